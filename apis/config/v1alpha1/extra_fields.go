@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"sort"
+)
+
+// ExtraFields contains keys of a k3OS config.yaml section that aren't modelled by the typed fields.
+// They are kept so that parsing and marshaling a config file doesn't lose them.
+// The values are the generic YAML values (maps, slices and scalars) they were decoded into.
+// +kubebuilder:object:generate=false
+type ExtraFields map[string]interface{}
+
+// Keys returns the sorted keys.
+func (in ExtraFields) Keys() []string {
+	keys := make([]string, 0, len(in))
+	for key := range in {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DeepCopyInto is a deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in ExtraFields) DeepCopyInto(out *ExtraFields) {
+	*out = in.DeepCopy()
+}
+
+// DeepCopy is a deepcopy function, copying the receiver, creating a new ExtraFields.
+func (in ExtraFields) DeepCopy() ExtraFields {
+	if in == nil {
+		return nil
+	}
+	out := make(ExtraFields, len(in))
+	for key, value := range in {
+		out[key] = deepCopyValue(value)
+	}
+	return out
+}
+
+func deepCopyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = deepCopyValue(value)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[interface{}]interface{}, len(v))
+		for key, value := range v {
+			out[key] = deepCopyValue(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = deepCopyValue(value)
+		}
+		return out
+	default: // scalars are immutable
+		return v
+	}
+}
@@ -0,0 +1,131 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultDeniedLabelKeys are the key patterns of labels that are never synced unless they're allowed: the prefixes
+// reserved for Kubernetes and k3s. The NodeRestriction admission plugin restricts them and k3s and the kubelet set
+// many of them (e.g. kubernetes.io/hostname or node.kubernetes.io/instance-type) themselves.
+var DefaultDeniedLabelKeys = []string{"kubernetes.io/", "*.kubernetes.io/", "k8s.io/", "*.k8s.io/", "k3s.io/", "*.k3s.io/"}
+
+// DefaultAllowedLabelKeys are the key patterns of labels that are synced even though they match DefaultDeniedLabelKeys:
+// the well-known topology labels, which nothing sets on k3OS nodes by default, and node-role.kubernetes.io/. The
+// NodeRestriction admission plugin only keeps the kubelet from setting node roles on its own node; the operator sets
+// them with its own credentials and the roles of the k3os_config_operator section are synced as these labels.
+var DefaultAllowedLabelKeys = []string{NodeRoleLabelPrefix, "topology.kubernetes.io/", "failure-domain.beta.kubernetes.io/"}
+
+// LabelPolicy restricts which labels of the node config are synced. A label is synced unless its key matches a
+// denied pattern and no allowed pattern. The patterns of the policy take precedence over DefaultAllowedLabelKeys and
+// DefaultDeniedLabelKeys, which apply to keys that match neither of them. A pattern is a label key where `*` matches
+// any sequence of characters; patterns ending with `/` match all keys with that prefix. To only sync some labels deny
+// `*` and allow their keys.
+type LabelPolicy struct {
+	// Allowed are the key patterns of labels that are synced even if they match a denied pattern.
+	// +optional
+	Allowed []string `json:"allowed,omitempty"`
+
+	// Denied are the key patterns of labels that aren't synced.
+	// +optional
+	Denied []string `json:"denied,omitempty"`
+}
+
+// AllowsKey returns whether the label with the key is synced. A nil policy only applies the default patterns.
+func (p *LabelPolicy) AllowsKey(key string) bool {
+	if p != nil {
+		if matchesAnyLabelKeyPattern(key, p.Allowed) {
+			return true
+		}
+		if matchesAnyLabelKeyPattern(key, p.Denied) {
+			return false
+		}
+	}
+	return matchesAnyLabelKeyPattern(key, DefaultAllowedLabelKeys) || !matchesAnyLabelKeyPattern(key, DefaultDeniedLabelKeys)
+}
+
+// Filter returns the labels that are synced and the sorted keys of the ones that aren't.
+func (p *LabelPolicy) Filter(labels map[string]string) (map[string]string, []string) {
+	return p.FilterOwned(labels, nil)
+}
+
+// FilterOwned is like Filter but also syncs the labels with the owned keys that are only denied by
+// DefaultDeniedLabelKeys. This keeps changes to the defaults from removing labels the operator has been managing;
+// labels denied by the policy itself aren't kept.
+func (p *LabelPolicy) FilterOwned(labels map[string]string, owned map[string]struct{}) (map[string]string, []string) {
+	var skipped []string
+	filtered := make(map[string]string, len(labels))
+	for key, value := range labels {
+		_, isOwned := owned[key]
+		if p.AllowsKey(key) || (isOwned && !p.deniesKey(key)) {
+			filtered[key] = value
+		} else {
+			skipped = append(skipped, key)
+		}
+	}
+	sort.Strings(skipped)
+	return filtered, skipped
+}
+
+// deniesKey returns whether the policy itself denies the label with the key.
+func (p *LabelPolicy) deniesKey(key string) bool {
+	return p != nil && !matchesAnyLabelKeyPattern(key, p.Allowed) && matchesAnyLabelKeyPattern(key, p.Denied)
+}
+
+func matchesAnyLabelKeyPattern(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchLabelKeyPattern(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLabelKeyPattern reports whether the key matches the pattern: `*` matches any sequence of characters
+// (including none) and a trailing `/` matches any name after the prefix.
+func matchLabelKeyPattern(pattern, key string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "*"
+	}
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == key
+	}
+	if !strings.HasPrefix(key, parts[0]) {
+		return false
+	}
+	key = key[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(key, part)
+		if i < 0 {
+			return false
+		}
+		key = key[i+len(part):]
+	}
+	last := parts[len(parts)-1]
+	return len(key) >= len(last) && strings.HasSuffix(key, last)
+}
@@ -0,0 +1,93 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelPolicy_AllowsKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *LabelPolicy
+		key    string
+		want   bool
+	}{
+		{name: "unprefixed key", key: "region", want: true},
+		{name: "custom prefix", key: "example.com/region", want: true},
+		{name: "reserved prefix", key: "kubernetes.io/arch", want: false},
+		{name: "reserved subdomain", key: "node.kubernetes.io/instance-type", want: false},
+		{name: "reserved k8s.io prefix", key: "k8s.io/foo", want: false},
+		{name: "reserved k3s prefix", key: "k3s.io/hostname", want: false},
+		{name: "reserved k3s subdomain", key: "node.k3s.io/foo", want: false},
+		{name: "allowed by default", key: "node-role.kubernetes.io/worker", want: true},
+		{name: "topology label allowed by default", key: "topology.kubernetes.io/zone", want: true},
+		{name: "domain that only ends like a reserved one", key: "notkubernetes.io/foo", want: true},
+		{
+			name:   "allowed explicitly",
+			policy: &LabelPolicy{Allowed: []string{"node.kubernetes.io/exclude-from-external-load-balancers"}},
+			key:    "node.kubernetes.io/exclude-from-external-load-balancers",
+			want:   true,
+		},
+		{name: "denied by prefix", policy: &LabelPolicy{Denied: []string{"example.com/"}}, key: "example.com/region", want: false},
+		{name: "denied by glob", policy: &LabelPolicy{Denied: []string{"*.example.com/*-test"}}, key: "a.example.com/foo-test", want: false},
+		{name: "not matching the glob", policy: &LabelPolicy{Denied: []string{"*.example.com/*-test"}}, key: "a.example.com/foo-prod", want: true},
+		{name: "allow list", policy: &LabelPolicy{Allowed: []string{"region", "zone"}, Denied: []string{"*"}}, key: "zone", want: true},
+		{name: "not in allow list", policy: &LabelPolicy{Allowed: []string{"region", "zone"}, Denied: []string{"*"}}, key: "rack", want: false},
+		{name: "default allowed key denied explicitly", policy: &LabelPolicy{Denied: []string{"node-role.kubernetes.io/master"}}, key: "node-role.kubernetes.io/master", want: false},
+		{name: "allow list denies keys allowed by default", policy: &LabelPolicy{Allowed: []string{"region"}, Denied: []string{"*"}}, key: "node-role.kubernetes.io/worker", want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.AllowsKey(tt.key); got != tt.want {
+				t.Errorf("AllowsKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelPolicy_Filter(t *testing.T) {
+	labels, skipped := (&LabelPolicy{Denied: []string{"rack"}}).Filter(map[string]string{"region": "shelf-1", "rack": "a", "kubernetes.io/arch": "arm64"})
+	if want := map[string]string{"region": "shelf-1"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("Filter() labels = %v, want %v", labels, want)
+	}
+	if want := []string{"kubernetes.io/arch", "rack"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("Filter() skipped = %v, want %v", skipped, want)
+	}
+}
+
+func TestLabelPolicy_FilterOwned(t *testing.T) {
+	labels := map[string]string{"region": "shelf-1", "rack": "a", "kubernetes.io/arch": "arm64", "node.kubernetes.io/instance-type": "pi4"}
+	owned := map[string]struct{}{"rack": {}, "node.kubernetes.io/instance-type": {}}
+	filtered, skipped := (&LabelPolicy{Denied: []string{"rack"}}).FilterOwned(labels, owned)
+	if want := map[string]string{"region": "shelf-1", "node.kubernetes.io/instance-type": "pi4"}; !reflect.DeepEqual(filtered, want) {
+		t.Errorf("FilterOwned() labels = %v, want %v", filtered, want)
+	}
+	if want := []string{"kubernetes.io/arch", "rack"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("FilterOwned() skipped = %v, want %v", skipped, want)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Condition types of the K3OSConfig.
+const (
+	// ConditionTypeReady is true if all nodes synced successfully.
+	ConditionTypeReady = "Ready"
+	// ConditionTypeDegraded is true if at least one node failed to sync.
+	ConditionTypeDegraded = "Degraded"
+)
+
+// Condition reasons of the K3OSConfig.
+const (
+	// ConditionReasonAllNodesSynced is used when all nodes synced successfully.
+	ConditionReasonAllNodesSynced = "AllNodesSynced"
+	// ConditionReasonNodesFailed is used when at least one node failed to sync.
+	ConditionReasonNodesFailed = "NodesFailed"
+	// ConditionReasonNodesStale is used when at least one node didn't report its sync status within NodeStaleAfter.
+	ConditionReasonNodesStale = "NodesStale"
+	// ConditionReasonNodesPending is used when at least one node didn't sync the current generation yet.
+	ConditionReasonNodesPending = "NodesPending"
+	// ConditionReasonNoNodes is used when there are no nodes.
+	ConditionReasonNoNodes = "NoNodes"
+	// ConditionReasonOwnershipRepaired is used when the ownership annotations of at least one node couldn't be read and were repaired.
+	ConditionReasonOwnershipRepaired = "OwnershipRepaired"
+)
+
+// maxNodeNamesInConditionMessage limits the number of node names listed in condition messages.
+const maxNodeNamesInConditionMessage = 10
+
+// UpdateSummary marks stale nodes and computes the summary as well as the Ready and Degraded
+// conditions from the sync status of all nodes.
+func (s *K3OSConfigStatus) UpdateSummary(generation int64, staleAfter time.Duration, now time.Time) {
+	var failed, stale, pending, repaired []string
+	s.Summary = K3OSConfigSyncSummary{Nodes: int32(len(s.Nodes))}
+	for i := range s.Nodes {
+		nodeStatus := &s.Nodes[i]
+		nodeStatus.Stale = nodeStatus.LastSyncTime != nil && now.Sub(nodeStatus.LastSyncTime.Time) > staleAfter
+		if nodeStatus.Planned != nil {
+			s.Summary.Planned++
+		}
+		if len(nodeStatus.OwnershipErrors) > 0 {
+			repaired = append(repaired, nodeStatus.NodeName)
+		}
+		switch {
+		case nodeStatus.LastSyncTime == nil:
+			pending = append(pending, nodeStatus.NodeName)
+		case nodeStatus.Stale:
+			stale = append(stale, nodeStatus.NodeName)
+		case nodeStatus.LastError != "":
+			failed = append(failed, nodeStatus.NodeName)
+		case nodeStatus.ObservedGeneration < generation:
+			pending = append(pending, nodeStatus.NodeName)
+		default:
+			s.Summary.Synced++
+		}
+	}
+	s.Summary.Failed, s.Summary.Stale, s.Summary.Pending = int32(len(failed)), int32(len(stale)), int32(len(pending))
+
+	ready := metav1.Condition{Type: ConditionTypeReady, Status: metav1.ConditionFalse, ObservedGeneration: generation}
+	degraded := metav1.Condition{Type: ConditionTypeDegraded, Status: metav1.ConditionFalse, ObservedGeneration: generation}
+	switch {
+	case len(s.Nodes) == 0:
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionUnknown, ConditionReasonNoNodes, "there are no nodes"
+	case len(failed) > 0:
+		ready.Reason, ready.Message = ConditionReasonNodesFailed, nodesMessage("failed to sync", failed, len(s.Nodes))
+	case len(stale) > 0:
+		ready.Reason, ready.Message = ConditionReasonNodesStale, nodesMessage("didn't report within "+staleAfter.String(), stale, len(s.Nodes))
+	case len(pending) > 0:
+		ready.Reason, ready.Message = ConditionReasonNodesPending, nodesMessage("didn't sync the current generation yet", pending, len(s.Nodes))
+	default:
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionTrue, ConditionReasonAllNodesSynced, fmt.Sprintf("all %d nodes synced successfully", len(s.Nodes))
+	}
+	degraded.Reason, degraded.Message = ready.Reason, ready.Message
+	switch {
+	case len(failed) > 0 || len(stale) > 0:
+		degraded.Status = metav1.ConditionTrue
+	case len(repaired) > 0: // clears once the nodes synced again with the repaired annotations
+		degraded.Status, degraded.Reason = metav1.ConditionTrue, ConditionReasonOwnershipRepaired
+		degraded.Message = nodesMessage("had ownership annotations that couldn't be read and were repaired", repaired, len(s.Nodes))
+	}
+	meta.SetStatusCondition(&s.Conditions, ready)
+	meta.SetStatusCondition(&s.Conditions, degraded)
+}
+
+// NextStaleCheck returns the duration after which the next node that currently isn't stale becomes stale.
+// It returns false if there is no such node.
+func (s *K3OSConfigStatus) NextStaleCheck(staleAfter time.Duration, now time.Time) (time.Duration, bool) {
+	var next time.Duration
+	var found bool
+	for i := range s.Nodes {
+		if s.Nodes[i].LastSyncTime == nil || s.Nodes[i].Stale {
+			continue
+		}
+		if until := s.Nodes[i].LastSyncTime.Add(staleAfter).Sub(now); !found || until < next {
+			next, found = until, true
+		}
+	}
+	return next, found
+}
+
+func nodesMessage(problem string, nodeNames []string, numNodes int) string {
+	numAffected := len(nodeNames)
+	if numAffected > maxNodeNamesInConditionMessage {
+		nodeNames = append(nodeNames[:maxNodeNamesInConditionMessage:maxNodeNamesInConditionMessage], "…")
+	}
+	return fmt.Sprintf("%d of %d nodes %s: %s", numAffected, numNodes, problem, strings.Join(nodeNames, ", "))
+}
@@ -0,0 +1,157 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestK3OSConfigStatus_UpdateSummary(t *testing.T) {
+	now := time.Now()
+	staleAfter := 15 * time.Minute
+	recently := metav1.NewTime(now.Add(-time.Minute))
+	longAgo := metav1.NewTime(now.Add(-time.Hour))
+
+	tests := []struct {
+		name            string
+		nodes           []K3OSConfigNodeStatus
+		expectedSummary K3OSConfigSyncSummary
+		expectedReady   metav1.ConditionStatus
+		expectedReason  string
+		degraded        bool
+	}{
+		{
+			name:            "no nodes",
+			expectedReady:   metav1.ConditionUnknown,
+			expectedReason:  ConditionReasonNoNodes,
+			expectedSummary: K3OSConfigSyncSummary{},
+		},
+		{
+			name: "all nodes synced",
+			nodes: []K3OSConfigNodeStatus{
+				{NodeName: "n1", ObservedGeneration: 1, LastSyncTime: &recently},
+				{NodeName: "n2", ObservedGeneration: 1, LastSyncTime: &recently},
+			},
+			expectedReady:   metav1.ConditionTrue,
+			expectedReason:  ConditionReasonAllNodesSynced,
+			expectedSummary: K3OSConfigSyncSummary{Nodes: 2, Synced: 2},
+		},
+		{
+			name: "one node didn't report yet, another one didn't sync the current generation",
+			nodes: []K3OSConfigNodeStatus{
+				{NodeName: "n1"},
+				{NodeName: "n2", ObservedGeneration: 0, LastSyncTime: &recently},
+			},
+			expectedReady:   metav1.ConditionFalse,
+			expectedReason:  ConditionReasonNodesPending,
+			expectedSummary: K3OSConfigSyncSummary{Nodes: 2, Pending: 2},
+		},
+		{
+			name: "one node is stale",
+			nodes: []K3OSConfigNodeStatus{
+				{NodeName: "n1", ObservedGeneration: 1, LastSyncTime: &longAgo},
+				{NodeName: "n2", ObservedGeneration: 1, LastSyncTime: &recently},
+			},
+			expectedReady:   metav1.ConditionFalse,
+			expectedReason:  ConditionReasonNodesStale,
+			expectedSummary: K3OSConfigSyncSummary{Nodes: 2, Synced: 1, Stale: 1},
+			degraded:        true,
+		},
+		{
+			name: "failed nodes take precedence over stale nodes",
+			nodes: []K3OSConfigNodeStatus{
+				{NodeName: "n1", ObservedGeneration: 1, LastSyncTime: &longAgo},
+				{NodeName: "n2", ObservedGeneration: 1, LastSyncTime: &recently, LastError: "failed"},
+			},
+			expectedReady:   metav1.ConditionFalse,
+			expectedReason:  ConditionReasonNodesFailed,
+			expectedSummary: K3OSConfigSyncSummary{Nodes: 2, Failed: 1, Stale: 1},
+			degraded:        true,
+		},
+		{
+			name: "nodes with planned changes in dry-run mode",
+			nodes: []K3OSConfigNodeStatus{
+				{NodeName: "n1", ObservedGeneration: 1, LastSyncTime: &recently, Planned: &K3OSConfigPlannedChanges{Labels: map[string]string{"a": "b"}}},
+				{NodeName: "n2", ObservedGeneration: 1, LastSyncTime: &recently},
+			},
+			expectedReady:   metav1.ConditionTrue,
+			expectedReason:  ConditionReasonAllNodesSynced,
+			expectedSummary: K3OSConfigSyncSummary{Nodes: 2, Synced: 2, Planned: 1},
+		},
+		{
+			name: "node with repaired ownership annotations is degraded but ready",
+			nodes: []K3OSConfigNodeStatus{
+				{NodeName: "n1", ObservedGeneration: 1, LastSyncTime: &recently, OwnershipErrors: []string{"failed to parse annotation"}},
+				{NodeName: "n2", ObservedGeneration: 1, LastSyncTime: &recently},
+			},
+			expectedReady:   metav1.ConditionTrue,
+			expectedReason:  ConditionReasonAllNodesSynced,
+			expectedSummary: K3OSConfigSyncSummary{Nodes: 2, Synced: 2},
+			degraded:        true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := &K3OSConfigStatus{Nodes: tt.nodes}
+			s.UpdateSummary(1, staleAfter, now)
+			if s.Summary != tt.expectedSummary {
+				t.Errorf("UpdateSummary() summary = %+v, want %+v", s.Summary, tt.expectedSummary)
+			}
+			ready := meta.FindStatusCondition(s.Conditions, ConditionTypeReady)
+			if ready == nil || ready.Status != tt.expectedReady || ready.Reason != tt.expectedReason {
+				t.Errorf("UpdateSummary() Ready condition = %+v, want status %q and reason %q", ready, tt.expectedReady, tt.expectedReason)
+			}
+			if degraded := meta.IsStatusConditionTrue(s.Conditions, ConditionTypeDegraded); degraded != tt.degraded {
+				t.Errorf("UpdateSummary() Degraded = %v, want %v", degraded, tt.degraded)
+			}
+		})
+	}
+}
+
+func TestK3OSConfigStatus_NextStaleCheck(t *testing.T) {
+	now := time.Now()
+	staleAfter := 15 * time.Minute
+	fiveMinutesAgo := metav1.NewTime(now.Add(-5 * time.Minute))
+	oneMinuteAgo := metav1.NewTime(now.Add(-time.Minute))
+
+	s := &K3OSConfigStatus{Nodes: []K3OSConfigNodeStatus{
+		{NodeName: "pending"},
+		{NodeName: "n1", LastSyncTime: &oneMinuteAgo},
+		{NodeName: "n2", LastSyncTime: &fiveMinutesAgo},
+	}}
+	next, ok := s.NextStaleCheck(staleAfter, now)
+	if !ok || next != 10*time.Minute {
+		t.Errorf("NextStaleCheck() = %v, %v, want %v, true", next, ok, 10*time.Minute)
+	}
+
+	if _, ok = (&K3OSConfigStatus{Nodes: []K3OSConfigNodeStatus{{NodeName: "pending"}}}).NextStaleCheck(staleAfter, now); ok {
+		t.Error("NextStaleCheck() expected no node to become stale")
+	}
+}
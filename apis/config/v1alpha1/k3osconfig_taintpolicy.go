@@ -0,0 +1,118 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NoExecutePolicy denotes whether taints with the NoExecute effect are added to nodes.
+// +kubebuilder:validation:Enum=Allow;OptIn;Forbid
+type NoExecutePolicy string
+
+const (
+	// NoExecutePolicyAllow adds all taints with the NoExecute effect.
+	NoExecutePolicyAllow NoExecutePolicy = "Allow"
+	// NoExecutePolicyOptIn only adds the taints with the NoExecute effect whose keys are listed in NoExecuteKeys.
+	NoExecutePolicyOptIn NoExecutePolicy = "OptIn"
+	// NoExecutePolicyForbid never adds taints with the NoExecute effect.
+	NoExecutePolicyForbid NoExecutePolicy = "Forbid"
+)
+
+// TaintPolicy contains the safeguards for taints with the NoExecute effect which evict all pods that don't tolerate
+// them from the node right away.
+type TaintPolicy struct {
+	// NoExecute defines whether taints with the NoExecute effect are added to nodes. Taints that aren't added are
+	// skipped as if they weren't part of the node config. Defaults to Allow.
+	// +optional
+	// +kubebuilder:default=Allow
+	NoExecute NoExecutePolicy `json:"noExecute,omitempty"`
+
+	// NoExecuteKeys are the keys of the taints with the NoExecute effect that are added with the OptIn policy.
+	// +optional
+	NoExecuteKeys []string `json:"noExecuteKeys,omitempty"`
+
+	// NoExecuteGracePeriod delays taints with the NoExecute effect: they are added with the NoSchedule effect first
+	// and escalated to NoExecute once this duration elapsed.
+	// +optional
+	NoExecuteGracePeriod *metav1.Duration `json:"noExecuteGracePeriod,omitempty"`
+
+	// MaxNoExecuteNodesPercent is the maximum percentage of all nodes (rounded down but at least one node) that
+	// carry taints with the NoExecute effect added by the operator at the same time. The leader approves adding
+	// them to one node after another; nodes waiting for the approval carry them with the NoSchedule effect.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	MaxNoExecuteNodesPercent *int32 `json:"maxNoExecuteNodesPercent,omitempty"`
+}
+
+// AllowsNoExecute returns whether the taint with the key is added with the NoExecute effect.
+// A nil policy allows all taints.
+func (p *TaintPolicy) AllowsNoExecute(key string) bool {
+	if p == nil {
+		return true
+	}
+	switch p.NoExecute {
+	case NoExecutePolicyForbid:
+		return false
+	case NoExecutePolicyOptIn:
+		for _, noExecuteKey := range p.NoExecuteKeys {
+			if noExecuteKey == key {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// GracePeriod returns the duration after which taints with the NoExecute effect are escalated from NoSchedule.
+// It is zero if they are added right away.
+func (p *TaintPolicy) GracePeriod() time.Duration {
+	if p == nil || p.NoExecuteGracePeriod == nil || p.NoExecuteGracePeriod.Duration <= 0 {
+		return 0
+	}
+	return p.NoExecuteGracePeriod.Duration
+}
+
+// LimitsNoExecuteNodes returns whether adding taints with the NoExecute effect requires the approval of the leader.
+func (p *TaintPolicy) LimitsNoExecuteNodes() bool {
+	return p != nil && p.MaxNoExecuteNodesPercent != nil
+}
+
+// MaxNoExecuteNodes returns how many of the nodes may carry taints with the NoExecute effect at the same time.
+func (p *TaintPolicy) MaxNoExecuteNodes(nodeCount int) int {
+	if !p.LimitsNoExecuteNodes() {
+		return nodeCount
+	}
+	maxNodes := nodeCount * int(*p.MaxNoExecuteNodesPercent) / 100
+	if maxNodes < 1 {
+		return 1
+	}
+	return maxNodes
+}
@@ -0,0 +1,89 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTaintPolicy_AllowsNoExecute(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *TaintPolicy
+		key    string
+		want   bool
+	}{
+		{name: "no policy", key: "dedicated", want: true},
+		{name: "default policy", policy: &TaintPolicy{}, key: "dedicated", want: true},
+		{name: "allowed", policy: &TaintPolicy{NoExecute: NoExecutePolicyAllow}, key: "dedicated", want: true},
+		{name: "forbidden", policy: &TaintPolicy{NoExecute: NoExecutePolicyForbid, NoExecuteKeys: []string{"dedicated"}}, key: "dedicated", want: false},
+		{name: "opted in", policy: &TaintPolicy{NoExecute: NoExecutePolicyOptIn, NoExecuteKeys: []string{"dedicated"}}, key: "dedicated", want: true},
+		{name: "not opted in", policy: &TaintPolicy{NoExecute: NoExecutePolicyOptIn, NoExecuteKeys: []string{"dedicated"}}, key: "maintenance", want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.AllowsNoExecute(tt.key); got != tt.want {
+				t.Errorf("TaintPolicy.AllowsNoExecute(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaintPolicy_GracePeriod(t *testing.T) {
+	if got := (*TaintPolicy)(nil).GracePeriod(); got != 0 {
+		t.Errorf("TaintPolicy.GracePeriod() of nil policy = %v, want 0", got)
+	}
+	policy := &TaintPolicy{NoExecuteGracePeriod: &metav1.Duration{Duration: 5 * time.Minute}}
+	if got := policy.GracePeriod(); got != 5*time.Minute {
+		t.Errorf("TaintPolicy.GracePeriod() = %v, want 5m", got)
+	}
+}
+
+func TestTaintPolicy_MaxNoExecuteNodes(t *testing.T) {
+	percent := func(p int32) *TaintPolicy { return &TaintPolicy{MaxNoExecuteNodesPercent: &p} }
+	tests := []struct {
+		name      string
+		policy    *TaintPolicy
+		nodeCount int
+		want      int
+	}{
+		{name: "no limit", nodeCount: 5, want: 5},
+		{name: "rounded down", policy: percent(50), nodeCount: 5, want: 2},
+		{name: "at least one node", policy: percent(10), nodeCount: 3, want: 1},
+		{name: "all nodes", policy: percent(100), nodeCount: 3, want: 3},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.MaxNoExecuteNodes(tt.nodeCount); got != tt.want {
+				t.Errorf("TaintPolicy.MaxNoExecuteNodes(%d) = %v, want %v", tt.nodeCount, got, tt.want)
+			}
+		})
+	}
+}
@@ -25,6 +25,8 @@ SOFTWARE.
 package v1alpha1
 
 import (
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -34,22 +36,390 @@ const K3OSConfigKind = "K3OSConfig"
 // K3OSConfigListKind contains the Kind of a list of K3OSConfig CRs.
 const K3OSConfigListKind = "K3OSConfigList"
 
+// DefaultNodeStaleAfter is used if NodeStaleAfter isn't set.
+const DefaultNodeStaleAfter = 15 * time.Minute
+
 // K3OSConfigSpec defines the desired state of K3OSConfig.
 type K3OSConfigSpec struct {
-	// SyncNodeLabels enables syncing node labels set in the K3OS config.yaml.
-	// K3OS by default only sets labels on nodes on first boot.
+	// SyncNodeLabels enables syncing node labels set in the K3OS config.yaml, including the labels of the
+	// node roles set in its `k3os_config_operator` section. K3OS by default only sets labels on nodes on first boot.
 	SyncNodeLabels bool `json:"syncNodeLabels,omitempty"`
 
+	// LabelPolicy restricts which labels of the node config are synced. Labels with keys reserved for Kubernetes
+	// and k3s (kubernetes.io/, k8s.io/, k3s.io/ and their subdomains except node-role.kubernetes.io/,
+	// topology.kubernetes.io/ and failure-domain.beta.kubernetes.io/) are only synced if they're allowed explicitly
+	// or the operator added them before they were denied by default.
+	// +optional
+	LabelPolicy *LabelPolicy `json:"labelPolicy,omitempty"`
+
 	// SyncNodeTaints enables syncing node taints set in the K3OS config.yaml.
 	// K3OS by default only sets taints on nodes on first boot.
 	SyncNodeTaints bool `json:"syncNodeTaints,omitempty"`
+
+	// TaintPolicy contains the safeguards for taints with the NoExecute effect: they can be forbidden or require
+	// an opt-in, be escalated from NoSchedule after a grace period and be limited to a percentage of the nodes.
+	// +optional
+	TaintPolicy *TaintPolicy `json:"taintPolicy,omitempty"`
+
+	// SyncNodeAnnotations enables syncing node annotations set in the `k3os_config_operator` section of the K3OS config.yaml.
+	// +optional
+	SyncNodeAnnotations bool `json:"syncNodeAnnotations,omitempty"`
+
+	// SyncNodeSpec enables syncing the unschedulable and providerID fields of the node spec set in the
+	// `k3os_config_operator` section of the K3OS config.yaml.
+	// +optional
+	SyncNodeSpec bool `json:"syncNodeSpec,omitempty"`
+
+	// UnschedulablePolicy defines whether the unschedulable field of the node config overrides nodes that were
+	// cordoned or uncordoned by someone else (e.g. with kubectl cordon). Only used with SyncNodeSpec.
+	// +optional
+	// +kubebuilder:default=Respect
+	UnschedulablePolicy UnschedulablePolicy `json:"unschedulablePolicy,omitempty"`
+
+	// NodeStaleAfter is the duration after which a node that didn't report its sync status is considered stale.
+	// Nodes report their sync status at least three times within this duration. Defaults to 15m.
+	// +optional
+	NodeStaleAfter *metav1.Duration `json:"nodeStaleAfter,omitempty"`
+
+	// RebootNodes enables rebooting nodes after changes to the node config file that only take effect on boot.
+	// Nodes are cordoned, drained and rebooted one at a time and uncordoned once they're Ready again.
+	// Requires node config file management and a reboot command to be configured on the operator. Nodes whose
+	// operator has no reboot command are left alone and nodes that aren't rebooted within 30m are uncordoned.
+	// +optional
+	RebootNodes bool `json:"rebootNodes,omitempty"`
+
+	// DryRun makes the nodes compute the changes a sync would make without applying them. The planned
+	// label and taint changes and a diff of the node config file are reported in the status of every node.
+	// Drop-in files and reboots are left alone.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// DriftPolicy defines how changes to the node config file on disk that weren't made by the operator
+	// (e.g. by hand over SSH) are handled. Drift is reported in the status of the node in any case.
+	// +optional
+	// +kubebuilder:default=Correct
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// Layers configures merging the config of every node from several entries of the node config Secret.
+	// If unset, the entry named after the node contains its complete config.
+	// +optional
+	Layers *K3OSConfigLayers `json:"layers,omitempty"`
+
+	// Templating renders the entries of the node config Secret as Go templates for every node before they are parsed.
+	// +optional
+	Templating *K3OSConfigTemplating `json:"templating,omitempty"`
+}
+
+// K3OSConfigTemplating configures rendering the entries of the node config Secret as Go templates. Templates have
+// access to the facts of the node (.Node.Name, .Node.Labels, .Node.Addresses, .Node.Architecture,
+// .Node.OperatingSystem, .Node.Capacity) and the data of the values ConfigMap (.Values) and Secret (.Secret).
+type K3OSConfigTemplating struct {
+	// Enabled enables rendering the entries of the node config Secret as templates.
+	Enabled bool `json:"enabled"`
+
+	// ValuesConfigMap is the name of a ConfigMap in the operator's namespace whose data is available as .Values.
+	// +optional
+	ValuesConfigMap string `json:"valuesConfigMap,omitempty"`
+
+	// ValuesSecret is the name of a Secret in the operator's namespace whose data is available as .Secret.
+	// +optional
+	ValuesSecret string `json:"valuesSecret,omitempty"`
+}
+
+// K3OSConfigLayers configures merging the config of a node from a base layer, group layers and the node's own entry
+// of the node config Secret (in this order). Maps are merged deeply, lists according to their merge strategy.
+type K3OSConfigLayers struct {
+	// Base is the key of the node config Secret entry that is merged into the config of every node first.
+	// +optional
+	Base string `json:"base,omitempty"`
+
+	// Groups are merged on top of the base layer in order into the config of the nodes they select.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Groups []K3OSConfigLayerGroup `json:"groups,omitempty"`
+
+	// ListMergeStrategies overrides how the lists at the given paths are merged. By default boot_cmd, init_cmd and
+	// run_cmd are appended, ssh_authorized_keys and k3os.modules are merged as a union, write_files (by path) and
+	// k3os.wifi (by name) are keyed and all other lists are replaced.
+	// +optional
+	// +listType=map
+	// +listMapKey=path
+	ListMergeStrategies []ListMergeStrategy `json:"listMergeStrategies,omitempty"`
+}
+
+// K3OSConfigLayerGroup is a layer that is merged into the config of the nodes selected by its node selector.
+type K3OSConfigLayerGroup struct {
+	// Name is the name of the group.
+	Name string `json:"name"`
+
+	// Key is the key of the node config Secret entry that contains the config of this group.
+	Key string `json:"key"`
+
+	// NodeSelector selects the nodes this layer is merged into. An empty selector selects all nodes.
+	NodeSelector metav1.LabelSelector `json:"nodeSelector"`
+}
+
+// UnschedulablePolicy denotes whether the operator overrides the unschedulable field of nodes it doesn't own.
+// +kubebuilder:validation:Enum=Respect;Enforce
+type UnschedulablePolicy string
+
+const (
+	// UnschedulablePolicyRespect only cordons nodes and uncordons the nodes the operator cordoned itself.
+	UnschedulablePolicyRespect UnschedulablePolicy = "Respect"
+	// UnschedulablePolicyEnforce sets the unschedulable field of the node config regardless of who changed it last.
+	UnschedulablePolicyEnforce UnschedulablePolicy = "Enforce"
+)
+
+// DriftPolicy denotes how drift of the node config file on disk is handled.
+// +kubebuilder:validation:Enum=Correct;Report;Merge
+type DriftPolicy string
+
+const (
+	// DriftPolicyCorrect overwrites the node config file with the desired config.
+	DriftPolicyCorrect DriftPolicy = "Correct"
+	// DriftPolicyReport only reports the drift and leaves the node config file alone until it's resolved by hand.
+	DriftPolicyReport DriftPolicy = "Report"
+	// DriftPolicyMerge merges the changes of the desired config since the operator last wrote the node config file
+	// into the file on disk and keeps all other changes made by someone else. Fields changed on both sides are conflicts
+	// that keep their value on disk.
+	DriftPolicyMerge DriftPolicy = "Merge"
+)
+
+// ListMergeStrategyType denotes how a list of a lower layer is merged with the same list of a higher layer.
+// +kubebuilder:validation:Enum=Append;Union;Replace;Keyed
+type ListMergeStrategyType string
+
+const (
+	// ListMergeAppend appends the items of the higher layer to the items of the lower layer.
+	ListMergeAppend ListMergeStrategyType = "Append"
+	// ListMergeUnion appends the items of the higher layer that the lower layer doesn't contain yet.
+	ListMergeUnion ListMergeStrategyType = "Union"
+	// ListMergeReplace replaces the items of the lower layer with the items of the higher layer.
+	ListMergeReplace ListMergeStrategyType = "Replace"
+	// ListMergeKeyed deeply merges items with the same value of the merge key and appends all others.
+	ListMergeKeyed ListMergeStrategyType = "Keyed"
+)
+
+// ListMergeStrategy defines how the list at a path of the node config file is merged.
+type ListMergeStrategy struct {
+	// Path is the path of the list in the node config file, e.g. k3os.ntp_servers or write_files.
+	Path string `json:"path"`
+
+	// Strategy is the merge strategy of the list.
+	Strategy ListMergeStrategyType `json:"strategy"`
+
+	// MergeKey is the field that identifies the items of the list. Required for the Keyed strategy.
+	// +optional
+	MergeKey string `json:"mergeKey,omitempty"`
+}
+
+// StaleAfter returns the duration after which a node that didn't report its sync status is considered stale.
+func (s *K3OSConfigSpec) StaleAfter() time.Duration {
+	if s.NodeStaleAfter == nil || s.NodeStaleAfter.Duration <= 0 {
+		return DefaultNodeStaleAfter
+	}
+	return s.NodeStaleAfter.Duration
 }
 
 // K3OSConfigStatus defines the observed state of K3OSConfig.
-type K3OSConfigStatus struct{}
+type K3OSConfigStatus struct {
+	// Conditions contains the cluster-wide conditions (Ready, Degraded) of the K3OSConfig.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Summary contains the cluster-wide rollup of the sync status of all nodes.
+	// +optional
+	Summary K3OSConfigSyncSummary `json:"summary,omitempty"`
+
+	// Nodes contains the sync status of every node in the cluster as reported by the operator running on it.
+	// +optional
+	// +listType=map
+	// +listMapKey=nodeName
+	Nodes []K3OSConfigNodeStatus `json:"nodes,omitempty"`
+}
+
+// K3OSConfigSyncSummary contains the number of nodes per sync state.
+type K3OSConfigSyncSummary struct {
+	// Nodes is the number of nodes in the cluster.
+	Nodes int32 `json:"nodes"`
+	// Synced is the number of nodes that synced the current generation of the K3OSConfig successfully.
+	Synced int32 `json:"synced"`
+	// Failed is the number of nodes whose last sync failed.
+	Failed int32 `json:"failed"`
+	// Stale is the number of nodes that didn't report their sync status within NodeStaleAfter.
+	Stale int32 `json:"stale"`
+	// Pending is the number of nodes that didn't report their sync status for the current generation yet.
+	Pending int32 `json:"pending"`
+	// Planned is the number of nodes with changes planned in dry-run mode.
+	// +optional
+	Planned int32 `json:"planned,omitempty"`
+}
+
+// ConfigFileUpdateResult denotes the outcome of updating the node config file on disk.
+// +kubebuilder:validation:Enum=Updated;Planned;Skipped;Disabled;Failed
+type ConfigFileUpdateResult string
+
+const (
+	// ConfigFileUpdated means the node config file (or drop-in files) on disk was rewritten.
+	ConfigFileUpdated ConfigFileUpdateResult = "Updated"
+	// ConfigFilePlanned means the node config file on disk would be rewritten but the K3OSConfig is in dry-run mode.
+	ConfigFilePlanned ConfigFileUpdateResult = "Planned"
+	// ConfigFileSkipped means the node config file on disk was already up to date.
+	ConfigFileSkipped ConfigFileUpdateResult = "Skipped"
+	// ConfigFileDisabled means node config file management is disabled.
+	ConfigFileDisabled ConfigFileUpdateResult = "Disabled"
+	// ConfigFileFailed means updating the node config file on disk failed.
+	ConfigFileFailed ConfigFileUpdateResult = "Failed"
+)
+
+// ConfigFileDrift describes changes to the node config file on disk that weren't made by the operator.
+type ConfigFileDrift struct {
+	// Diff is the unified diff between the contents the operator last wrote and the node config file on disk
+	// with the values of secret fields redacted.
+	Diff string `json:"diff"`
+
+	// Corrected is set if the operator overwrote the node config file (DriftPolicy Correct).
+	// +optional
+	Corrected bool `json:"corrected,omitempty"`
+
+	// Conflicts contains the paths of the fields that were changed on disk and in the desired config in different ways
+	// during the last merge (DriftPolicy Merge). They keep their value on disk.
+	// +optional
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// K3OSConfigPlannedChanges contains the changes a sync would make to a node.
+type K3OSConfigPlannedChanges struct {
+	// Labels contains the labels that would be added, changed or removed.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Taints contains the taints that would be added, changed or removed.
+	// +optional
+	Taints map[string]string `json:"taints,omitempty"`
+
+	// Annotations contains the annotations that would be added, changed or removed.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// SpecFields contains the node spec fields that would be changed.
+	// +optional
+	SpecFields map[string]string `json:"specFields,omitempty"`
+
+	// ConfigFileDiff is the unified diff of the node config file on disk with the values of secret fields redacted.
+	// +optional
+	ConfigFileDiff string `json:"configFileDiff,omitempty"`
+
+	// BootTimeChanges contains the node config file fields that would change and only take effect after a reboot.
+	// +optional
+	BootTimeChanges []string `json:"bootTimeChanges,omitempty"`
+}
+
+// K3OSConfigNodeStatus contains the sync status of a single node.
+type K3OSConfigNodeStatus struct {
+	// NodeName is the name of the node this status belongs to.
+	NodeName string `json:"nodeName"`
+
+	// ObservedGeneration is the generation of the K3OSConfig that was last synced.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Stale is set by the leader if the node didn't report its sync status within NodeStaleAfter.
+	// +optional
+	Stale bool `json:"stale,omitempty"`
+
+	// ConfigSource is the source of the config of the node: `Secret/<name>` if it was read from the
+	// node config Secret or `K3OSConfigFile/<name>` if it was read from a K3OSConfigFile object.
+	// +optional
+	ConfigSource string `json:"configSource,omitempty"`
+
+	// ConfigHash is the hash of the node config that was last synced.
+	// +optional
+	ConfigHash string `json:"configHash,omitempty"`
+
+	// UpdatedLabels contains the labels that were added, changed or removed during the last sync that changed them.
+	// +optional
+	UpdatedLabels map[string]string `json:"updatedLabels,omitempty"`
+
+	// SkippedLabels contains the keys of the labels of the node config that weren't synced because the label policy denies them.
+	// +optional
+	SkippedLabels []string `json:"skippedLabels,omitempty"`
+
+	// UpdatedTaints contains the taints that were added, changed or removed during the last sync that changed them.
+	// +optional
+	UpdatedTaints map[string]string `json:"updatedTaints,omitempty"`
+
+	// SkippedTaints contains the taints of the node config with the NoExecute effect that weren't synced because
+	// the taint policy forbids them.
+	// +optional
+	SkippedTaints []string `json:"skippedTaints,omitempty"`
+
+	// DelayedTaints contains the taints of the node config with the NoExecute effect that were added with the
+	// NoSchedule effect because their grace period didn't elapse yet or the leader didn't approve them yet.
+	// +optional
+	DelayedTaints []string `json:"delayedTaints,omitempty"`
+
+	// UpdatedAnnotations contains the annotations that were added, changed or removed during the last sync that changed them.
+	// +optional
+	UpdatedAnnotations map[string]string `json:"updatedAnnotations,omitempty"`
+
+	// UpdatedSpecFields contains the node spec fields that were changed during the last sync that changed them.
+	// +optional
+	UpdatedSpecFields map[string]string `json:"updatedSpecFields,omitempty"`
+
+	// ConfigFileUpdate contains the outcome of updating the node config file on disk during the last sync.
+	// +optional
+	ConfigFileUpdate ConfigFileUpdateResult `json:"configFileUpdate,omitempty"`
+
+	// DropInUpdate contains the outcome of updating the config.d drop-in files on disk during the last sync.
+	// +optional
+	DropInUpdate ConfigFileUpdateResult `json:"dropInUpdate,omitempty"`
+
+	// BootTimeChanges contains the node config file fields that changed since the node was last booted.
+	// They're only recorded if rebootNodes is enabled.
+	// These changes only take effect after the node is rebooted.
+	// +optional
+	BootTimeChanges []string `json:"bootTimeChanges,omitempty"`
+
+	// ConfigFileDrift is set if the node config file on disk was changed by someone else since the operator last wrote it.
+	// +optional
+	ConfigFileDrift *ConfigFileDrift `json:"configFileDrift,omitempty"`
+
+	// Planned contains the changes the last sync would have made to the node in dry-run mode.
+	// It is empty if the K3OSConfig isn't in dry-run mode or there's nothing to change.
+	// +optional
+	Planned *K3OSConfigPlannedChanges `json:"planned,omitempty"`
+
+	// OwnershipErrors describe the annotations that keep track of the labels, taints, annotations and spec fields the
+	// operator added to the node that couldn't be read during the last sync. They were repaired but the entries that
+	// couldn't be read aren't removed from the node anymore.
+	// +optional
+	OwnershipErrors []string `json:"ownershipErrors,omitempty"`
+
+	// LastError contains the error of the last sync. It is empty if the last sync succeeded.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// LastSyncTime is the time the node was last synced.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// LastSuccessfulSyncTime is the time the node was last synced successfully.
+	// +optional
+	LastSuccessfulSyncTime *metav1.Time `json:"lastSuccessfulSyncTime,omitempty"`
+}
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Nodes",type=integer,JSONPath=`.status.summary.nodes`
+// +kubebuilder:printcolumn:name="Synced",type=integer,JSONPath=`.status.summary.synced`
+// +kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.summary.failed`
+// +kubebuilder:printcolumn:name="Stale",type=integer,JSONPath=`.status.summary.stale`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // K3OSConfig is the Schema for the k3osconfigs API.
 type K3OSConfig struct {
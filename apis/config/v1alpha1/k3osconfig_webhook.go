@@ -0,0 +1,158 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers the validating webhook for K3OSConfig CRs with the manager.
+func (r *K3OSConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(r).Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-config-operators-annismckenzie-github-com-v1alpha1-k3osconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=config.operators.annismckenzie.github.com,resources=k3osconfigs,verbs=create;update,versions=v1alpha1,name=vk3osconfig.kb.io,admissionReviewVersions=v1
+
+// K3OSConfig implements the webhook.Validator interface.
+var _ webhook.Validator = (*K3OSConfig)(nil)
+
+// ValidateCreate implements webhook.Validator.
+func (r *K3OSConfig) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator.
+func (r *K3OSConfig) ValidateUpdate(old runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator.
+func (r *K3OSConfig) ValidateDelete() error {
+	return nil
+}
+
+func (r *K3OSConfig) validate() error {
+	allErrs := r.Spec.ValidateFields(field.NewPath("spec"))
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind(K3OSConfigKind).GroupKind(), r.GetName(), allErrs)
+}
+
+// ValidateFields checks the contents of the spec for errors and returns them.
+func (s *K3OSConfigSpec) ValidateFields(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if s.NodeStaleAfter != nil && s.NodeStaleAfter.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("nodeStaleAfter"), s.NodeStaleAfter.Duration.String(), "must be a positive duration"))
+	}
+	if s.LabelPolicy != nil {
+		allErrs = append(allErrs, s.LabelPolicy.ValidateFields(fldPath.Child("labelPolicy"))...)
+	}
+	if s.TaintPolicy != nil {
+		allErrs = append(allErrs, s.TaintPolicy.ValidateFields(fldPath.Child("taintPolicy"))...)
+	}
+	if s.Layers != nil {
+		allErrs = append(allErrs, s.Layers.ValidateFields(fldPath.Child("layers"))...)
+	}
+	return allErrs
+}
+
+// ValidateFields checks the taint policy for errors and returns them.
+func (p *TaintPolicy) ValidateFields(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch p.NoExecute {
+	case "", NoExecutePolicyAllow, NoExecutePolicyOptIn, NoExecutePolicyForbid:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("noExecute"), p.NoExecute,
+			[]string{string(NoExecutePolicyAllow), string(NoExecutePolicyOptIn), string(NoExecutePolicyForbid)}))
+	}
+	for i, key := range p.NoExecuteKeys {
+		if strings.TrimSpace(key) == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("noExecuteKeys").Index(i), "must not be empty"))
+		}
+	}
+	if p.NoExecuteGracePeriod != nil && p.NoExecuteGracePeriod.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("noExecuteGracePeriod"), p.NoExecuteGracePeriod.Duration.String(), "must be a positive duration"))
+	}
+	if p.MaxNoExecuteNodesPercent != nil && (*p.MaxNoExecuteNodesPercent < 1 || *p.MaxNoExecuteNodesPercent > 100) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxNoExecuteNodesPercent"), *p.MaxNoExecuteNodesPercent, "must be between 1 and 100"))
+	}
+	return allErrs
+}
+
+// ValidateFields checks the key patterns of the label policy for errors and returns them.
+func (p *LabelPolicy) ValidateFields(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, patterns := range []struct {
+		name     string
+		patterns []string
+	}{{"allowed", p.Allowed}, {"denied", p.Denied}} {
+		for i, pattern := range patterns.patterns {
+			if strings.TrimSpace(pattern) == "" {
+				allErrs = append(allErrs, field.Required(fldPath.Child(patterns.name).Index(i), "must not be empty"))
+			}
+		}
+	}
+	return allErrs
+}
+
+// ValidateFields checks the layers for errors and returns them.
+func (l *K3OSConfigLayers) ValidateFields(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i := range l.Groups {
+		group, groupPath := &l.Groups[i], fldPath.Child("groups").Index(i)
+		if group.Name == "" {
+			allErrs = append(allErrs, field.Required(groupPath.Child("name"), ""))
+		}
+		if group.Key == "" {
+			allErrs = append(allErrs, field.Required(groupPath.Child("key"), ""))
+		}
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(&group.NodeSelector, groupPath.Child("nodeSelector"))...)
+	}
+	for i, strategy := range l.ListMergeStrategies {
+		strategyPath := fldPath.Child("listMergeStrategies").Index(i)
+		if strategy.Path == "" {
+			allErrs = append(allErrs, field.Required(strategyPath.Child("path"), ""))
+		}
+		switch strategy.Strategy {
+		case ListMergeAppend, ListMergeUnion, ListMergeReplace:
+		case ListMergeKeyed:
+			if strategy.MergeKey == "" {
+				allErrs = append(allErrs, field.Required(strategyPath.Child("mergeKey"), "required for the Keyed strategy"))
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(strategyPath.Child("strategy"), strategy.Strategy,
+				[]string{string(ListMergeAppend), string(ListMergeUnion), string(ListMergeReplace), string(ListMergeKeyed)}))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,130 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestK3OSConfig_ValidateCreate(t *testing.T) {
+	percent25, percent101 := int32(25), int32(101)
+	tests := []struct {
+		name    string
+		spec    K3OSConfigSpec
+		wantErr bool
+	}{
+		{
+			name: "empty spec",
+		},
+		{
+			name: "valid nodeStaleAfter",
+			spec: K3OSConfigSpec{SyncNodeLabels: true, NodeStaleAfter: &metav1.Duration{Duration: 5 * time.Minute}},
+		},
+		{
+			name:    "zero nodeStaleAfter",
+			spec:    K3OSConfigSpec{NodeStaleAfter: &metav1.Duration{}},
+			wantErr: true,
+		},
+		{
+			name: "valid layers",
+			spec: K3OSConfigSpec{Layers: &K3OSConfigLayers{
+				Base:   "base",
+				Groups: []K3OSConfigLayerGroup{{Name: "workers", Key: "workers", NodeSelector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}}}},
+				ListMergeStrategies: []ListMergeStrategy{
+					{Path: "k3os.ntp_servers", Strategy: ListMergeAppend},
+					{Path: "k3os.dns_nameservers", Strategy: ListMergeUnion},
+					{Path: "k3os.k3s_args", Strategy: ListMergeKeyed, MergeKey: "name"},
+				},
+			}},
+		},
+		{
+			name: "group without key and with an invalid selector",
+			spec: K3OSConfigSpec{Layers: &K3OSConfigLayers{
+				Groups: []K3OSConfigLayerGroup{{Name: "workers", NodeSelector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "-"}}}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "keyed list merge strategy without merge key",
+			spec: K3OSConfigSpec{Layers: &K3OSConfigLayers{
+				ListMergeStrategies: []ListMergeStrategy{{Path: "write_files", Strategy: ListMergeKeyed}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "valid label policy",
+			spec: K3OSConfigSpec{LabelPolicy: &LabelPolicy{Allowed: []string{"kubernetes.io/arch"}, Denied: []string{"*"}}},
+		},
+		{
+			name:    "label policy with an empty pattern",
+			spec:    K3OSConfigSpec{LabelPolicy: &LabelPolicy{Denied: []string{" "}}},
+			wantErr: true,
+		},
+		{
+			name: "valid taint policy",
+			spec: K3OSConfigSpec{TaintPolicy: &TaintPolicy{
+				NoExecute:                NoExecutePolicyOptIn,
+				NoExecuteKeys:            []string{"dedicated"},
+				NoExecuteGracePeriod:     &metav1.Duration{Duration: 5 * time.Minute},
+				MaxNoExecuteNodesPercent: &percent25,
+			}},
+		},
+		{
+			name:    "taint policy with an unknown NoExecute policy",
+			spec:    K3OSConfigSpec{TaintPolicy: &TaintPolicy{NoExecute: "Sometimes"}},
+			wantErr: true,
+		},
+		{
+			name:    "taint policy with a negative grace period",
+			spec:    K3OSConfigSpec{TaintPolicy: &TaintPolicy{NoExecuteGracePeriod: &metav1.Duration{Duration: -time.Minute}}},
+			wantErr: true,
+		},
+		{
+			name:    "taint policy with a percentage over 100",
+			spec:    K3OSConfigSpec{TaintPolicy: &TaintPolicy{MaxNoExecuteNodesPercent: &percent101}},
+			wantErr: true,
+		},
+		{
+			name:    "negative nodeStaleAfter",
+			spec:    K3OSConfigSpec{NodeStaleAfter: &metav1.Duration{Duration: -time.Minute}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			config := &K3OSConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}, Spec: tt.spec}
+			if err := config.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := config.ValidateUpdate(config.DeepCopy()); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -25,9 +25,12 @@ SOFTWARE.
 package v1alpha1
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 
 	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -37,24 +40,185 @@ const K3OSConfigFileKind = "K3OSConfigFile"
 // K3OSConfigFileSectionK3OS contains the spec of the `k3os` section of
 // the K3OS YAML config file.
 type K3OSConfigFileSectionK3OS struct {
-	Labels map[string]string `json:"labels" yaml:"labels"`
-	Taints []string          `json:"taints" yaml:"taints"`
+	// DataSources lists the cloud-init data sources k3OS reads its config from.
+	// +optional
+	DataSources []string `json:"dataSources,omitempty" yaml:"data_sources,omitempty"`
+	// Modules lists the kernel modules that are loaded on boot.
+	// +optional
+	Modules []string `json:"modules,omitempty" yaml:"modules,omitempty"`
+	// Sysctl contains the kernel parameters that are set on boot.
+	// +optional
+	Sysctl map[string]string `json:"sysctl,omitempty" yaml:"sysctl,omitempty"`
+	// NTPServers lists the NTP servers used by the node.
+	// +optional
+	NTPServers []string `json:"ntpServers,omitempty" yaml:"ntp_servers,omitempty"`
+	// DNSNameservers lists the DNS nameservers used by the node.
+	// +optional
+	DNSNameservers []string `json:"dnsNameservers,omitempty" yaml:"dns_nameservers,omitempty"`
+	// Wifi lists the wifi networks the node connects to.
+	// +optional
+	Wifi []K3OSConfigFileWifi `json:"wifi,omitempty" yaml:"wifi,omitempty"`
+	// Password is the password of the rancher user.
+	// +optional
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// PasswordFrom selects the key of a Secret in the namespace of the operator that contains the password
+	// of the rancher user. It takes precedence over Password and is only used by K3OSConfigFile objects.
+	// +optional
+	PasswordFrom *corev1.SecretKeySelector `json:"passwordFrom,omitempty" yaml:"-"`
+	// ServerURL is the URL of the k3s server the node joins as an agent.
+	// +optional
+	ServerURL string `json:"serverURL,omitempty" yaml:"server_url,omitempty"`
+	// Token is the cluster secret or node token used to join the cluster.
+	// +optional
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+	// TokenFrom selects the key of a Secret in the namespace of the operator that contains the cluster secret
+	// or node token. It takes precedence over Token and is only used by K3OSConfigFile objects.
+	// +optional
+	TokenFrom *corev1.SecretKeySelector `json:"tokenFrom,omitempty" yaml:"-"`
+	// Labels contains the labels of the node.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// K3sArgs lists the arguments passed to k3s.
+	// +optional
+	K3sArgs []string `json:"k3sArgs,omitempty" yaml:"k3s_args,omitempty"`
+	// Environment contains the environment variables set for k3s and other processes.
+	// +optional
+	Environment map[string]string `json:"environment,omitempty" yaml:"environment,omitempty"`
+	// Taints lists the taints of the node.
+	// +optional
+	Taints []string `json:"taints,omitempty" yaml:"taints,omitempty"`
+	// Install contains the settings used when installing k3OS.
+	// +optional
+	Install *K3OSConfigFileInstall `json:"install,omitempty" yaml:"install,omitempty"`
+
+	// Extra contains the keys of the `k3os` section that aren't modelled above.
+	Extra ExtraFields `json:"-" yaml:",inline"`
+}
+
+// K3OSConfigFileSectionOperator contains the spec of the `k3os_config_operator` section. k3OS ignores this section:
+// the operator applies it to the Node object with its own identity instead of passing it to the kubelet.
+type K3OSConfigFileSectionOperator struct {
+	// Annotations contains the annotations of the node.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	// Roles lists the roles of the node. They are set as `node-role.kubernetes.io/<role>` labels,
+	// which the kubelet refuses to set and which therefore can't be part of the `k3os.labels` section.
+	// +optional
+	Roles []string `json:"roles,omitempty" yaml:"roles,omitempty"`
+	// Unschedulable cordons (true) or uncordons (false) the node. Unless the unschedulable policy of the K3OSConfig
+	// is Enforce, nodes cordoned by someone else aren't uncordoned.
+	// +optional
+	Unschedulable *bool `json:"unschedulable,omitempty" yaml:"unschedulable,omitempty"`
+	// ProviderID is set as the provider ID of the node if it doesn't have one (it can't be changed once it is set),
+	// e.g. to reference the machine in a bare-metal inventory. The key is `providerID` in K3OSConfigFile objects
+	// and `provider_id` in the config.yaml of a node.
+	// +optional
+	ProviderID string `json:"providerID,omitempty" yaml:"provider_id,omitempty"`
+
+	// Extra contains the keys of the `k3os_config_operator` section that aren't modelled above.
+	Extra ExtraFields `json:"-" yaml:",inline"`
+}
+
+// NodeRoleLabelPrefix is the prefix of the labels that denote the roles of a node.
+const NodeRoleLabelPrefix = "node-role.kubernetes.io/"
+
+// NodeLabels returns the labels of the node: the labels of the `k3os` section and a label for every role.
+func (s *K3OSConfigFileSpec) NodeLabels() map[string]string {
+	if len(s.Operator.Roles) == 0 {
+		return s.K3OS.Labels
+	}
+	labels := make(map[string]string, len(s.K3OS.Labels)+len(s.Operator.Roles))
+	for key, value := range s.K3OS.Labels {
+		labels[key] = value
+	}
+	for _, role := range s.Operator.Roles {
+		labels[NodeRoleLabelPrefix+role] = "true"
+	}
+	return labels
+}
+
+// K3OSConfigFileWifi contains the spec of a wifi network in the `k3os.wifi` section.
+type K3OSConfigFileWifi struct {
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"`
+	Passphrase string `json:"passphrase,omitempty" yaml:"passphrase,omitempty"`
+
+	// Extra contains the keys that aren't modelled above.
+	Extra ExtraFields `json:"-" yaml:",inline"`
+}
+
+// K3OSConfigFileInstall contains the spec of the `k3os.install` section.
+type K3OSConfigFileInstall struct {
+	ForceEFI  bool   `json:"forceEFI,omitempty" yaml:"force_efi,omitempty"`
+	Device    string `json:"device,omitempty" yaml:"device,omitempty"`
+	ConfigURL string `json:"configURL,omitempty" yaml:"config_url,omitempty"`
+	Silent    bool   `json:"silent,omitempty" yaml:"silent,omitempty"`
+	ISOURL    string `json:"isoURL,omitempty" yaml:"iso_url,omitempty"`
+	PowerOff  bool   `json:"powerOff,omitempty" yaml:"power_off,omitempty"`
+	NoFormat  bool   `json:"noFormat,omitempty" yaml:"no_format,omitempty"`
+	Debug     bool   `json:"debug,omitempty" yaml:"debug,omitempty"`
+	TTY       string `json:"tty,omitempty" yaml:"tty,omitempty"`
+
+	// Extra contains the keys that aren't modelled above.
+	Extra ExtraFields `json:"-" yaml:",inline"`
+}
+
+// K3OSConfigFileWriteFile contains the spec of a file in the `write_files` section.
+type K3OSConfigFileWriteFile struct {
+	Encoding    string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+	Content     string `json:"content,omitempty" yaml:"content,omitempty"`
+	Owner       string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Path        string `json:"path,omitempty" yaml:"path,omitempty"`
+	Permissions string `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+
+	// Extra contains the keys that aren't modelled above.
+	Extra ExtraFields `json:"-" yaml:",inline"`
 }
 
 // K3OSConfigFileSpec defines the desired state of K3OSConfigFile.
 // Use `ParseConfigYAML()` to parse a k3OS config.yaml file.
 type K3OSConfigFileSpec struct {
-	Hostname string `json:"hostname" yaml:"hostname"`
+	// NodeSelector selects the nodes a K3OSConfigFile object applies to. Without it the object applies to the
+	// node with the same name. An object named after a node takes precedence over objects selecting the node.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty" yaml:"-"`
 
-	K3OS K3OSConfigFileSectionK3OS `json:"k3os" yaml:"k3os"`
+	// SSHAuthorizedKeys lists the SSH keys that are added to the rancher user.
+	// +optional
+	SSHAuthorizedKeys []string `json:"sshAuthorizedKeys,omitempty" yaml:"ssh_authorized_keys,omitempty"`
+	// WriteFiles lists the files that are written on boot.
+	// +optional
+	WriteFiles []K3OSConfigFileWriteFile `json:"writeFiles,omitempty" yaml:"write_files,omitempty"`
+	// Hostname is the hostname of the node.
+	// +optional
+	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	// InitCmd lists the commands that are run during the initrd stage.
+	// +optional
+	InitCmd []string `json:"initCmd,omitempty" yaml:"init_cmd,omitempty"`
+	// BootCmd lists the commands that are run during the boot stage.
+	// +optional
+	BootCmd []string `json:"bootCmd,omitempty" yaml:"boot_cmd,omitempty"`
+	// RunCmd lists the commands that are run after the system is fully booted.
+	// +optional
+	RunCmd []string `json:"runCmd,omitempty" yaml:"run_cmd,omitempty"`
+
+	// +optional
+	K3OS K3OSConfigFileSectionK3OS `json:"k3os,omitempty" yaml:"k3os,omitempty"`
+
+	// Operator contains the config of the node that the operator applies to the Node object itself.
+	// +optional
+	Operator K3OSConfigFileSectionOperator `json:"operator,omitempty" yaml:"k3os_config_operator,omitempty"`
+
+	// Extra contains the top-level keys that aren't modelled above.
+	Extra ExtraFields `json:"-" yaml:",inline"`
 
 	// Data contains the raw contents of the config file.
 	Data []byte `json:"-" yaml:"-"`
 }
 
-// Validate checks the contents of the spec for errors and returns them.
-func (s *K3OSConfigFileSpec) Validate() error {
-	return nil
+// Hash returns the SHA-256 hash of the raw contents of the config file.
+func (s *K3OSConfigFileSpec) Hash() string {
+	sum := sha256.Sum256(s.Data)
+	return hex.EncodeToString(sum[:])
 }
 
 // ParseConfigYAML parses the data of a k3OS config.yaml into a K3OSConfigFileSpec object.
@@ -76,7 +240,19 @@ func ParseConfigYAML(data []byte) (*K3OSConfigFileSpec, error) {
 }
 
 // K3OSConfigFileStatus defines the observed state of K3OSConfigFile.
-type K3OSConfigFileStatus struct{}
+type K3OSConfigFileStatus struct {
+	// Nodes contains the sync status of every node whose config is read from this K3OSConfigFile
+	// as reported to the K3OSConfig. It is updated by the leader.
+	// +optional
+	// +listType=map
+	// +listMapKey=nodeName
+	Nodes []K3OSConfigNodeStatus `json:"nodes,omitempty"`
+}
+
+// ConfigSource returns the config source of the nodes using this K3OSConfigFile (see K3OSConfigNodeStatus.ConfigSource).
+func (s *K3OSConfigFile) ConfigSource() string {
+	return K3OSConfigFileKind + "/" + s.GetName()
+}
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
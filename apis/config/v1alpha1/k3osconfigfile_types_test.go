@@ -0,0 +1,163 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+)
+
+const fullConfigYAML = `ssh_authorized_keys:
+- ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC2TBZGjE+J8ag11dzkFT58J3XPONrDVmalCNrKxsfADfyy0eqdZrG8hAcxAR/5zuj90Gin2uB4RSw6Cn4VHsPZcFpXyQCj1KQDADj+WcuhpXOIOY3AB0LZBly9NI0ll+8lo3QtEaoyRLtrMBhQ6Mooy2M3MTG4JNwU9o3yInuqZWf9PvtW6KxMl+ygg1xZkljhemGZ9k0wSrjqif+8usNbzVlCOVQmZwZA+BZxbdcLNwkg7zWJSXzDIXyqM6iWPGXQDEbWLq3+HR1qKucTnSxaEe8C7XbSOUZfFROZ3yjlRo6jO9gk7AZv7PNk0dC5Hb63DQ1SFcNhSxvKMSPDPhGl
+- github:annismckenzie
+write_files:
+- encoding: b64
+  content: CiMgVGhpcyBmaWxlIGNvbnRyb2xzIHRoZSBzdGF0ZSBvZiBTRUxpbnV4
+  owner: root:root
+  path: /etc/test
+  permissions: "0644"
+  append: true
+hostname: n1-node
+init_cmd:
+- echo hello init
+boot_cmd:
+- echo hello boot
+run_cmd:
+- echo hello run
+k3os:
+  data_sources:
+  - aws
+  - cdrom
+  modules:
+  - kvm
+  - nvme
+  sysctl:
+    kernel.printk: 4 4 1 7
+    kernel.kptr_restrict: 1
+  ntp_servers:
+  - 0.us.pool.ntp.org
+  dns_nameservers:
+  - 8.8.8.8
+  wifi:
+  - name: home
+    passphrase: mypassword
+  password: rancher
+  server_url: https://someserver:6443
+  token: TOKEN_VALUE
+  labels:
+    region: us-west-1
+  k3s_args:
+  - server
+  - --disable-agent
+  environment:
+    http_proxy: http://myserver
+  taints:
+  - key1=value1:NoSchedule
+  install:
+    force_efi: true
+    device: /dev/vda
+    silent: true
+    tty: ttyS0
+    unknown_install_key: value
+  unknown_k3os_key:
+  - some
+  - values
+k3os_config_operator:
+  annotations:
+    node.longhorn.io/default-disks-config: '[{"path":"/var/lib/longhorn"}]'
+  roles:
+  - worker
+  unknown_operator_key: value
+unknown_top_level_key:
+  nested:
+    key: value
+`
+
+func TestParseConfigYAML_RoundTrip(t *testing.T) {
+	spec, err := ParseConfigYAML([]byte(fullConfigYAML))
+	if err != nil {
+		t.Fatalf("ParseConfigYAML() error = %v", err)
+	}
+
+	if spec.Hostname != "n1-node" || len(spec.SSHAuthorizedKeys) != 2 || len(spec.WriteFiles) != 1 || len(spec.RunCmd) != 1 {
+		t.Errorf("ParseConfigYAML() did not parse the top-level fields: %+v", spec)
+	}
+	if spec.WriteFiles[0].Permissions != "0644" || spec.WriteFiles[0].Extra["append"] != true {
+		t.Errorf("ParseConfigYAML() did not parse write_files: %+v", spec.WriteFiles[0])
+	}
+	if spec.K3OS.Sysctl["kernel.kptr_restrict"] != "1" || spec.K3OS.ServerURL != "https://someserver:6443" || spec.K3OS.Token != "TOKEN_VALUE" {
+		t.Errorf("ParseConfigYAML() did not parse the k3os section: %+v", spec.K3OS)
+	}
+	if spec.K3OS.Install == nil || !spec.K3OS.Install.ForceEFI || spec.K3OS.Install.Extra["unknown_install_key"] != "value" {
+		t.Errorf("ParseConfigYAML() did not parse the k3os.install section: %+v", spec.K3OS.Install)
+	}
+	if len(spec.Operator.Annotations) != 1 || !reflect.DeepEqual(spec.Operator.Roles, []string{"worker"}) {
+		t.Errorf("ParseConfigYAML() did not parse the k3os_config_operator section: %+v", spec.Operator)
+	}
+	if keys := spec.Extra.Keys(); !reflect.DeepEqual(keys, []string{"unknown_top_level_key"}) {
+		t.Errorf("ParseConfigYAML() unknown top-level keys = %v", keys)
+	}
+	if keys := spec.K3OS.Extra.Keys(); !reflect.DeepEqual(keys, []string{"unknown_k3os_key"}) {
+		t.Errorf("ParseConfigYAML() unknown k3os keys = %v", keys)
+	}
+	if keys := spec.Operator.Extra.Keys(); !reflect.DeepEqual(keys, []string{"unknown_operator_key"}) {
+		t.Errorf("ParseConfigYAML() unknown k3os_config_operator keys = %v", keys)
+	}
+
+	data, err := (&K3OSConfigFile{Spec: *spec}).MarshalYAML()
+	if err != nil {
+		t.Fatalf("MarshalYAML() error = %v", err)
+	}
+	roundTripped, err := ParseConfigYAML(data)
+	if err != nil {
+		t.Fatalf("ParseConfigYAML() of marshaled data error = %v", err)
+	}
+	roundTripped.Data, spec.Data = nil, nil
+	if !reflect.DeepEqual(spec, roundTripped) {
+		t.Errorf("round-tripped spec differs:\n%+v\n%+v", spec, roundTripped)
+	}
+
+	copied := spec.DeepCopy()
+	copied.Extra["unknown_top_level_key"].(map[string]interface{})["nested"].(map[string]interface{})["key"] = "changed"
+	if spec.Extra["unknown_top_level_key"].(map[string]interface{})["nested"].(map[string]interface{})["key"] != "value" {
+		t.Error("DeepCopy() did not copy the unknown keys")
+	}
+}
+
+func TestK3OSConfigFileSpec_NodeLabels(t *testing.T) {
+	spec := &K3OSConfigFileSpec{K3OS: K3OSConfigFileSectionK3OS{Labels: map[string]string{"region": "shelf-1"}}}
+	if labels := spec.NodeLabels(); !reflect.DeepEqual(labels, spec.K3OS.Labels) {
+		t.Errorf("NodeLabels() = %v, want %v", labels, spec.K3OS.Labels)
+	}
+
+	spec.Operator.Roles = []string{"worker", "storage"}
+	want := map[string]string{"region": "shelf-1", "node-role.kubernetes.io/worker": "true", "node-role.kubernetes.io/storage": "true"}
+	if labels := spec.NodeLabels(); !reflect.DeepEqual(labels, want) {
+		t.Errorf("NodeLabels() = %v, want %v", labels, want)
+	}
+	if len(spec.K3OS.Labels) != 1 {
+		t.Errorf("NodeLabels() modified the labels of the k3os section: %v", spec.K3OS.Labels)
+	}
+}
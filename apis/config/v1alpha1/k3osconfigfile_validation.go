@@ -0,0 +1,223 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/util/taints"
+	"golang.org/x/crypto/ssh"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// githubSSHKeyPrefix denotes SSH keys that k3OS fetches from GitHub, e.g. `github:username`.
+const githubSSHKeyPrefix = "github:"
+
+var (
+	// sysctlRegexp matches well-formed sysctl keys (same format as validated by Kubernetes for pod sysctls).
+	sysctlRegexp = regexp.MustCompile(`^([a-z0-9]([-_a-z0-9]*[a-z0-9])?[\./])*[a-z0-9]([-_a-z0-9]*[a-z0-9])?$`)
+	// githubUsernameRegexp matches valid GitHub usernames.
+	githubUsernameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,37}[a-zA-Z0-9])?$`)
+)
+
+// sysctlMaxLength is the maximum length of a sysctl key.
+const sysctlMaxLength = 253
+
+// operatorAnnotationPrefix is the prefix of the node annotations the operator uses itself.
+var operatorAnnotationPrefix = "k3osconfigs." + GroupVersion.Group + "/"
+
+// Validate checks the contents of the spec for errors and returns them.
+// The returned error aggregates all errors, each of them prefixed with the path of the invalid field.
+func (s *K3OSConfigFileSpec) Validate() error {
+	return s.ValidateFields().ToAggregate()
+}
+
+// ValidateFields checks the contents of the spec for errors and returns them.
+// Field paths use the keys of the k3OS config.yaml.
+func (s *K3OSConfigFileSpec) ValidateFields() field.ErrorList {
+	var allErrs field.ErrorList
+
+	if s.Hostname != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(s.Hostname) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("hostname"), s.Hostname, msg))
+		}
+	}
+
+	sshKeysPath := field.NewPath("ssh_authorized_keys")
+	for i, key := range s.SSHAuthorizedKeys {
+		allErrs = append(allErrs, validateSSHAuthorizedKey(key, sshKeysPath.Index(i))...)
+	}
+
+	allErrs = append(allErrs, s.K3OS.validateFields(field.NewPath("k3os"))...)
+	allErrs = append(allErrs, s.Operator.validateFields(field.NewPath("k3os_config_operator"))...)
+	return allErrs
+}
+
+// Warnings returns warnings about the contents of the spec, e.g. about keys that k3OS doesn't know.
+func (s *K3OSConfigFileSpec) Warnings() []string {
+	var warnings []string
+	for _, key := range s.Extra.Keys() {
+		warnings = append(warnings, unknownKeyWarning(nil, key, s))
+	}
+	for _, key := range s.K3OS.Extra.Keys() {
+		warnings = append(warnings, unknownKeyWarning(field.NewPath("k3os"), key, &s.K3OS))
+	}
+	for _, key := range s.Operator.Extra.Keys() {
+		warnings = append(warnings, unknownKeyWarning(field.NewPath("k3os_config_operator"), key, &s.Operator))
+	}
+	return warnings
+}
+
+// unknownKeyWarning returns the warning about an unknown key of a section. If the key is the name of one of the
+// section's fields in K3OSConfigFile objects (e.g. providerID), the warning names the key used in config.yaml.
+func unknownKeyWarning(fldPath *field.Path, key string, section interface{}) string {
+	path := key
+	if fldPath != nil {
+		path = fldPath.Child(key).String()
+	}
+	t := reflect.TypeOf(section).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		jsonKey := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		yamlKey := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if jsonKey == key && yamlKey != "" && yamlKey != "-" && yamlKey != key {
+			return fmt.Sprintf("unknown key %q, did you mean %q?", path, strings.TrimSuffix(path, key)+yamlKey)
+		}
+	}
+	return fmt.Sprintf("unknown key %q", path)
+}
+
+func (s *K3OSConfigFileSectionK3OS) validateFields(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	labelsPath := fldPath.Child("labels")
+	for _, key := range sortedKeys(s.Labels) {
+		value := s.Labels[key]
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(labelsPath, key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			allErrs = append(allErrs, field.Invalid(labelsPath.Key(key), value, msg))
+		}
+	}
+
+	taintsPath := fldPath.Child("taints")
+	for i, taint := range s.Taints {
+		_, taintsToRemove, err := taints.ParseTaints([]string{taint})
+		switch {
+		case err != nil:
+			allErrs = append(allErrs, field.Invalid(taintsPath.Index(i), taint, err.Error()))
+		case len(taintsToRemove) > 0:
+			allErrs = append(allErrs, field.Invalid(taintsPath.Index(i), taint, "removing taints is not supported, remove the taint from the list instead"))
+		}
+	}
+	if len(allErrs) == 0 { // this additionally catches duplicate taints
+		if _, _, err := taints.ParseTaints(s.Taints); err != nil {
+			allErrs = append(allErrs, field.Invalid(taintsPath, s.Taints, err.Error()))
+		}
+	}
+
+	sysctlPath := fldPath.Child("sysctl")
+	for _, key := range sortedKeys(s.Sysctl) {
+		if len(key) > sysctlMaxLength || !sysctlRegexp.MatchString(key) {
+			allErrs = append(allErrs, field.Invalid(sysctlPath, key, "must be a well-formed sysctl key, e.g. 'kernel.printk'"))
+		}
+	}
+
+	if s.ServerURL != "" {
+		allErrs = append(allErrs, validateURL(s.ServerURL, fldPath.Child("server_url"))...)
+	}
+
+	return allErrs
+}
+
+func (s *K3OSConfigFileSectionOperator) validateFields(fldPath *field.Path) field.ErrorList {
+	annotationsPath := fldPath.Child("annotations")
+	allErrs := apivalidation.ValidateAnnotations(s.Annotations, annotationsPath)
+	for _, key := range sortedKeys(s.Annotations) {
+		if strings.HasPrefix(key, operatorAnnotationPrefix) {
+			allErrs = append(allErrs, field.Invalid(annotationsPath, key, "annotations with the prefix "+operatorAnnotationPrefix+" are reserved for the operator"))
+		}
+	}
+
+	rolesPath := fldPath.Child("roles")
+	roles := map[string]struct{}{}
+	for i, role := range s.Roles {
+		if _, ok := roles[role]; ok {
+			allErrs = append(allErrs, field.Duplicate(rolesPath.Index(i), role))
+			continue
+		}
+		roles[role] = struct{}{}
+		if role == "" {
+			allErrs = append(allErrs, field.Required(rolesPath.Index(i), ""))
+			continue
+		}
+		for _, msg := range validation.IsQualifiedName(NodeRoleLabelPrefix + role) {
+			allErrs = append(allErrs, field.Invalid(rolesPath.Index(i), role, msg))
+		}
+	}
+	return allErrs
+}
+
+func validateSSHAuthorizedKey(key string, fldPath *field.Path) field.ErrorList {
+	if strings.HasPrefix(key, githubSSHKeyPrefix) {
+		if username := strings.TrimPrefix(key, githubSSHKeyPrefix); !githubUsernameRegexp.MatchString(username) {
+			return field.ErrorList{field.Invalid(fldPath, key, "must be a valid GitHub username after the 'github:' prefix")}
+		}
+		return nil
+	}
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key)); err != nil {
+		return field.ErrorList{field.Invalid(fldPath, key, fmt.Sprintf("must be a valid SSH public key or 'github:<username>': %v", err))}
+	}
+	return nil
+}
+
+func validateURL(rawURL string, fldPath *field.Path) field.ErrorList {
+	u, err := url.Parse(rawURL)
+	switch {
+	case err != nil:
+		return field.ErrorList{field.Invalid(fldPath, rawURL, err.Error())}
+	case u.Scheme != "https" && u.Scheme != "http":
+		return field.ErrorList{field.Invalid(fldPath, rawURL, "must be an http or https URL")}
+	case u.Host == "":
+		return field.ErrorList{field.Invalid(fldPath, rawURL, "must contain a host")}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
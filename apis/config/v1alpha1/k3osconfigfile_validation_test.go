@@ -0,0 +1,169 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestK3OSConfigFileSpec_Validate(t *testing.T) {
+	validSSHKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGB7fPjbmBBbvDTSXw2cu2jyX7EfI4XqhnLJKAh3yXtP user@host"
+
+	tests := []struct {
+		name           string
+		spec           K3OSConfigFileSpec
+		expectedFields []string // paths of the invalid fields in order
+	}{
+		{
+			name: "empty spec",
+		},
+		{
+			name: "valid spec",
+			spec: K3OSConfigFileSpec{
+				Hostname:          "n1-node.example.com",
+				SSHAuthorizedKeys: []string{validSSHKey, "github:annismckenzie"},
+				K3OS: K3OSConfigFileSectionK3OS{
+					Labels:    map[string]string{"topology.kubernetes.io/zone": "shelf-1", "plain": ""},
+					Taints:    []string{"key1=value1:NoSchedule", "key2:NoExecute"},
+					Sysctl:    map[string]string{"kernel.printk": "4 4 1 7", "net/ipv4/ip_forward": "1"},
+					ServerURL: "https://10.0.0.1:6443",
+				},
+				Operator: K3OSConfigFileSectionOperator{
+					Annotations: map[string]string{"node.longhorn.io/default-disks-config": "[]"},
+					Roles:       []string{"worker", "storage"},
+				},
+			},
+		},
+		{
+			name:           "invalid hostname",
+			spec:           K3OSConfigFileSpec{Hostname: "N1_node"},
+			expectedFields: []string{"hostname"},
+		},
+		{
+			name:           "invalid SSH keys",
+			spec:           K3OSConfigFileSpec{SSHAuthorizedKeys: []string{validSSHKey, "ssh-rsa notbase64", "github:-invalid-"}},
+			expectedFields: []string{"ssh_authorized_keys[1]", "ssh_authorized_keys[2]"},
+		},
+		{
+			name: "invalid label keys and values",
+			spec: K3OSConfigFileSpec{K3OS: K3OSConfigFileSectionK3OS{
+				Labels: map[string]string{"invalid key": "value", "key": "invalid value"},
+			}},
+			expectedFields: []string{"k3os.labels", "k3os.labels[key]"},
+		},
+		{
+			name: "invalid taints and removal-only taints",
+			spec: K3OSConfigFileSpec{K3OS: K3OSConfigFileSectionK3OS{
+				Taints: []string{"key1=value1:NoSchedule", "key2:InvalidEffect", "key1:NoSchedule-", "key3-"},
+			}},
+			expectedFields: []string{"k3os.taints[1]", "k3os.taints[2]", "k3os.taints[3]"},
+		},
+		{
+			name: "duplicate taints",
+			spec: K3OSConfigFileSpec{K3OS: K3OSConfigFileSectionK3OS{
+				Taints: []string{"key1=value1:NoSchedule", "key1=value2:NoSchedule"},
+			}},
+			expectedFields: []string{"k3os.taints"},
+		},
+		{
+			name: "invalid sysctl keys",
+			spec: K3OSConfigFileSpec{K3OS: K3OSConfigFileSectionK3OS{
+				Sysctl: map[string]string{"kernel..printk": "1", "Kernel.Printk": "1", "vm.swappiness": "10"},
+			}},
+			expectedFields: []string{"k3os.sysctl", "k3os.sysctl"},
+		},
+		{
+			name:           "invalid server URLs",
+			spec:           K3OSConfigFileSpec{K3OS: K3OSConfigFileSectionK3OS{ServerURL: "10.0.0.1:6443"}},
+			expectedFields: []string{"k3os.server_url"},
+		},
+		{
+			name:           "server URL without host",
+			spec:           K3OSConfigFileSpec{K3OS: K3OSConfigFileSectionK3OS{ServerURL: "https://"}},
+			expectedFields: []string{"k3os.server_url"},
+		},
+		{
+			name: "invalid and reserved annotation keys",
+			spec: K3OSConfigFileSpec{Operator: K3OSConfigFileSectionOperator{
+				Annotations: map[string]string{"invalid key": "value", "k3osconfigs." + GroupVersion.Group + "/labelsAdded": "a"},
+			}},
+			expectedFields: []string{"k3os_config_operator.annotations", "k3os_config_operator.annotations"},
+		},
+		{
+			name: "invalid, empty and duplicate roles",
+			spec: K3OSConfigFileSpec{Operator: K3OSConfigFileSectionOperator{
+				Roles: []string{"worker", "in valid", "", "worker"},
+			}},
+			expectedFields: []string{"k3os_config_operator.roles[1]", "k3os_config_operator.roles[2]", "k3os_config_operator.roles[3]"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.spec.ValidateFields()
+			var fields []string
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.expectedFields) {
+				t.Errorf("ValidateFields() invalid fields = %v, want %v (errors: %v)", fields, tt.expectedFields, errs)
+			}
+			if err := tt.spec.Validate(); (err != nil) != (len(tt.expectedFields) > 0) {
+				t.Errorf("Validate() error = %v, want error: %v", err, len(tt.expectedFields) > 0)
+			}
+		})
+	}
+}
+
+func TestK3OSConfigFileSpec_Warnings(t *testing.T) {
+	spec, err := ParseConfigYAML([]byte(fullConfigYAML))
+	if err != nil {
+		t.Fatalf("ParseConfigYAML() error = %v", err)
+	}
+	expected := []string{
+		`unknown key "unknown_top_level_key"`,
+		`unknown key "k3os.unknown_k3os_key"`,
+		`unknown key "k3os_config_operator.unknown_operator_key"`,
+	}
+	if warnings := spec.Warnings(); !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("Warnings() = %v, want %v", warnings, expected)
+	}
+}
+
+func TestK3OSConfigFileSpec_Warnings_keysOfK3OSConfigFileObjects(t *testing.T) {
+	spec, err := ParseConfigYAML([]byte("sshAuthorizedKeys:\n- github:annismckenzie\nk3os:\n  ntpServers:\n  - 0.pool.ntp.org\nk3os_config_operator:\n  providerID: k3os://home/n1\n"))
+	if err != nil {
+		t.Fatalf("ParseConfigYAML() error = %v", err)
+	}
+	expected := []string{
+		`unknown key "sshAuthorizedKeys", did you mean "ssh_authorized_keys"?`,
+		`unknown key "k3os.ntpServers", did you mean "k3os.ntp_servers"?`,
+		`unknown key "k3os_config_operator.providerID", did you mean "k3os_config_operator.provider_id"?`,
+	}
+	if warnings := spec.Warnings(); !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("Warnings() = %v, want %v", warnings, expected)
+	}
+}
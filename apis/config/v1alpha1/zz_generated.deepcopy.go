@@ -30,16 +30,38 @@ SOFTWARE.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigFileDrift) DeepCopyInto(out *ConfigFileDrift) {
+	*out = *in
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigFileDrift.
+func (in *ConfigFileDrift) DeepCopy() *ConfigFileDrift {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigFileDrift)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K3OSConfig) DeepCopyInto(out *K3OSConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfig.
@@ -66,7 +88,7 @@ func (in *K3OSConfigFile) DeepCopyInto(out *K3OSConfigFile) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigFile.
@@ -87,6 +109,22 @@ func (in *K3OSConfigFile) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3OSConfigFileInstall) DeepCopyInto(out *K3OSConfigFileInstall) {
+	*out = *in
+	out.Extra = in.Extra.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigFileInstall.
+func (in *K3OSConfigFileInstall) DeepCopy() *K3OSConfigFileInstall {
+	if in == nil {
+		return nil
+	}
+	out := new(K3OSConfigFileInstall)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K3OSConfigFileList) DeepCopyInto(out *K3OSConfigFileList) {
 	*out = *in
@@ -122,6 +160,50 @@ func (in *K3OSConfigFileList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K3OSConfigFileSectionK3OS) DeepCopyInto(out *K3OSConfigFileSectionK3OS) {
 	*out = *in
+	if in.DataSources != nil {
+		in, out := &in.DataSources, &out.DataSources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Modules != nil {
+		in, out := &in.Modules, &out.Modules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Sysctl != nil {
+		in, out := &in.Sysctl, &out.Sysctl
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NTPServers != nil {
+		in, out := &in.NTPServers, &out.NTPServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DNSNameservers != nil {
+		in, out := &in.DNSNameservers, &out.DNSNameservers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Wifi != nil {
+		in, out := &in.Wifi, &out.Wifi
+		*out = make([]K3OSConfigFileWifi, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PasswordFrom != nil {
+		in, out := &in.PasswordFrom, &out.PasswordFrom
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TokenFrom != nil {
+		in, out := &in.TokenFrom, &out.TokenFrom
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Labels != nil {
 		in, out := &in.Labels, &out.Labels
 		*out = make(map[string]string, len(*in))
@@ -129,11 +211,29 @@ func (in *K3OSConfigFileSectionK3OS) DeepCopyInto(out *K3OSConfigFileSectionK3OS
 			(*out)[key] = val
 		}
 	}
+	if in.K3sArgs != nil {
+		in, out := &in.K3sArgs, &out.K3sArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Environment != nil {
+		in, out := &in.Environment, &out.Environment
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Taints != nil {
 		in, out := &in.Taints, &out.Taints
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Install != nil {
+		in, out := &in.Install, &out.Install
+		*out = new(K3OSConfigFileInstall)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Extra = in.Extra.DeepCopy()
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigFileSectionK3OS.
@@ -146,10 +246,77 @@ func (in *K3OSConfigFileSectionK3OS) DeepCopy() *K3OSConfigFileSectionK3OS {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3OSConfigFileSectionOperator) DeepCopyInto(out *K3OSConfigFileSectionOperator) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Unschedulable != nil {
+		in, out := &in.Unschedulable, &out.Unschedulable
+		*out = new(bool)
+		**out = **in
+	}
+	out.Extra = in.Extra.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigFileSectionOperator.
+func (in *K3OSConfigFileSectionOperator) DeepCopy() *K3OSConfigFileSectionOperator {
+	if in == nil {
+		return nil
+	}
+	out := new(K3OSConfigFileSectionOperator)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K3OSConfigFileSpec) DeepCopyInto(out *K3OSConfigFileSpec) {
 	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SSHAuthorizedKeys != nil {
+		in, out := &in.SSHAuthorizedKeys, &out.SSHAuthorizedKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.WriteFiles != nil {
+		in, out := &in.WriteFiles, &out.WriteFiles
+		*out = make([]K3OSConfigFileWriteFile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InitCmd != nil {
+		in, out := &in.InitCmd, &out.InitCmd
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BootCmd != nil {
+		in, out := &in.BootCmd, &out.BootCmd
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RunCmd != nil {
+		in, out := &in.RunCmd, &out.RunCmd
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.K3OS.DeepCopyInto(&out.K3OS)
+	in.Operator.DeepCopyInto(&out.Operator)
+	out.Extra = in.Extra.DeepCopy()
 	if in.Data != nil {
 		in, out := &in.Data, &out.Data
 		*out = make([]byte, len(*in))
@@ -170,6 +337,13 @@ func (in *K3OSConfigFileSpec) DeepCopy() *K3OSConfigFileSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K3OSConfigFileStatus) DeepCopyInto(out *K3OSConfigFileStatus) {
 	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]K3OSConfigNodeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigFileStatus.
@@ -182,6 +356,81 @@ func (in *K3OSConfigFileStatus) DeepCopy() *K3OSConfigFileStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3OSConfigFileWifi) DeepCopyInto(out *K3OSConfigFileWifi) {
+	*out = *in
+	out.Extra = in.Extra.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigFileWifi.
+func (in *K3OSConfigFileWifi) DeepCopy() *K3OSConfigFileWifi {
+	if in == nil {
+		return nil
+	}
+	out := new(K3OSConfigFileWifi)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3OSConfigFileWriteFile) DeepCopyInto(out *K3OSConfigFileWriteFile) {
+	*out = *in
+	out.Extra = in.Extra.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigFileWriteFile.
+func (in *K3OSConfigFileWriteFile) DeepCopy() *K3OSConfigFileWriteFile {
+	if in == nil {
+		return nil
+	}
+	out := new(K3OSConfigFileWriteFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3OSConfigLayerGroup) DeepCopyInto(out *K3OSConfigLayerGroup) {
+	*out = *in
+	in.NodeSelector.DeepCopyInto(&out.NodeSelector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigLayerGroup.
+func (in *K3OSConfigLayerGroup) DeepCopy() *K3OSConfigLayerGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(K3OSConfigLayerGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3OSConfigLayers) DeepCopyInto(out *K3OSConfigLayers) {
+	*out = *in
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]K3OSConfigLayerGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ListMergeStrategies != nil {
+		in, out := &in.ListMergeStrategies, &out.ListMergeStrategies
+		*out = make([]ListMergeStrategy, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigLayers.
+func (in *K3OSConfigLayers) DeepCopy() *K3OSConfigLayers {
+	if in == nil {
+		return nil
+	}
+	out := new(K3OSConfigLayers)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K3OSConfigList) DeepCopyInto(out *K3OSConfigList) {
 	*out = *in
@@ -214,9 +463,168 @@ func (in *K3OSConfigList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3OSConfigNodeStatus) DeepCopyInto(out *K3OSConfigNodeStatus) {
+	*out = *in
+	if in.UpdatedLabels != nil {
+		in, out := &in.UpdatedLabels, &out.UpdatedLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SkippedLabels != nil {
+		in, out := &in.SkippedLabels, &out.SkippedLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UpdatedTaints != nil {
+		in, out := &in.UpdatedTaints, &out.UpdatedTaints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SkippedTaints != nil {
+		in, out := &in.SkippedTaints, &out.SkippedTaints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DelayedTaints != nil {
+		in, out := &in.DelayedTaints, &out.DelayedTaints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UpdatedAnnotations != nil {
+		in, out := &in.UpdatedAnnotations, &out.UpdatedAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.UpdatedSpecFields != nil {
+		in, out := &in.UpdatedSpecFields, &out.UpdatedSpecFields
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.BootTimeChanges != nil {
+		in, out := &in.BootTimeChanges, &out.BootTimeChanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConfigFileDrift != nil {
+		in, out := &in.ConfigFileDrift, &out.ConfigFileDrift
+		*out = new(ConfigFileDrift)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Planned != nil {
+		in, out := &in.Planned, &out.Planned
+		*out = new(K3OSConfigPlannedChanges)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OwnershipErrors != nil {
+		in, out := &in.OwnershipErrors, &out.OwnershipErrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulSyncTime != nil {
+		in, out := &in.LastSuccessfulSyncTime, &out.LastSuccessfulSyncTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigNodeStatus.
+func (in *K3OSConfigNodeStatus) DeepCopy() *K3OSConfigNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(K3OSConfigNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3OSConfigPlannedChanges) DeepCopyInto(out *K3OSConfigPlannedChanges) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Taints != nil {
+		in, out := &in.Taints, &out.Taints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SpecFields != nil {
+		in, out := &in.SpecFields, &out.SpecFields
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.BootTimeChanges != nil {
+		in, out := &in.BootTimeChanges, &out.BootTimeChanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigPlannedChanges.
+func (in *K3OSConfigPlannedChanges) DeepCopy() *K3OSConfigPlannedChanges {
+	if in == nil {
+		return nil
+	}
+	out := new(K3OSConfigPlannedChanges)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K3OSConfigSpec) DeepCopyInto(out *K3OSConfigSpec) {
 	*out = *in
+	if in.LabelPolicy != nil {
+		in, out := &in.LabelPolicy, &out.LabelPolicy
+		*out = new(LabelPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TaintPolicy != nil {
+		in, out := &in.TaintPolicy, &out.TaintPolicy
+		*out = new(TaintPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeStaleAfter != nil {
+		in, out := &in.NodeStaleAfter, &out.NodeStaleAfter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Layers != nil {
+		in, out := &in.Layers, &out.Layers
+		*out = new(K3OSConfigLayers)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Templating != nil {
+		in, out := &in.Templating, &out.Templating
+		*out = new(K3OSConfigTemplating)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigSpec.
@@ -232,6 +640,21 @@ func (in *K3OSConfigSpec) DeepCopy() *K3OSConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K3OSConfigStatus) DeepCopyInto(out *K3OSConfigStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.Summary = in.Summary
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]K3OSConfigNodeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigStatus.
@@ -243,3 +666,103 @@ func (in *K3OSConfigStatus) DeepCopy() *K3OSConfigStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3OSConfigSyncSummary) DeepCopyInto(out *K3OSConfigSyncSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigSyncSummary.
+func (in *K3OSConfigSyncSummary) DeepCopy() *K3OSConfigSyncSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(K3OSConfigSyncSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3OSConfigTemplating) DeepCopyInto(out *K3OSConfigTemplating) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3OSConfigTemplating.
+func (in *K3OSConfigTemplating) DeepCopy() *K3OSConfigTemplating {
+	if in == nil {
+		return nil
+	}
+	out := new(K3OSConfigTemplating)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabelPolicy) DeepCopyInto(out *LabelPolicy) {
+	*out = *in
+	if in.Allowed != nil {
+		in, out := &in.Allowed, &out.Allowed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Denied != nil {
+		in, out := &in.Denied, &out.Denied
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabelPolicy.
+func (in *LabelPolicy) DeepCopy() *LabelPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(LabelPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListMergeStrategy) DeepCopyInto(out *ListMergeStrategy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListMergeStrategy.
+func (in *ListMergeStrategy) DeepCopy() *ListMergeStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(ListMergeStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaintPolicy) DeepCopyInto(out *TaintPolicy) {
+	*out = *in
+	if in.NoExecuteKeys != nil {
+		in, out := &in.NoExecuteKeys, &out.NoExecuteKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NoExecuteGracePeriod != nil {
+		in, out := &in.NoExecuteGracePeriod, &out.NoExecuteGracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxNoExecuteNodesPercent != nil {
+		in, out := &in.MaxNoExecuteNodesPercent, &out.MaxNoExecuteNodesPercent
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaintPolicy.
+func (in *TaintPolicy) DeepCopy() *TaintPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(TaintPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
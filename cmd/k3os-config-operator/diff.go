@@ -0,0 +1,107 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodeconfig"
+	"github.com/annismckenzie/k3os-config-operator/pkg/util/diff"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// diffCommand shows the differences between the node config Secret in the cluster and the node config files in a directory.
+type diffCommand struct {
+	SecretOptions
+	ClusterOptions `group:"Cluster Options"`
+
+	ShowSecrets bool `long:"show-secrets" description:"Show the values of passwords, tokens and passphrases instead of redacting them."`
+
+	Args directoryArgs `positional-args:"yes" required:"yes"`
+}
+
+// Execute runs the diff command. It returns errDifferent if there are differences.
+func (c *diffCommand) Execute([]string) error {
+	entries, err := readNodeConfigs(os.Stderr, c.Args.Directory)
+	if err != nil {
+		return err
+	}
+	clientset, _, err := c.clients()
+	if err != nil {
+		return err
+	}
+	current := map[string][]byte{}
+	secret, err := clientset.CoreV1().Secrets(c.Namespace).Get(context.Background(), c.Name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		current = secret.Data
+	case !apierrors.IsNotFound(err):
+		return err
+	}
+	if writeDiff(os.Stdout, current, nodeconfig.Data(entries), c.ShowSecrets) {
+		return errDifferent
+	}
+	return nil
+}
+
+// writeDiff writes the unified diff of every entry of the node config Secret that differs between the cluster and
+// the directory. Secret fields are redacted unless showSecrets is set. It returns whether there are differences.
+func writeDiff(out io.Writer, current, desired map[string][]byte, showSecrets bool) bool {
+	keys := make([]string, 0, len(current)+len(desired))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	for key := range desired {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var different bool
+	for _, key := range keys {
+		a, b := current[key], desired[key]
+		if bytes.Equal(a, b) {
+			continue
+		}
+		different = true
+		fromName, toName := "cluster/"+key, "local/"+key
+		if !showSecrets {
+			a, b = nodeconfig.RedactLines(a), nodeconfig.RedactLines(b)
+		}
+		if unified := diff.Unified(fromName, toName, a, b); unified != "" {
+			fmt.Fprint(out, unified)
+		} else {
+			fmt.Fprintf(out, "--- %s\n+++ %s\n(only redacted fields differ, use --show-secrets to show them)\n", fromName, toName)
+		}
+	}
+	return different
+}
@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		current       map[string][]byte
+		desired       map[string][]byte
+		showSecrets   bool
+		wantDifferent bool
+		want          []string
+		wantNot       []string
+	}{
+		{
+			name:    "equal",
+			current: map[string][]byte{"n1": []byte("hostname: n1\n")},
+			desired: map[string][]byte{"n1": []byte("hostname: n1\n")},
+		},
+		{
+			name:          "added, changed and removed entries",
+			current:       map[string][]byte{"n1": []byte("hostname: n1\n"), "n2": []byte("hostname: n2\n")},
+			desired:       map[string][]byte{"n1": []byte("hostname: node1\n"), "n3": []byte("hostname: n3\n")},
+			wantDifferent: true,
+			want: []string{
+				"--- cluster/n1\n+++ local/n1\n", "-hostname: n1\n+hostname: node1\n",
+				"--- cluster/n2\n+++ local/n2\n", "-hostname: n2\n",
+				"--- cluster/n3\n+++ local/n3\n", "+hostname: n3\n",
+			},
+		},
+		{
+			name:          "secrets are redacted",
+			current:       map[string][]byte{"n1": []byte("k3os:\n  token: secret1\n")},
+			desired:       map[string][]byte{"n1": []byte("k3os:\n  token: secret2\n")},
+			wantDifferent: true,
+			want:          []string{"only redacted fields differ"},
+			wantNot:       []string{"secret1", "secret2"},
+		},
+		{
+			name:          "secrets are shown",
+			current:       map[string][]byte{"n1": []byte("k3os:\n  token: secret1\n")},
+			desired:       map[string][]byte{"n1": []byte("k3os:\n  token: secret2\n")},
+			showSecrets:   true,
+			wantDifferent: true,
+			want:          []string{"-  token: secret1\n+  token: secret2\n"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if different := writeDiff(out, tt.current, tt.desired, tt.showSecrets); different != tt.wantDifferent {
+				t.Errorf("writeDiff() = %v, want %v", different, tt.wantDifferent)
+			}
+			if !tt.wantDifferent && out.Len() > 0 {
+				t.Errorf("writeDiff() wrote %q, want nothing", out.String())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("writeDiff() wrote %q, want it to contain %q", out.String(), want)
+				}
+			}
+			for _, wantNot := range tt.wantNot {
+				if strings.Contains(out.String(), wantNot) {
+					t.Errorf("writeDiff() wrote %q, want it not to contain %q", out.String(), wantNot)
+				}
+			}
+		})
+	}
+}
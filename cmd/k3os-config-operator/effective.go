@@ -0,0 +1,305 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodeconfig"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// effectiveCommand prints the config of every node merged from its layers and rendered from its templates.
+type effectiveCommand struct {
+	Namespace      string `long:"namespace" default:"k3os-config-operator-system" description:"Namespace the operator is running in." short:"n"`
+	ClusterOptions `group:"Cluster Options"`
+
+	K3OSConfig  string   `long:"k3osconfig"   description:"Path to a file containing the K3OSConfig whose layers and templating are used (read from the cluster with --cluster)."`
+	Cluster     bool     `long:"cluster"      description:"Read the nodes, the K3OSConfig, the K3OSConfigFile objects and the template values from the cluster."`
+	Nodes       []string `long:"node"         description:"Only print the config of this node (can be repeated)."`
+	ShowSecrets bool     `long:"show-secrets" description:"Show the values of passwords, tokens and passphrases instead of redacting them."`
+
+	Args directoryArgs `positional-args:"yes" required:"yes"`
+}
+
+// Execute runs the effective command.
+func (c *effectiveCommand) Execute([]string) error {
+	entries, err := readNodeConfigs(os.Stderr, c.Args.Directory)
+	if err != nil {
+		return err
+	}
+	source := &nodeConfigSource{data: nodeconfig.Data(entries)}
+	if c.K3OSConfig != "" {
+		data, err := os.ReadFile(c.K3OSConfig)
+		if err != nil {
+			return err
+		}
+		k3OSConfig := &configv1alpha1.K3OSConfig{}
+		if err = yaml.UnmarshalStrict(data, k3OSConfig); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", c.K3OSConfig, err)
+		}
+		source.spec = &k3OSConfig.Spec
+	}
+
+	var nodeList []*corev1.Node
+	if c.Cluster {
+		if nodeList, err = c.readCluster(context.Background(), source); err != nil {
+			return err
+		}
+	} else {
+		nodeList = source.nodes()
+	}
+	if len(c.Nodes) > 0 {
+		nodeList = selectNodes(nodeList, c.Nodes)
+	}
+	return writeEffectiveConfigs(os.Stdout, os.Stderr, source, nodeList, c.ShowSecrets)
+}
+
+// readCluster reads the K3OSConfig (unless it was read from a file), the K3OSConfigFile objects and the template
+// values from the namespace of the operator into the source and returns the nodes of the cluster.
+func (c *effectiveCommand) readCluster(ctx context.Context, source *nodeConfigSource) ([]*corev1.Node, error) {
+	clientset, k8sClient, err := c.clients()
+	if err != nil {
+		return nil, err
+	}
+	if source.spec == nil {
+		k3OSConfigs := &configv1alpha1.K3OSConfigList{}
+		if err = k8sClient.List(ctx, k3OSConfigs, client.InNamespace(c.Namespace)); err != nil {
+			return nil, err
+		}
+		switch len(k3OSConfigs.Items) {
+		case 0:
+		case 1:
+			source.spec = &k3OSConfigs.Items[0].Spec
+		default:
+			return nil, fmt.Errorf("found %d K3OSConfig objects in namespace %s, pass the one to use with --k3osconfig", len(k3OSConfigs.Items), c.Namespace)
+		}
+	}
+	configFiles := &configv1alpha1.K3OSConfigFileList{}
+	if err = k8sClient.List(ctx, configFiles, client.InNamespace(c.Namespace)); err != nil {
+		return nil, err
+	}
+	source.configFiles = configFiles.Items
+	source.resolve = resolveSecretKey(ctx, clientset, c.Namespace)
+
+	if templating := source.templating(); templating != nil {
+		if templating.ValuesConfigMap != "" {
+			configMap, err := clientset.CoreV1().ConfigMaps(c.Namespace).Get(ctx, templating.ValuesConfigMap, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			source.values = configMap.Data
+		}
+		if templating.ValuesSecret != "" {
+			secret, err := clientset.CoreV1().Secrets(c.Namespace).Get(ctx, templating.ValuesSecret, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			source.secretValues = map[string]string{}
+			for key, value := range secret.Data {
+				source.secretValues[key] = string(value)
+			}
+		}
+	}
+
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodeList := make([]*corev1.Node, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		nodeList = append(nodeList, &nodes.Items[i])
+	}
+	return nodeList, nil
+}
+
+// resolveSecretKey returns a resolver for the Secrets referenced by K3OSConfigFile objects.
+func resolveSecretKey(ctx context.Context, clientset kubernetes.Interface, namespace string) nodeconfig.SecretKeyResolver {
+	return func(selector *corev1.SecretKeySelector) (string, error) {
+		optional := selector.Optional != nil && *selector.Optional
+		secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, selector.Name, metav1.GetOptions{})
+		if err != nil {
+			if optional && apierrors.IsNotFound(err) {
+				return "", nil
+			}
+			return "", err
+		}
+		value, ok := secret.Data[selector.Key]
+		if !ok && !optional {
+			return "", fmt.Errorf("key %q not found in Secret %q", selector.Key, selector.Name)
+		}
+		return string(value), nil
+	}
+}
+
+// nodeConfigSource contains everything the config of a node is merged and rendered from.
+type nodeConfigSource struct {
+	spec         *configv1alpha1.K3OSConfigSpec
+	data         map[string][]byte
+	configFiles  []configv1alpha1.K3OSConfigFile
+	resolve      nodeconfig.SecretKeyResolver
+	values       map[string]string
+	secretValues map[string]string
+}
+
+func (s *nodeConfigSource) layers() *configv1alpha1.K3OSConfigLayers {
+	if s.spec == nil {
+		return nil
+	}
+	return s.spec.Layers
+}
+
+func (s *nodeConfigSource) templating() *configv1alpha1.K3OSConfigTemplating {
+	if s.spec == nil || s.spec.Templating == nil || !s.spec.Templating.Enabled {
+		return nil
+	}
+	return s.spec.Templating
+}
+
+// nodes returns a node for every entry that isn't a base or group layer. The nodes only carry the hostname label
+// so group layers only select them by their name.
+func (s *nodeConfigSource) nodes() []*corev1.Node {
+	layerKeys := map[string]struct{}{}
+	if layers := s.layers(); layers != nil {
+		layerKeys[layers.Base] = struct{}{}
+		for _, group := range layers.Groups {
+			layerKeys[group.Key] = struct{}{}
+		}
+	}
+	var nodeList []*corev1.Node
+	for key := range s.data {
+		if _, ok := layerKeys[key]; !ok {
+			nodeList = append(nodeList, offlineNode(key))
+		}
+	}
+	sort.Slice(nodeList, func(i, j int) bool { return nodeList[i].GetName() < nodeList[j].GetName() })
+	return nodeList
+}
+
+func offlineNode(name string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{corev1.LabelHostname: name}}}
+}
+
+// selectNodes returns the nodes with the given names. Nodes that aren't in the list are made up like the nodes
+// of the entries so that the config of a node that only uses the base and group layers can be printed.
+func selectNodes(nodeList []*corev1.Node, names []string) []*corev1.Node {
+	nodesByName := make(map[string]*corev1.Node, len(nodeList))
+	for _, node := range nodeList {
+		nodesByName[node.GetName()] = node
+	}
+	selected := make([]*corev1.Node, 0, len(names))
+	for _, name := range names {
+		node, ok := nodesByName[name]
+		if !ok {
+			node = offlineNode(name)
+		}
+		selected = append(selected, node)
+	}
+	return selected
+}
+
+// nodeConfig returns the config of the node and the names of the layers it was merged from the same way the operator
+// does: the entry of the node is replaced by the K3OSConfigFile of the node if there is one, every layer is rendered
+// as a template if templating is enabled and the merged config is parsed and validated.
+func (s *nodeConfigSource) nodeConfig(node *corev1.Node) ([]byte, []string, error) {
+	data := make(map[string][]byte, len(s.data)+1)
+	for key, value := range s.data {
+		data[key] = value
+	}
+	configFile, err := nodeconfig.SelectConfigFile(s.configFiles, node)
+	if err != nil {
+		return nil, nil, err
+	}
+	if configFile != nil {
+		if data[node.GetName()], err = nodeconfig.ConfigFileData(configFile, s.resolve); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	layers, err := nodeconfig.Layers(s.layers(), data, node)
+	if err != nil {
+		return nil, nil, err
+	}
+	layerNames := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		layerNames = append(layerNames, layer.Name)
+	}
+	if configFile != nil {
+		layerNames[len(layerNames)-1] = configFile.ConfigSource()
+	}
+
+	var templateData *nodeconfig.TemplateData
+	if s.templating() != nil {
+		templateData = nodeconfig.NewTemplateData(node, s.values, s.secretValues)
+	}
+	nodeConfigBytes, err := nodeconfig.Merge(s.layers(), data, node, templateData)
+	if err != nil {
+		return nil, layerNames, err
+	}
+	if _, err = configv1alpha1.ParseConfigYAML(nodeConfigBytes); err != nil {
+		return nil, layerNames, err
+	}
+	return nodeConfigBytes, layerNames, nil
+}
+
+// writeEffectiveConfigs writes the config of every node as a YAML document to out. Secret fields are redacted unless
+// showSecrets is set. Nodes whose config can't be merged, rendered or fails validation are reported to log.
+func writeEffectiveConfigs(out, log io.Writer, source *nodeConfigSource, nodeList []*corev1.Node, showSecrets bool) error {
+	var written, failed int
+	for _, node := range nodeList {
+		nodeConfigBytes, layerNames, err := source.nodeConfig(node)
+		if err != nil {
+			fmt.Fprintf(log, "error: node %s: %v\n", node.GetName(), err)
+			failed++
+			continue
+		}
+		if !showSecrets {
+			nodeConfigBytes = nodeconfig.RedactLines(nodeConfigBytes)
+		}
+		if written > 0 {
+			fmt.Fprintln(out, "---")
+		}
+		written++
+		fmt.Fprintf(out, "# node %s (%s)\n%s", node.GetName(), strings.Join(layerNames, ", "), nodeConfigBytes)
+		if len(nodeConfigBytes) > 0 && nodeConfigBytes[len(nodeConfigBytes)-1] != '\n' {
+			fmt.Fprintln(out)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to get the config of %d of %d nodes", failed, len(nodeList))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWriteEffectiveConfigs(t *testing.T) {
+	layers := &configv1alpha1.K3OSConfigSpec{
+		Layers: &configv1alpha1.K3OSConfigLayers{
+			Base: "base",
+			Groups: []configv1alpha1.K3OSConfigLayerGroup{
+				{Name: "n2", Key: "group-n2", NodeSelector: metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelHostname: "n2"}}},
+			},
+		},
+	}
+	data := map[string][]byte{
+		"base":     []byte("k3os:\n  token: secret\n  labels:\n    region: home\n"),
+		"group-n2": []byte("k3os:\n  labels:\n    disk: ssd\n"),
+		"n1":       []byte("hostname: n1\n"),
+		"n2":       []byte("hostname: n2\n"),
+	}
+
+	tests := []struct {
+		name        string
+		source      *nodeConfigSource
+		nodes       []string
+		showSecrets bool
+		want        string
+		wantErr     string
+		wantLog     string
+	}{
+		{
+			name:   "without layers",
+			source: &nodeConfigSource{data: map[string][]byte{"n1": []byte("hostname: n1\n"), "n2": []byte("hostname: n2\n")}},
+			want:   "# node n1 (node n1)\nhostname: n1\n---\n# node n2 (node n2)\nhostname: n2\n",
+		},
+		{
+			name:   "merged from layers with secrets redacted",
+			source: &nodeConfigSource{spec: layers, data: data},
+			want: "# node n1 (base, node n1)\nhostname: n1\nk3os:\n    labels:\n        region: home\n    token: REDACTED\n" +
+				"---\n# node n2 (base, group n2, node n2)\nhostname: n2\nk3os:\n    labels:\n        disk: ssd\n        region: home\n    token: REDACTED\n",
+		},
+		{
+			name:        "selected node with secrets",
+			source:      &nodeConfigSource{spec: layers, data: data},
+			nodes:       []string{"n3"},
+			showSecrets: true,
+			want:        "# node n3 (base)\nk3os:\n    labels:\n        region: home\n    token: secret\n",
+		},
+		{
+			name: "rendered from templates",
+			source: &nodeConfigSource{
+				spec:   &configv1alpha1.K3OSConfigSpec{Templating: &configv1alpha1.K3OSConfigTemplating{Enabled: true}},
+				data:   map[string][]byte{"n1": []byte("hostname: {{ .Node.Name }}\nk3os:\n  labels:\n    zone: {{ .Values.zone }}\n")},
+				values: map[string]string{"zone": "a"},
+			},
+			want: "# node n1 (node n1)\nhostname: n1\nk3os:\n  labels:\n    zone: a\n",
+		},
+		{
+			name: "entry replaced by a K3OSConfigFile",
+			source: &nodeConfigSource{
+				data: map[string][]byte{"n1": []byte("hostname: n1\n")},
+				configFiles: []configv1alpha1.K3OSConfigFile{{
+					ObjectMeta: metav1.ObjectMeta{Name: "n1"},
+					Spec:       configv1alpha1.K3OSConfigFileSpec{Hostname: "node1"},
+				}},
+			},
+			want: "# node n1 (K3OSConfigFile/n1)\nhostname: node1\n",
+		},
+		{
+			name:    "invalid merged config",
+			source:  &nodeConfigSource{data: map[string][]byte{"n1": []byte("hostname: n1\n"), "n2": []byte("hostname: -n2\n")}},
+			want:    "# node n1 (node n1)\nhostname: n1\n",
+			wantErr: "failed to get the config of 1 of 2 nodes",
+			wantLog: "error: node n2: hostname",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			nodeList := tt.source.nodes()
+			if len(tt.nodes) > 0 {
+				nodeList = selectNodes(nodeList, tt.nodes)
+			}
+			out, log := &bytes.Buffer{}, &bytes.Buffer{}
+			err := writeEffectiveConfigs(out, log, tt.source, nodeList, tt.showSecrets)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("writeEffectiveConfigs() error = %v, wantErr %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("writeEffectiveConfigs() error = %v, log = %s", err, log)
+			}
+			if out.String() != tt.want {
+				t.Errorf("writeEffectiveConfigs() = %q, want %q", out.String(), tt.want)
+			}
+			if !strings.Contains(log.String(), tt.wantLog) {
+				t.Errorf("writeEffectiveConfigs() log = %q, want it to contain %q", log.String(), tt.wantLog)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodeconfig"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// generateCommand contains the commands that generate the node config Secret.
+type generateCommand struct {
+	Secret        generateSecretCommand        `command:"secret"        description:"Print the node config Secret containing the node config files in a directory."`
+	Kustomization generateKustomizationCommand `command:"kustomization" description:"Print a kustomization that generates the node config Secret from the node config files in a directory (write it into the directory)."`
+}
+
+// generateSecretCommand prints the node config Secret.
+type generateSecretCommand struct {
+	SecretOptions
+	Args directoryArgs `positional-args:"yes" required:"yes"`
+}
+
+// Execute runs the generate secret command.
+func (c *generateSecretCommand) Execute([]string) error {
+	entries, err := readNodeConfigs(os.Stderr, c.Args.Directory)
+	if err != nil {
+		return err
+	}
+	return writeSecret(os.Stdout, entries, &c.SecretOptions)
+}
+
+// generateKustomizationCommand prints a kustomization that generates the node config Secret.
+type generateKustomizationCommand struct {
+	SecretOptions
+	Args directoryArgs `positional-args:"yes" required:"yes"`
+}
+
+// Execute runs the generate kustomization command.
+func (c *generateKustomizationCommand) Execute([]string) error {
+	entries, err := readNodeConfigs(os.Stderr, c.Args.Directory)
+	if err != nil {
+		return err
+	}
+	return writeKustomization(os.Stdout, entries, &c.SecretOptions)
+}
+
+// nodeConfigSecret returns the node config Secret containing the entries. It carries the labels the validating
+// webhook selects node config Secrets by.
+func nodeConfigSecret(entries []nodeconfig.Entry, options *SecretOptions) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      options.Name,
+			Namespace: options.Namespace,
+			Labels:    consts.LabelSelectorForNodeConfigFileSecret().MatchLabels,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: nodeconfig.Data(entries),
+	}
+}
+
+func writeSecret(out io.Writer, entries []nodeconfig.Entry, options *SecretOptions) error {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(nodeConfigSecret(entries, options))
+	if err != nil {
+		return err
+	}
+	unstructured.RemoveNestedField(object, "metadata", "creationTimestamp") // always null
+	return writeYAML(out, object)
+}
+
+// kustomization is the subset of a kustomization.yaml needed to generate the node config Secret.
+type kustomization struct {
+	APIVersion       string            `json:"apiVersion"`
+	Kind             string            `json:"kind"`
+	GeneratorOptions generatorOptions  `json:"generatorOptions"`
+	SecretGenerator  []secretGenerator `json:"secretGenerator"`
+}
+
+type generatorOptions struct {
+	Labels                map[string]string `json:"labels"`
+	DisableNameSuffixHash bool              `json:"disableNameSuffixHash"`
+}
+
+type secretGenerator struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Type      string   `json:"type"`
+	Files     []string `json:"files"`
+}
+
+// writeKustomization writes a kustomization that generates the node config Secret from the files of the entries.
+// The paths of the files are relative to the directory they were read from. The name of the Secret must not change
+// because the operator reads it by name.
+func writeKustomization(out io.Writer, entries []nodeconfig.Entry, options *SecretOptions) error {
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, fmt.Sprintf("%s=%s", entry.Key, entry.File))
+	}
+	return writeYAML(out, &kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		GeneratorOptions: generatorOptions{
+			Labels:                consts.LabelSelectorForNodeConfigFileSecret().MatchLabels,
+			DisableNameSuffixHash: true,
+		},
+		SecretGenerator: []secretGenerator{{
+			Name:      options.Name,
+			Namespace: options.Namespace,
+			Type:      string(corev1.SecretTypeOpaque),
+			Files:     files,
+		}},
+	})
+}
+
+func writeYAML(out io.Writer, object interface{}) error {
+	data, err := yaml.Marshal(object)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodeconfig"
+)
+
+var testEntries = []nodeconfig.Entry{
+	{Key: "n1", File: "dc:a6:32:01:02:03.yaml", Data: []byte("hostname: n1\n")},
+	{Key: "n2", File: "n2.yaml", Data: []byte("hostname: n2\n")},
+}
+
+func TestWriteSecret(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := writeSecret(out, testEntries, &SecretOptions{Name: "k3os-nodes", Namespace: "k3os-config-operator-system"}); err != nil {
+		t.Fatalf("writeSecret() error = %v", err)
+	}
+	want := `apiVersion: v1
+data:
+  n1: aG9zdG5hbWU6IG4xCg==
+  n2: aG9zdG5hbWU6IG4yCg==
+kind: Secret
+metadata:
+  labels:
+    app.kubernetes.io/managed-by: k3os-config-operator
+  name: k3os-nodes
+  namespace: k3os-config-operator-system
+type: Opaque
+`
+	if out.String() != want {
+		t.Errorf("writeSecret() = %s, want %s", out.String(), want)
+	}
+}
+
+func TestWriteKustomization(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := writeKustomization(out, testEntries, &SecretOptions{Name: "nodes", Namespace: "operators"}); err != nil {
+		t.Fatalf("writeKustomization() error = %v", err)
+	}
+	want := `apiVersion: kustomize.config.k8s.io/v1beta1
+generatorOptions:
+  disableNameSuffixHash: true
+  labels:
+    app.kubernetes.io/managed-by: k3os-config-operator
+kind: Kustomization
+secretGenerator:
+- files:
+  - n1=dc:a6:32:01:02:03.yaml
+  - n2=n2.yaml
+  name: nodes
+  namespace: operators
+  type: Opaque
+`
+	if out.String() != want {
+		t.Errorf("writeKustomization() = %s, want %s", out.String(), want)
+	}
+}
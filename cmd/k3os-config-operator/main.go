@@ -0,0 +1,73 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Command k3os-config-operator validates the node config files in a directory and generates the node config Secret
+// of the operator from them, diffs them against the cluster and prints the config every node ends up with.
+package main
+
+import (
+	"os"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	flags "github.com/jessevdk/go-flags"
+)
+
+// errDifferent is returned by the diff command if the node config Secret in the cluster differs from the directory
+// so that the CLI exits with a non-zero exit code like diff does.
+var errDifferent = errors.New("the node config Secret differs from the directory")
+
+// options contains the commands of the CLI.
+type options struct {
+	Validate  validateCommand  `command:"validate"  description:"Validate the node config files in a directory."`
+	Generate  generateCommand  `command:"generate"  description:"Generate the node config Secret or a kustomization for it from the node config files in a directory."`
+	Diff      diffCommand      `command:"diff"      description:"Show the differences between the node config Secret in the cluster and the node config files in a directory."`
+	Effective effectiveCommand `command:"effective" description:"Print the config of every node merged from its layers and rendered from its templates."`
+}
+
+// directoryArgs is the positional argument of the commands that read the node config files in a directory.
+type directoryArgs struct {
+	Directory string `positional-arg-name:"dir" description:"Directory containing the node config files (<node>.yaml or <mac>.yaml)."`
+}
+
+// SecretOptions are the options of the commands that generate or read the node config Secret.
+type SecretOptions struct {
+	Name      string `long:"name"      default:"k3os-nodes"                  description:"Name of the node config Secret."`
+	Namespace string `long:"namespace" default:"k3os-config-operator-system" description:"Namespace the operator is running in." short:"n"`
+}
+
+// ClusterOptions are the options of the commands that connect to the cluster.
+type ClusterOptions struct {
+	Kubeconfig string `long:"kubeconfig" description:"Path to the kubeconfig file (defaults to $KUBECONFIG or ~/.kube/config)."`
+	Context    string `long:"context"    description:"Name of the kubeconfig context to use."`
+}
+
+func main() {
+	// the parser prints all errors including the ones returned by the commands
+	if _, err := flags.NewParser(&options{}, flags.Default).Parse(); err != nil {
+		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodeconfig"
+)
+
+// validateCommand validates the node config files in a directory.
+type validateCommand struct {
+	Args directoryArgs `positional-args:"yes" required:"yes"`
+}
+
+// Execute runs the validate command.
+func (c *validateCommand) Execute([]string) error {
+	entries, err := readNodeConfigs(os.Stderr, c.Args.Directory)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "validated %d node config files\n", len(entries))
+	return nil
+}
+
+// readNodeConfigs reads the node config files in the directory and validates them the same way the operator and
+// its validating webhook do. Problems are written to log. It returns an error if any of the files is invalid.
+// Files containing template actions can only be validated once they're rendered for a node and are skipped.
+func readNodeConfigs(log io.Writer, dir string) ([]nodeconfig.Entry, error) {
+	entries, err := nodeconfig.ReadDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no node config files found in %s", dir)
+	}
+	var invalid int
+	for _, entry := range entries {
+		if bytes.Contains(entry.Data, []byte("{{")) {
+			fmt.Fprintf(log, "warning: %s: contains template actions and is only validated when it's rendered for a node\n", entry.File)
+			continue
+		}
+		spec, err := configv1alpha1.ParseConfigYAML(entry.Data)
+		if err != nil {
+			fmt.Fprintf(log, "error: %s: %v\n", entry.File, err)
+			invalid++
+			continue
+		}
+		for _, warning := range spec.Warnings() {
+			fmt.Fprintf(log, "warning: %s: %s\n", entry.File, warning)
+		}
+		if name := strings.TrimSuffix(entry.File, filepath.Ext(entry.File)); name == entry.Key && spec.Hostname != "" && spec.Hostname != entry.Key {
+			fmt.Fprintf(log, "warning: %s: hostname %q differs from the name of the node the file applies to\n", entry.File, spec.Hostname)
+		}
+	}
+	if invalid > 0 {
+		return nil, fmt.Errorf("%d of %d node config files are invalid", invalid, len(entries))
+	}
+	return entries, nil
+}
@@ -0,0 +1,116 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeNodeConfigs writes the files into a temporary directory and returns it.
+func writeNodeConfigs(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadNodeConfigs(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		wantKeys     []string
+		wantErr      string
+		wantMessages []string
+	}{
+		{
+			name: "valid files",
+			files: map[string]string{
+				"dc:a6:32:01:02:03.yaml": "hostname: n1\nk3os:\n  labels:\n    region: home\n",
+				"n2.yaml":                "hostname: n2\n",
+			},
+			wantKeys: []string{"n1", "n2"},
+		},
+		{
+			name:    "empty directory",
+			files:   map[string]string{},
+			wantErr: "no node config files found",
+		},
+		{
+			name: "invalid file",
+			files: map[string]string{
+				"n1.yaml": "hostname: n1\n",
+				"n2.yaml": "hostname: n2\nk3os:\n  taints:\n  - invalid\n",
+			},
+			wantErr:      "1 of 2 node config files are invalid",
+			wantMessages: []string{"error: n2.yaml: k3os.taints[0]"},
+		},
+		{
+			name: "warnings",
+			files: map[string]string{
+				"n1.yaml": "hostname: n2\n",
+				"n3.yaml": "hostname: {{ .Node.Name }}\n",
+			},
+			wantKeys: []string{"n1", "n3"},
+			wantMessages: []string{
+				`warning: n1.yaml: hostname "n2" differs from the name of the node the file applies to`,
+				"warning: n3.yaml: contains template actions",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			log := &bytes.Buffer{}
+			entries, err := readNodeConfigs(log, writeNodeConfigs(t, tt.files))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readNodeConfigs() error = %v, wantErr %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("readNodeConfigs() error = %v", err)
+			}
+			var keys []string
+			for _, entry := range entries {
+				keys = append(keys, entry.Key)
+			}
+			if strings.Join(keys, ",") != strings.Join(tt.wantKeys, ",") {
+				t.Errorf("readNodeConfigs() keys = %v, want %v", keys, tt.wantKeys)
+			}
+			for _, message := range tt.wantMessages {
+				if !strings.Contains(log.String(), message) {
+					t.Errorf("readNodeConfigs() log = %q, want it to contain %q", log.String(), message)
+				}
+			}
+		})
+	}
+}
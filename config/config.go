@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strings"
+	"time"
 
 	flags "github.com/jessevdk/go-flags"
 )
@@ -40,9 +42,24 @@ type Configuration struct {
 	NodeName  string `long:"node-name" required:"true" env:"NODE_NAME" description:"The name of the node the operator is running on."`
 	DevMode   bool   `long:"dev-mode"                  env:"DEV_MODE"  description:"Enable dev mode."`
 
+	Webhooks bool `long:"enable-webhooks" env:"ENABLE_WEBHOOKS" description:"Enable the validating admission webhooks (requires a serving certificate)."`
+
 	ManageNodeConfigFile   bool   `long:"manage-node-config-file"                        env:"ENABLE_NODECONFIG_FILE_MANAGEMENT" description:"Enable node config file management."`
 	NodeConfigFileLocation string `long:"node-config-file-location"                      env:"NODECONFIG_FILE_LOCATION"          description:"Location of the node config file on disk."`
 	NodeConfigSecretName   string `long:"node-config-secret-name"   default:"k3os-nodes" env:"NODECONFIG_SECRET_NAME"            description:"Name of the secret that contains the node configurations."`
+	NodeConfigFileBackups  int    `long:"node-config-file-backups"  default:"3"          env:"NODECONFIG_FILE_BACKUPS"           description:"Number of timestamped backups of the node config file to keep next to it (0 disables backups)."`
+	NodeConfigFileFormat   string `long:"node-config-file-format"   default:"preserve"   env:"NODECONFIG_FILE_FORMAT"            description:"Whether the node config file is written as it is in the node config Secret including comments (preserve) or with sorted keys and without comments (normalize)." choice:"preserve" choice:"normalize"`
+
+	NodeConfigDropInDir        string `long:"node-config-drop-in-dir"                                  env:"NODECONFIG_DROPIN_DIR"         description:"Location of the k3OS config.d directory on disk. Managing drop-in files is disabled if empty."`
+	NodeConfigDropInSecretName string `long:"node-config-drop-in-secret-name" default:"k3os-nodes-config-d" env:"NODECONFIG_DROPIN_SECRET_NAME" description:"Name of the secret that contains the config.d drop-in files."`
+
+	OwnershipBackend string `long:"ownership-backend" default:"annotations" env:"OWNERSHIP_BACKEND" description:"How the operator tracks the node labels it owns: in an annotation on the node (annotations) or in the managedFields of the node by applying them with server-side apply (server-side-apply)." choice:"annotations" choice:"server-side-apply"`
+
+	ResyncInterval time.Duration `long:"resync-interval" default:"10m" env:"RESYNC_INTERVAL" description:"Interval in which the node is synced even if nothing changed, e.g. to detect drift of the node config file (0 disables it)."`
+
+	RestoreNodeConfigBackup string `long:"restore-node-config-backup" description:"Restore the node config file from the backup with the given file name (or 'latest') and exit."`
+
+	RebootCommand string `long:"reboot-command" env:"REBOOT_COMMAND" description:"Command (split on whitespace) that reboots the node, e.g. 'nsenter -t 1 -m -u -i -n -p -- reboot'. Rebooting nodes is disabled if empty."`
 }
 
 // EnableDevMode returns whether dev mode should be enabled or not.
@@ -50,6 +67,31 @@ func (c *Configuration) EnableDevMode() bool {
 	return c.DevMode
 }
 
+// EnableWebhooks returns whether the validating admission webhooks should be served or not.
+func (c *Configuration) EnableWebhooks() bool {
+	return c.Webhooks
+}
+
+// EnableNodeConfigDropInManagement returns whether the config.d drop-in file management should be enabled or not.
+func (c *Configuration) EnableNodeConfigDropInManagement() bool {
+	return c.NodeConfigDropInDir != ""
+}
+
+// NodeRebootCommand returns the command that reboots the node. It returns nil if rebooting nodes is disabled.
+func (c *Configuration) NodeRebootCommand() []string {
+	return strings.Fields(c.RebootCommand)
+}
+
+// NormalizeNodeConfigFile returns whether the node config file should be written in its normalized form.
+func (c *Configuration) NormalizeNodeConfigFile() bool {
+	return c.NodeConfigFileFormat == "normalize"
+}
+
+// UseServerSideApply returns whether node labels should be applied with server-side apply.
+func (c *Configuration) UseServerSideApply() bool {
+	return c.OwnershipBackend == "server-side-apply"
+}
+
 // EnableNodeConfigFileManagement returns whether the node config file management should be enabled or not.
 func (c *Configuration) EnableNodeConfigFileManagement() bool {
 	return c.ManageNodeConfigFile
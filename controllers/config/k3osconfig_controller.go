@@ -28,27 +28,47 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
+	"sync/atomic"
+	"time"
 
 	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
 	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	"github.com/annismckenzie/k3os-config-operator/pkg/events"
+	"github.com/annismckenzie/k3os-config-operator/pkg/metrics"
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodeconfig"
 	"github.com/annismckenzie/k3os-config-operator/pkg/nodes"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // allow operator to handle K3OSConfig CR objects in its namespace
 // +kubebuilder:rbac:groups=config.operators.annismckenzie.github.com,resources=k3osconfigs,verbs=get;list;watch;create;update;patch;delete,namespace=k3os-config-operator-system
 // +kubebuilder:rbac:groups=config.operators.annismckenzie.github.com,resources=k3osconfigs/status,verbs=get;update;patch,namespace=k3os-config-operator-system
 
+// allow operator to read K3OSConfigFile CR objects in its namespace and update their status
+// +kubebuilder:rbac:groups=config.operators.annismckenzie.github.com,resources=k3osconfigfiles,verbs=get;list;watch,namespace=k3os-config-operator-system
+// +kubebuilder:rbac:groups=config.operators.annismckenzie.github.com,resources=k3osconfigfiles/status,verbs=get;update;patch,namespace=k3os-config-operator-system
+
 // allow operator to get, list and watch Secret objects in its namespace
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch,namespace=k3os-config-operator-system
 
+// allow operator to get ConfigMap objects in its namespace (values of node config templates)
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get,namespace=k3os-config-operator-system
+
 // allow operator to update Node objects (the verbs deliberately do not include create and delete)
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;update;patch
 
+// allow operator to emit Events on Node objects and K3OSConfig CR objects
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
 // allow operator to use the k3os-config-operator-manager PodSecurityPolicy
 // +kubebuilder:rbac:groups=policy,resources=podsecuritypolicies,verbs=use,resourceNames=k3os-config-operator-manager,namespace=k3os-config-operator-system
 
@@ -68,7 +88,22 @@ func (r *K3OSConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	if r.leader { // this instance of the operator won the leader election and can update the K3OSConfig CR
 		result, err = r.handleK3OSConfigAsLeader(ctx, config)
 	} else { // handle k3os config file
-		result, err = r.handleK3OSConfig(ctx, config)
+		start := time.Now()
+		nodeStatus := &configv1alpha1.K3OSConfigNodeStatus{NodeName: r.configuration.NodeName}
+		result, err = r.handleK3OSConfig(ctx, config, nodeStatus)
+		if reportErr := r.reportNodeStatus(ctx, config, nodeStatus, err); reportErr != nil {
+			r.logger.Error(reportErr, "failed to report node status")
+		}
+		r.observeSync(nodeStatus, start, err)
+		if err == nil && !result.Requeue { // report back regularly so the leader doesn't consider this node stale
+			requeueAfter := reportInterval(config)
+			if resync := r.configuration.ResyncInterval; resync > 0 && resync < requeueAfter {
+				requeueAfter = resync
+			}
+			if result.RequeueAfter == 0 || requeueAfter < result.RequeueAfter {
+				result.RequeueAfter = requeueAfter
+			}
+		}
 	}
 
 	if err != nil {
@@ -77,8 +112,96 @@ func (r *K3OSConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	return result, err
 }
 
+// handleK3OSConfigAsLeader aggregates the sync reports of all nodes into the status of the K3OSConfig.
 func (r *K3OSConfigReconciler) handleK3OSConfigAsLeader(ctx context.Context, config *configv1alpha1.K3OSConfig) (ctrl.Result, error) {
-	return ctrl.Result{}, nil
+	nodeList, err := r.nodeLister.List(labels.Everything())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	pollAfter, err := r.orchestrateReboots(ctx, config, nodeList)
+	if err != nil { // the status is updated regardless
+		r.logger.Error(err, "failed to orchestrate node reboots")
+		pollAfter = rebootPollInterval
+	}
+	if err = r.orchestrateNoExecuteTaints(ctx, config, nodeList); err != nil { // the status is updated regardless
+		r.logger.Error(err, "failed to orchestrate NoExecute node taints")
+		pollAfter = rebootPollInterval
+	}
+
+	status := config.Status.DeepCopy()
+	status.Nodes = make([]configv1alpha1.K3OSConfigNodeStatus, 0, len(nodeList))
+	for _, node := range nodeList {
+		nodeStatus := configv1alpha1.K3OSConfigNodeStatus{NodeName: node.GetName()}
+		report, err := nodes.GetSyncReport(node)
+		switch {
+		case err != nil: // the node is reported as pending until it overwrites the report
+			r.logger.Error(err, "failed to read sync report", "node", node.GetName())
+		case report != nil && report.K3OSConfig == config.GetName():
+			nodeStatus = report.K3OSConfigNodeStatus
+			nodeStatus.NodeName = node.GetName()
+		}
+		status.Nodes = append(status.Nodes, nodeStatus)
+	}
+	sort.Slice(status.Nodes, func(i, j int) bool { return status.Nodes[i].NodeName < status.Nodes[j].NodeName })
+
+	now, staleAfter := time.Now(), config.Spec.StaleAfter()
+	status.UpdateSummary(config.GetGeneration(), staleAfter, now)
+
+	if err := r.updateK3OSConfigFileStatuses(ctx, status.Nodes); err != nil { // the K3OSConfig status is updated regardless
+		r.logger.Error(err, "failed to update K3OSConfigFile status")
+	}
+
+	var result ctrl.Result
+	if next, ok := status.NextStaleCheck(staleAfter, now); ok { // requeue to notice nodes that go stale
+		result.RequeueAfter = next + time.Second
+	}
+	if pollAfter > 0 && (result.RequeueAfter == 0 || pollAfter < result.RequeueAfter) {
+		result.RequeueAfter = pollAfter
+	}
+	if equality.Semantic.DeepEqual(status, &config.Status) {
+		return result, nil
+	}
+
+	config.Status = *status
+	if err := r.client.Status().Update(ctx, config); err != nil {
+		if apierrors.IsConflict(err) { // the K3OSConfig was changed in the meantime, try again
+			return ctrl.Result{Requeue: true}, nil
+		}
+		return ctrl.Result{}, resultError(err, r.logger)
+	}
+	r.logger.V(1).Info("successfully updated K3OSConfig status", "summary", status.Summary)
+	return result, nil
+}
+
+// updateK3OSConfigFileStatuses copies the status of every node into the status of the K3OSConfigFile its config was read from.
+func (r *K3OSConfigReconciler) updateK3OSConfigFileStatuses(ctx context.Context, nodeStatuses []configv1alpha1.K3OSConfigNodeStatus) error {
+	configFiles := &configv1alpha1.K3OSConfigFileList{}
+	if err := r.client.List(ctx, configFiles, client.InNamespace(r.namespace)); err != nil {
+		return err
+	}
+	for i := range configFiles.Items {
+		configFile := configFiles.Items[i].DeepCopy()
+		status := configv1alpha1.K3OSConfigFileStatus{}
+		for _, nodeStatus := range nodeStatuses {
+			if nodeStatus.ConfigSource == configFile.ConfigSource() {
+				status.Nodes = append(status.Nodes, nodeStatus)
+			}
+		}
+		if equality.Semantic.DeepEqual(&status, &configFile.Status) {
+			continue
+		}
+		configFile.Status = status
+		if err := r.client.Status().Update(ctx, configFile); err != nil && !apierrors.IsConflict(err) { // conflicts are retried on the next reconcile
+			return err
+		}
+	}
+	return nil
+}
+
+// reportInterval returns the interval in which nodes report their sync status.
+func reportInterval(config *configv1alpha1.K3OSConfig) time.Duration {
+	return config.Spec.StaleAfter() / 3 //nolint:gomnd // report three times before the node is considered stale
 }
 
 func resultError(err error, logger logr.Logger) error {
@@ -89,101 +212,551 @@ func resultError(err error, logger logr.Logger) error {
 		return nil
 	case apierrors.IsNotFound(err), apierrors.IsGone(err):
 		logger.Info("object is gone, not requeuing")
+		metrics.CountSwallowedError(metrics.ErrorClassNotFound)
 		return nil
 	case apierrors.IsForbidden(err):
 		logger.Error(err, "failed to execute operation, did you forget to apply some RBAC rules?")
+		metrics.CountSwallowedError(metrics.ErrorClassForbidden)
 		return nil
 	case errors.Is(err, os.ErrPermission):
 		logger.Error(err, "failed to execute operation, permission denied was returned")
+		metrics.CountSwallowedError(metrics.ErrorClassPermission)
 		return nil
 	default:
 		return err
 	}
 }
 
-func (r *K3OSConfigReconciler) handleK3OSConfig(ctx context.Context, k3OSConfig *configv1alpha1.K3OSConfig) (result ctrl.Result, err error) {
+// failSync records the error in the node status and returns the error that should be passed to the caller.
+func (r *K3OSConfigReconciler) failSync(nodeStatus *configv1alpha1.K3OSConfigNodeStatus, err error) error {
+	nodeStatus.LastError = err.Error()
+	return resultError(err, r.logger)
+}
+
+func (r *K3OSConfigReconciler) handleK3OSConfig(ctx context.Context, k3OSConfig *configv1alpha1.K3OSConfig, nodeStatus *configv1alpha1.K3OSConfigNodeStatus) (result ctrl.Result, err error) {
 	var (
 		nodeName   = r.configuration.NodeName // 1. get node name we're running
+		dryRun     = k3OSConfig.Spec.DryRun   // compute the changes without applying them
 		nodeConfig *configv1alpha1.K3OSConfigFileSpec
 		node       *corev1.Node
 	)
 
-	// 2. get node config
-	if nodeConfig, err = r.getNodeConfig(ctx, nodeName); err != nil {
-		return ctrl.Result{}, resultError(err, r.logger)
+	// 2. get node
+	if node, err = r.getNode(nodeName); err != nil {
+		return ctrl.Result{}, r.failSync(nodeStatus, err)
 	}
-	r.logger.V(1).Info("successfully fetched node config", "config", nodeConfig)
 
-	// 3. get node
-	if node, err = r.getNode(nodeName); err != nil {
-		return ctrl.Result{}, resultError(err, r.logger)
+	// 3. get node config (merged from its layers if configured)
+	if nodeConfig, nodeStatus.ConfigSource, err = r.getNodeConfig(ctx, k3OSConfig, node); err != nil {
+		reason := events.ReasonSyncFailed
+		if errors.Is(err, errors.ErrInvalidNodeConfig) {
+			reason = events.ReasonInvalidNodeConfig
+		}
+		r.recordEvent(k3OSConfig, node, corev1.EventTypeWarning, reason, err.Error())
+		return ctrl.Result{}, r.failSync(nodeStatus, err)
+	}
+	nodeStatus.ConfigHash = nodeConfig.Hash()
+	r.logger.V(1).Info("successfully fetched node config", "config", nodeConfig)
+	if warnings := nodeConfig.Warnings(); len(warnings) > 0 {
+		r.logger.Info("node config contains warnings", "warnings", warnings)
 	}
 
-	var updateNode bool
+	var (
+		updateNode bool
+		planned    = &configv1alpha1.K3OSConfigPlannedChanges{}
+	)
 
-	// 4. sync node labels
-	labeler := nodes.NewLabeler()
+	// 4. sync node labels and roles (applied with server-side apply after the node was updated if enabled)
+	original := node.DeepCopy()
+	labelsBefore, annotationsBefore := original.GetLabels(), original.GetAnnotations()
+	var (
+		labeler     nodes.Labeler = nodes.NewLabeler(k3OSConfig.Spec.LabelPolicy)
+		applyLabels bool
+	)
+	if r.useServerSideApply() {
+		labeler = nodes.NewApplyLabeler(k3OSConfig.Spec.LabelPolicy)
+	}
 	if k3OSConfig.Spec.SyncNodeLabels {
-		if err = labeler.Reconcile(node, nodeConfig.K3OS.Labels); err == nil {
-			updateNode = true
-		} else if err := resultError(err, r.logger); err != nil {
-			return ctrl.Result{}, err
+		start := time.Now()
+		err = labeler.Reconcile(node, nodeConfig.NodeLabels())
+		metrics.ObservePhase(nodeName, metrics.PhaseLabels, start, err)
+		r.reportOwnershipError(k3OSConfig, node, nodeStatus, labeler.OwnershipError())
+		if nodeStatus.SkippedLabels = labeler.SkippedLabels(); len(nodeStatus.SkippedLabels) > 0 {
+			r.logger.Info("skipped node labels denied by the label policy", "skippedLabels", nodeStatus.SkippedLabels)
+		}
+		_, isApplyLabeler := labeler.(nodes.ApplyLabeler)
+		if err == nil {
+			applyLabels, updateNode = isApplyLabeler, !isApplyLabeler
+		} else if !errors.Is(err, errors.ErrSkipUpdate) {
+			return ctrl.Result{}, r.failSync(nodeStatus, err)
 		}
 	}
 
 	// 5. sync node taints
-	tainter := nodes.NewTainter()
+	tainter := nodes.NewTainter(k3OSConfig.Spec.TaintPolicy)
 	if k3OSConfig.Spec.SyncNodeTaints {
-		if err = tainter.Reconcile(node, nodeConfig.K3OS.Taints); err == nil {
+		start := time.Now()
+		err = tainter.Reconcile(node, nodeConfig.K3OS.Taints)
+		metrics.ObservePhase(nodeName, metrics.PhaseTaints, start, err)
+		r.reportOwnershipError(k3OSConfig, node, nodeStatus, tainter.OwnershipError())
+		if nodeStatus.SkippedTaints = tainter.SkippedTaints(); len(nodeStatus.SkippedTaints) > 0 {
+			r.logger.Info("skipped node taints forbidden by the taint policy", "skippedTaints", nodeStatus.SkippedTaints)
+		}
+		if nodeStatus.DelayedTaints = tainter.DelayedTaints(); len(nodeStatus.DelayedTaints) > 0 {
+			r.logger.Info("delayed NoExecute node taints", "delayedTaints", nodeStatus.DelayedTaints)
+		}
+		if err == nil {
+			updateNode = true
+		} else if !errors.Is(err, errors.ErrSkipUpdate) {
+			return ctrl.Result{}, r.failSync(nodeStatus, err)
+		}
+	}
+
+	// 6. sync node annotations
+	annotator := nodes.NewAnnotator()
+	if k3OSConfig.Spec.SyncNodeAnnotations {
+		start := time.Now()
+		err = annotator.Reconcile(node, nodeConfig.Operator.Annotations)
+		metrics.ObservePhase(nodeName, metrics.PhaseAnnotations, start, err)
+		r.reportOwnershipError(k3OSConfig, node, nodeStatus, annotator.OwnershipError())
+		if err == nil {
+			updateNode = true
+		} else if !errors.Is(err, errors.ErrSkipUpdate) {
+			return ctrl.Result{}, r.failSync(nodeStatus, err)
+		}
+	}
+
+	// 7. sync the unschedulable and providerID fields of the node spec
+	specReconciler := nodes.NewNodeSpecReconciler(k3OSConfig.Spec.UnschedulablePolicy)
+	if k3OSConfig.Spec.SyncNodeSpec {
+		start := time.Now()
+		err = specReconciler.Reconcile(node, &nodeConfig.Operator)
+		metrics.ObservePhase(nodeName, metrics.PhaseSpec, start, err)
+		r.reportOwnershipError(k3OSConfig, node, nodeStatus, specReconciler.OwnershipError())
+		if err == nil {
 			updateNode = true
-		} else if err := resultError(err, r.logger); err != nil {
-			return ctrl.Result{}, err
+		} else if !errors.Is(err, errors.ErrSkipUpdate) {
+			return ctrl.Result{}, r.failSync(nodeStatus, err)
+		}
+		for _, conflict := range specReconciler.Conflicts() {
+			r.logger.Info("skipped updating node spec field", "reason", conflict)
+			r.recordEvent(k3OSConfig, node, corev1.EventTypeWarning, events.ReasonNodeSpecConflict, conflict)
 		}
 	}
 
-	// 6. update node only on changes
-	if updateNode {
-		err = r.updateNode(ctx, node)
+	// 8. update node only on changes
+	switch {
+	case (updateNode || applyLabels) && dryRun:
+		planned.Labels, planned.Taints, planned.Annotations = labeler.UpdatedLabels(), tainter.UpdatedTaints(), annotator.UpdatedAnnotations()
+		planned.SpecFields = specReconciler.UpdatedFields()
+		r.logger.Info("planned node changes", "plannedLabels", planned.Labels, "plannedTaints", planned.Taints, "plannedAnnotations", planned.Annotations, "plannedSpecFields", planned.SpecFields)
+	case updateNode || applyLabels:
+		start := time.Now()
+		if updateNode {
+			err = r.updateNode(ctx, original, node)
+		}
+		if err == nil && applyLabels {
+			err = r.applyNodeLabels(ctx, nodeName, labeler.(nodes.ApplyLabeler))
+		}
+		metrics.ObservePhase(nodeName, metrics.PhaseNode, start, err)
 		switch {
 		case err == nil:
-			r.logger.Info("successfully updated node", "updatedLabels", labeler.UpdatedLabels(), "updatedTaints", tainter.UpdatedTaints())
-		case apierrors.IsConflict(err): // check for conflict which happens from time to time and should not blow up the log
+			r.logger.Info("successfully updated node", "updatedLabels", labeler.UpdatedLabels(), "updatedTaints", tainter.UpdatedTaints(), "updatedAnnotations", annotator.UpdatedAnnotations(), "updatedSpecFields", specReconciler.UpdatedFields())
+			nodeStatus.UpdatedLabels = labeler.UpdatedLabels()
+			nodeStatus.UpdatedTaints = tainter.UpdatedTaints()
+			nodeStatus.UpdatedAnnotations = annotator.UpdatedAnnotations()
+			nodeStatus.UpdatedSpecFields = specReconciler.UpdatedFields()
+			r.recordNodeEvents(k3OSConfig, node, labelsBefore, labeler.UpdatedLabels(), tainter.UpdatedTaints())
+			r.recordNodeAnnotationEvents(k3OSConfig, node, annotationsBefore, annotator.UpdatedAnnotations())
+			r.recordNodeSpecEvents(k3OSConfig, node, specReconciler.UpdatedFields())
+		case apierrors.IsConflict(err), nodes.IsPatchTestFailed(err): // the fields were changed concurrently, this should not blow up the log
 			return ctrl.Result{}, errors.New("node object was changed, requeuing")
+		case errors.Is(err, errServerSideApplyUnsupported):
+			r.logger.Error(err, "falling back to tracking node labels in an annotation")
+			return ctrl.Result{Requeue: true}, nil
 		default:
-			return ctrl.Result{}, err // bail and pass error to caller
+			r.recordEvent(k3OSConfig, node, corev1.EventTypeWarning, events.ReasonSyncFailed, "failed to update node: "+err.Error())
+			return ctrl.Result{}, r.failSync(nodeStatus, err) // bail and pass error to caller
 		}
-	} else {
+	default:
 		r.logger.V(1).Info("skipped updating node")
 	}
 
-	// 7. update the config file on disk (if enabled – which is checked inside the updater)
+	// 9. update the config file on disk (if enabled – which is checked inside the updater) unless it drifted
+	// and the drift should only be reported
 	configFileUpdater := nodes.NewK3OSConfigFileUpdater(r.configuration)
-	updateErr := configFileUpdater.Update(nodeConfig)
-	if err := resultError(updateErr, r.logger); err != nil {
-		return ctrl.Result{}, err
+	drift, err := configFileUpdater.Drift()
+	if err != nil {
+		return ctrl.Result{}, r.failSync(nodeStatus, err)
+	}
+	reportDriftOnly := drift != "" && k3OSConfig.Spec.DriftPolicy == configv1alpha1.DriftPolicyReport
+	if drift != "" {
+		nodeStatus.ConfigFileDrift = &configv1alpha1.ConfigFileDrift{Diff: truncateDiff(drift)}
+		r.logger.Info("node config file on disk drifted", "diff", drift)
+		r.recordEvent(k3OSConfig, node, corev1.EventTypeWarning, events.ReasonConfigFileDriftDetected,
+			"node config file "+r.configuration.NodeConfigFileLocation+" on node "+nodeName+" was changed by someone else")
+	}
+	var updateErr error
+	start := time.Now()
+	switch {
+	case dryRun:
+		planned.ConfigFileDiff, updateErr = configFileUpdater.Plan(nodeConfig)
+	case reportDriftOnly:
+		updateErr = errors.ErrSkipUpdate
+	case drift != "" && k3OSConfig.Spec.DriftPolicy == configv1alpha1.DriftPolicyMerge:
+		var conflicts []string
+		conflicts, updateErr = configFileUpdater.Merge(nodeConfig)
+		if len(conflicts) > 0 {
+			nodeStatus.ConfigFileDrift.Conflicts = conflicts
+			r.recordEvent(k3OSConfig, node, corev1.EventTypeWarning, events.ReasonConfigFileMergeConflict,
+				fmt.Sprintf("kept the values on disk of the conflicting fields %v of node config file %s on node %s",
+					conflicts, r.configuration.NodeConfigFileLocation, nodeName))
+		}
+	default:
+		updateErr = configFileUpdater.Update(nodeConfig)
 	}
+	metrics.ObservePhase(nodeName, metrics.PhaseFile, start, updateErr)
 	switch {
-	case updateErr == nil: // would equal errors.ErrSkipUpdate if the update was skipped
+	case updateErr == nil && dryRun:
+		nodeStatus.ConfigFileUpdate = configv1alpha1.ConfigFilePlanned
+		planned.ConfigFileDiff = truncateDiff(planned.ConfigFileDiff)
+		planned.BootTimeChanges = configFileUpdater.BootTimeChanges()
+		r.logger.Info("planned node config changes on disk", "diff", planned.ConfigFileDiff)
+	case updateErr == nil:
+		nodeStatus.ConfigFileUpdate = configv1alpha1.ConfigFileUpdated
 		r.logger.Info("successfully updated node config on disk")
+		if drift != "" && k3OSConfig.Spec.DriftPolicy != configv1alpha1.DriftPolicyMerge {
+			nodeStatus.ConfigFileDrift.Corrected = true
+			r.recordEvent(k3OSConfig, node, corev1.EventTypeNormal, events.ReasonConfigFileDriftCorrected,
+				"overwrote drifted node config file "+r.configuration.NodeConfigFileLocation+" on node "+nodeName)
+		}
+		r.recordEvent(k3OSConfig, node, corev1.EventTypeNormal, events.ReasonConfigFileUpdated,
+			"rewrote node config file "+r.configuration.NodeConfigFileLocation+" on node "+nodeName)
 	case errors.Is(updateErr, errors.ErrSkipUpdate):
+		nodeStatus.ConfigFileUpdate = configv1alpha1.ConfigFileSkipped
+		if !r.configuration.EnableNodeConfigFileManagement() {
+			nodeStatus.ConfigFileUpdate = configv1alpha1.ConfigFileDisabled
+		} else if !reportDriftOnly {
+			r.recordEvent(k3OSConfig, node, corev1.EventTypeNormal, events.ReasonConfigFileSkipped,
+				"node config file "+r.configuration.NodeConfigFileLocation+" on node "+nodeName+" is up to date")
+		}
 		r.logger.V(1).Info("skipped updating node config on disk")
 	default:
-		r.logger.Error(updateErr, "failed to update node config on disk")
+		nodeStatus.ConfigFileUpdate = configv1alpha1.ConfigFileFailed
+		r.recordEvent(k3OSConfig, node, corev1.EventTypeWarning, events.ReasonConfigFileUpdateFailed, updateErr.Error())
+		return ctrl.Result{}, r.failSync(nodeStatus, updateErr)
+	}
+
+	if dryRun && !equality.Semantic.DeepEqual(planned, &configv1alpha1.K3OSConfigPlannedChanges{}) {
+		nodeStatus.Planned = planned
+	}
+
+	// 10. update the config.d drop-in files on disk (if enabled – which is checked inside the updater)
+	dropInUpdater := nodes.NewK3OSConfigDropInUpdater(r.configuration)
+	if r.configuration.EnableNodeConfigDropInManagement() && !dryRun {
+		var dropIns map[string][]byte
+		if dropIns, err = r.getNodeDropIns(ctx, nodeName); err != nil {
+			return ctrl.Result{}, r.failSync(nodeStatus, err)
+		}
+		start = time.Now()
+		updateErr = dropInUpdater.Update(dropIns)
+		metrics.ObservePhase(nodeName, metrics.PhaseDropIns, start, updateErr)
+	} else {
+		updateErr = errors.ErrSkipUpdate
+	}
+	switch {
+	case updateErr == nil:
+		nodeStatus.DropInUpdate = configv1alpha1.ConfigFileUpdated
+		r.logger.Info("successfully updated drop-in files on disk")
+		r.recordEvent(k3OSConfig, node, corev1.EventTypeNormal, events.ReasonDropInFilesUpdated,
+			"updated drop-in files in "+r.configuration.NodeConfigDropInDir+" on node "+nodeName)
+	case errors.Is(updateErr, errors.ErrSkipUpdate):
+		nodeStatus.DropInUpdate = configv1alpha1.ConfigFileSkipped
+		if !r.configuration.EnableNodeConfigDropInManagement() {
+			nodeStatus.DropInUpdate = configv1alpha1.ConfigFileDisabled
+		}
+		r.logger.V(1).Info("skipped updating drop-in files on disk")
+	default:
+		nodeStatus.DropInUpdate = configv1alpha1.ConfigFileFailed
+		r.recordEvent(k3OSConfig, node, corev1.EventTypeWarning, events.ReasonDropInFilesUpdateFailed, updateErr.Error())
+		return ctrl.Result{}, r.failSync(nodeStatus, updateErr)
+	}
+
+	// 11. reboot the node if changes to the config files require it and the leader approved it
+	if dryRun {
+		nodeStatus.BootTimeChanges = nodes.GetBootTimeChanges(node)
+		return ctrl.Result{}, nil
+	}
+	bootTimeChanges := append(configFileUpdater.BootTimeChanges(), dropInUpdater.BootTimeChanges()...)
+	if err = r.handleReboot(ctx, k3OSConfig, node, bootTimeChanges, nodeStatus); err != nil {
+		return ctrl.Result{}, r.failSync(nodeStatus, err)
+	}
+
+	return ctrl.Result{RequeueAfter: tainter.RequeueAfter()}, nil // escalate delayed taints once their grace period elapsed
+}
+
+// observeSync records the metrics of the node sync that started at start.
+func (r *K3OSConfigReconciler) observeSync(nodeStatus *configv1alpha1.K3OSConfigNodeStatus, start time.Time, err error) {
+	nodeName := nodeStatus.NodeName
+	if err == nil && nodeStatus.LastError != "" { // errors that aren't retried still fail the sync
+		err = errors.New(nodeStatus.LastError)
+	}
+	metrics.ObserveReconcile(nodeName, start, err)
+	if err == nil {
+		metrics.SetLastSuccessfulSync(nodeName, time.Now())
+	}
+	drift := nodeStatus.ConfigFileDrift != nil && !nodeStatus.ConfigFileDrift.Corrected
+	metrics.SetConfigDrift(nodeName, err != nil || drift || nodeStatus.Planned != nil || len(nodeStatus.BootTimeChanges) > 0)
+	if node, nodeErr := r.getNode(nodeName); nodeErr == nil {
+		metrics.SetManagedGauges(nodeName, len(nodes.ManagedLabels(node)), len(nodes.ManagedTaints(node)))
+	}
+}
+
+// recordEvent emits the Event on the node and the K3OSConfig.
+func (r *K3OSConfigReconciler) recordEvent(k3OSConfig *configv1alpha1.K3OSConfig, node *corev1.Node, eventType, reason, message string) {
+	r.recorder.Event(node, eventType, reason, message)
+	r.recorder.Event(k3OSConfig, eventType, reason, message)
+}
+
+// recordNodeEvents emits an Event for the labels and taints that were added, changed or removed.
+func (r *K3OSConfigReconciler) recordNodeEvents(k3OSConfig *configv1alpha1.K3OSConfig, node *corev1.Node, labelsBefore, updatedLabels, updatedTaints map[string]string) {
+	added, changed, removed := events.LabelChanges(labelsBefore, updatedLabels)
+	for _, e := range []struct {
+		reason, action string
+		items          []string
+	}{
+		{events.ReasonNodeLabelsAdded, "added", added},
+		{events.ReasonNodeLabelsChanged, "changed", changed},
+		{events.ReasonNodeLabelsRemoved, "removed", removed},
+	} {
+		if len(e.items) > 0 {
+			r.recordEvent(k3OSConfig, node, corev1.EventTypeNormal, e.reason, events.Message(e.action, "labels", e.items, node.GetName()))
+		}
+	}
+	added, changed, removed = events.TaintChanges(updatedTaints)
+	for _, e := range []struct {
+		reason, action string
+		items          []string
+	}{
+		{events.ReasonNodeTaintsAdded, "added", added},
+		{events.ReasonNodeTaintsChanged, "changed", changed},
+		{events.ReasonNodeTaintsRemoved, "removed", removed},
+	} {
+		if len(e.items) > 0 {
+			r.recordEvent(k3OSConfig, node, corev1.EventTypeNormal, e.reason, events.Message(e.action, "taints", e.items, node.GetName()))
+		}
+	}
+}
+
+// reportOwnershipError records that an annotation that keeps track of the labels, taints, annotations or spec fields the
+// operator added to the node couldn't be read in the node status (which marks the K3OSConfig as Degraded until the next
+// sync) and as an Event.
+func (r *K3OSConfigReconciler) reportOwnershipError(k3OSConfig *configv1alpha1.K3OSConfig, node *corev1.Node, nodeStatus *configv1alpha1.K3OSConfigNodeStatus, err error) {
+	if err == nil {
+		return
+	}
+	r.logger.Error(err, "repairing ownership annotation")
+	nodeStatus.OwnershipErrors = append(nodeStatus.OwnershipErrors, err.Error())
+	r.recordEvent(k3OSConfig, node, corev1.EventTypeWarning, events.ReasonOwnershipRepaired, err.Error())
+}
+
+// recordNodeAnnotationEvents emits the Events about the annotations updated on the node.
+func (r *K3OSConfigReconciler) recordNodeAnnotationEvents(k3OSConfig *configv1alpha1.K3OSConfig, node *corev1.Node, annotationsBefore, updatedAnnotations map[string]string) {
+	added, changed, removed := events.AnnotationChanges(annotationsBefore, updatedAnnotations)
+	for _, e := range []struct {
+		reason, action string
+		items          []string
+	}{
+		{events.ReasonNodeAnnotationsAdded, "added", added},
+		{events.ReasonNodeAnnotationsChanged, "changed", changed},
+		{events.ReasonNodeAnnotationsRemoved, "removed", removed},
+	} {
+		if len(e.items) > 0 {
+			r.recordEvent(k3OSConfig, node, corev1.EventTypeNormal, e.reason, events.Message(e.action, "annotations", e.items, node.GetName()))
+		}
 	}
+}
 
-	return ctrl.Result{}, nil
+// recordNodeSpecEvents emits the Event about the fields of the node spec updated on the node.
+func (r *K3OSConfigReconciler) recordNodeSpecEvents(k3OSConfig *configv1alpha1.K3OSConfig, node *corev1.Node, updatedFields map[string]string) {
+	if len(updatedFields) == 0 {
+		return
+	}
+	fields := make([]string, 0, len(updatedFields))
+	for field, value := range updatedFields {
+		fields = append(fields, field+"="+value)
+	}
+	sort.Strings(fields)
+	r.recordEvent(k3OSConfig, node, corev1.EventTypeNormal, events.ReasonNodeSpecUpdated, events.Message("changed", "spec fields", fields, node.GetName()))
+}
+
+// maxConfigFileDiffLength limits the size of the planned config file diff because it's stored in an annotation of the node.
+const maxConfigFileDiffLength = 32 * 1024
+
+func truncateDiff(diff string) string {
+	if len(diff) <= maxConfigFileDiffLength {
+		return diff
+	}
+	return diff[:maxConfigFileDiffLength] + "\n[diff truncated]\n"
+}
+
+// reportNodeStatus stores the sync status of the node this operator is running on in the sync report annotation
+// of the node where the leader picks it up. The report is only updated if it changed or is due.
+func (r *K3OSConfigReconciler) reportNodeStatus(ctx context.Context, k3OSConfig *configv1alpha1.K3OSConfig, nodeStatus *configv1alpha1.K3OSConfigNodeStatus, syncErr error) error {
+	now := metav1.Now()
+	nodeStatus.ObservedGeneration = k3OSConfig.GetGeneration()
+	nodeStatus.LastSyncTime = &now
+	if syncErr != nil && nodeStatus.LastError == "" {
+		nodeStatus.LastError = syncErr.Error()
+	}
+	if nodeStatus.LastError == "" {
+		nodeStatus.LastSuccessfulSyncTime = &now
+	}
+	report := &nodes.SyncReport{K3OSConfig: k3OSConfig.GetName(), K3OSConfigNodeStatus: *nodeStatus}
+
+	node, err := r.getNode(nodeStatus.NodeName)
+	if err != nil {
+		return err
+	}
+	previous, err := nodes.GetSyncReport(node)
+	if err != nil { // the corrupt report is overwritten below
+		r.logger.Error(err, "failed to read previous sync report")
+	}
+	if previous != nil && previous.K3OSConfig == report.K3OSConfig {
+		// keep the labels, taints, annotations and spec fields of the last sync that changed them
+		if len(report.UpdatedLabels) == 0 {
+			report.UpdatedLabels = previous.UpdatedLabels
+		}
+		if len(report.UpdatedTaints) == 0 {
+			report.UpdatedTaints = previous.UpdatedTaints
+		}
+		if len(report.UpdatedAnnotations) == 0 {
+			report.UpdatedAnnotations = previous.UpdatedAnnotations
+		}
+		if len(report.UpdatedSpecFields) == 0 {
+			report.UpdatedSpecFields = previous.UpdatedSpecFields
+		}
+		if report.LastSuccessfulSyncTime == nil {
+			report.LastSuccessfulSyncTime = previous.LastSuccessfulSyncTime
+		}
+		if report.EqualIgnoringTimes(previous) && previous.LastSyncTime != nil && now.Sub(previous.LastSyncTime.Time) < reportInterval(k3OSConfig) {
+			r.logger.V(1).Info("skipped reporting unchanged node status")
+			return nil
+		}
+	}
+
+	patch, err := nodes.SyncReportPatch(report)
+	if err != nil {
+		return err
+	}
+	_, err = r.clientset.CoreV1().Nodes().Patch(ctx, nodeStatus.NodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
 }
 
-func (r *K3OSConfigReconciler) getNodeConfig(ctx context.Context, nodeName string) (*configv1alpha1.K3OSConfigFileSpec, error) {
+// getNodeConfig returns the config of the node and its source. The entry of the node in the node config Secret
+// is replaced by the K3OSConfigFile of the node if there is one. The node config Secret is optional in that case.
+func (r *K3OSConfigReconciler) getNodeConfig(ctx context.Context, k3OSConfig *configv1alpha1.K3OSConfig, node *corev1.Node) (*configv1alpha1.K3OSConfigFileSpec, string, error) {
+	configFile, err := r.getK3OSConfigFile(ctx, node)
+	if err != nil {
+		return nil, "", err
+	}
+	data := map[string][]byte{}
+	source := "Secret/" + r.configuration.NodeConfigSecretName
 	secret, err := r.clientset.CoreV1().Secrets(r.namespace).Get(ctx, r.configuration.NodeConfigSecretName, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		for key, value := range secret.Data {
+			data[key] = value
+		}
+	case configFile == nil || !apierrors.IsNotFound(err):
+		return nil, "", err
+	}
+	if configFile != nil {
+		if data[node.GetName()], err = nodeconfig.ConfigFileData(configFile, r.resolveSecretKey(ctx)); err != nil {
+			return nil, "", err
+		}
+		source = configFile.ConfigSource()
+	}
+
+	var templateData *nodeconfig.TemplateData
+	if templating := k3OSConfig.Spec.Templating; templating != nil && templating.Enabled {
+		if templateData, err = r.getTemplateData(ctx, templating, node); err != nil {
+			return nil, "", err
+		}
+	}
+	nodeConfigBytes, err := nodeconfig.Merge(k3OSConfig.Spec.Layers, data, node, templateData)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", errors.ErrInvalidNodeConfig, err)
+	}
+	nodeConfig, err := configv1alpha1.ParseConfigYAML(nodeConfigBytes)
 	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", errors.ErrInvalidNodeConfig, err)
+	}
+	return nodeConfig, source, nil
+}
+
+// getK3OSConfigFile returns the K3OSConfigFile in the operator's namespace that contains the config of the node
+// or nil if there is none.
+func (r *K3OSConfigReconciler) getK3OSConfigFile(ctx context.Context, node *corev1.Node) (*configv1alpha1.K3OSConfigFile, error) {
+	configFiles := &configv1alpha1.K3OSConfigFileList{}
+	if err := r.client.List(ctx, configFiles, client.InNamespace(r.namespace)); err != nil {
 		return nil, err
 	}
-	nodeConfigBytes, ok := secret.Data[nodeName]
-	if !ok {
-		return nil, fmt.Errorf("failed to find node %q in config (keys: %v)", nodeName, secretKeys(secret))
+	return nodeconfig.SelectConfigFile(configFiles.Items, node)
+}
+
+// resolveSecretKey returns a resolver for the Secrets referenced by K3OSConfigFile objects.
+func (r *K3OSConfigReconciler) resolveSecretKey(ctx context.Context) nodeconfig.SecretKeyResolver {
+	return func(selector *corev1.SecretKeySelector) (string, error) {
+		optional := selector.Optional != nil && *selector.Optional
+		secret, err := r.clientset.CoreV1().Secrets(r.namespace).Get(ctx, selector.Name, metav1.GetOptions{})
+		if err != nil {
+			if optional && apierrors.IsNotFound(err) {
+				return "", nil
+			}
+			return "", err
+		}
+		value, ok := secret.Data[selector.Key]
+		if !ok && !optional {
+			return "", fmt.Errorf("key %q not found in Secret %q", selector.Key, selector.Name)
+		}
+		return string(value), nil
 	}
-	return configv1alpha1.ParseConfigYAML(nodeConfigBytes)
+}
+
+// getTemplateData returns the data the node config templates of the node are rendered with.
+func (r *K3OSConfigReconciler) getTemplateData(ctx context.Context, templating *configv1alpha1.K3OSConfigTemplating, node *corev1.Node) (*nodeconfig.TemplateData, error) {
+	values := map[string]string{}
+	if templating.ValuesConfigMap != "" {
+		configMap, err := r.clientset.CoreV1().ConfigMaps(r.namespace).Get(ctx, templating.ValuesConfigMap, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		values = configMap.Data
+	}
+	secretValues := map[string]string{}
+	if templating.ValuesSecret != "" {
+		secret, err := r.clientset.CoreV1().Secrets(r.namespace).Get(ctx, templating.ValuesSecret, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range secret.Data {
+			secretValues[key] = string(value)
+		}
+	}
+	return nodeconfig.NewTemplateData(node, values, secretValues), nil
+}
+
+// getNodeDropIns returns the drop-in files for the node. A missing drop-in Secret is treated as an empty one.
+func (r *K3OSConfigReconciler) getNodeDropIns(ctx context.Context, nodeName string) (map[string][]byte, error) {
+	secret, err := r.clientset.CoreV1().Secrets(r.namespace).Get(ctx, r.configuration.NodeConfigDropInSecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nodes.NodeDropIns(secret.Data, nodeName), nil
 }
 
 func (r *K3OSConfigReconciler) getNode(nodeName string) (*corev1.Node, error) {
@@ -194,17 +767,51 @@ func (r *K3OSConfigReconciler) getNode(nodeName string) (*corev1.Node, error) {
 	return node.DeepCopy(), nil
 }
 
-func (r *K3OSConfigReconciler) updateNode(ctx context.Context, node *corev1.Node) error {
-	if _, err := r.clientset.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{}); err != nil {
+// updateNode patches the labels, annotations, taints and spec fields of the node that changed compared to the original node.
+// The patch only fails if one of these fields was changed concurrently (see nodes.NodePatch).
+func (r *K3OSConfigReconciler) updateNode(ctx context.Context, original, node *corev1.Node) error {
+	patch, err := nodes.NodePatch(original, node)
+	if errors.Is(err, errors.ErrSkipUpdate) {
+		return nil
+	} else if err != nil {
 		return err
 	}
-	return nil
+	_, err = r.clientset.CoreV1().Nodes().Patch(ctx, node.GetName(), types.JSONPatchType, patch, metav1.PatchOptions{})
+	return err
 }
 
-func secretKeys(secret *corev1.Secret) []string {
-	keys := make([]string, 0, len(secret.Data))
-	for key := range secret.Data {
-		keys = append(keys, key)
+// errServerSideApplyUnsupported is returned when the API server rejected applying node labels with server-side apply.
+var errServerSideApplyUnsupported = errors.New("server-side apply is not supported")
+
+// useServerSideApply returns whether node labels are applied with server-side apply: it is enabled and the API
+// server didn't reject it before.
+func (r *K3OSConfigReconciler) useServerSideApply() bool {
+	return r.configuration.UseServerSideApply() && atomic.LoadInt32(&r.serverSideApplyUnsupported) == 0
+}
+
+// applyNodeLabels applies the labels of the labeler with server-side apply and migrates the labels that were
+// tracked in the labelsAdded annotation before. If the API server doesn't support server-side apply it falls back
+// to the annotation for the lifetime of the operator and returns errServerSideApplyUnsupported.
+func (r *K3OSConfigReconciler) applyNodeLabels(ctx context.Context, nodeName string, labeler nodes.ApplyLabeler) error {
+	patch, err := labeler.ApplyPatch()
+	if err != nil {
+		return err
+	}
+	force := true
+	_, err = r.clientset.CoreV1().Nodes().Patch(ctx, nodeName, types.ApplyPatchType, patch, metav1.PatchOptions{FieldManager: nodes.FieldManager, Force: &force})
+	if apierrors.IsUnsupportedMediaType(err) || apierrors.IsMethodNotSupported(err) {
+		atomic.StoreInt32(&r.serverSideApplyUnsupported, 1)
+		return fmt.Errorf("%w: %v", errServerSideApplyUnsupported, err)
+	} else if err != nil {
+		return err
 	}
-	return keys
+
+	if patch, err = labeler.MigrationPatch(); err != nil || patch == nil {
+		return err
+	}
+	if _, err = r.clientset.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to migrate node labels from the annotation: %w", err)
+	}
+	r.logger.Info("migrated node labels from the annotation to server-side apply")
+	return nil
 }
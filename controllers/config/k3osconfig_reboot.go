@@ -0,0 +1,152 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package config
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	"github.com/annismckenzie/k3os-config-operator/pkg/events"
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// allow the leader to drain nodes before rebooting them
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups=core,resources=pods/eviction,verbs=create
+
+// rebootPollInterval is the interval in which the leader checks on the progress of a node reboot.
+const rebootPollInterval = 10 * time.Second
+
+// rebootApprovalTimeout is the duration after which the leader withdraws the approval to reboot a node that wasn't
+// rebooted and Ready again so that a single node can't block the reboots of all other nodes.
+const rebootApprovalTimeout = 30 * time.Minute
+
+// handleReboot records the boot-time changes of the node config file on the node and reboots the node
+// once the leader approved it. The changes are forgotten if reboots are disabled.
+func (r *K3OSConfigReconciler) handleReboot(ctx context.Context, k3OSConfig *configv1alpha1.K3OSConfig, node *corev1.Node, changes []string, nodeStatus *configv1alpha1.K3OSConfigNodeStatus) (err error) {
+	if !k3OSConfig.Spec.RebootNodes {
+		nodeStatus.BootTimeChanges = nil
+		if err = r.patchNode(ctx, node, nodes.DisableRebootsPatch); err != nil && !errors.Is(err, errors.ErrSkipUpdate) {
+			return err
+		}
+		return nil
+	}
+
+	patch, err := nodes.RebootRequiredPatch(node, changes, r.rebooter != nil)
+	switch {
+	case errors.Is(err, errors.ErrSkipUpdate): // the annotations are up to date
+	case err != nil:
+		return err
+	default:
+		if node, err = r.clientset.CoreV1().Nodes().Patch(ctx, node.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return err
+		}
+	}
+	if len(changes) > 0 {
+		r.logger.Info("node config file changes require a reboot", "changes", changes, "rebootable", r.rebooter != nil)
+	}
+	nodeStatus.BootTimeChanges = nodes.GetBootTimeChanges(node)
+
+	if r.rebooter == nil || !nodes.RebootApproved(node) {
+		return nil
+	}
+	r.logger.Info("rebooting node", "changes", nodeStatus.BootTimeChanges)
+	return r.rebooter.Reboot(ctx)
+}
+
+// orchestrateReboots reboots the nodes with boot-time changes one at a time: the leader cordons and drains the node,
+// approves the reboot which the operator running on that node carries out and uncordons the node once it's Ready again.
+// Only nodes whose operator is able to reboot them are considered. The approval annotation acts as the lock: no other
+// node is touched while a node carries it. The lock is released and the node uncordoned if the node isn't rebooted
+// within rebootApprovalTimeout. It returns the duration after which the progress should be checked again.
+func (r *K3OSConfigReconciler) orchestrateReboots(ctx context.Context, config *configv1alpha1.K3OSConfig, nodeList []*corev1.Node) (time.Duration, error) {
+	if !config.Spec.RebootNodes || config.Spec.DryRun {
+		return 0, nil
+	}
+
+	sortedNodes := make([]*corev1.Node, len(nodeList))
+	copy(sortedNodes, nodeList)
+	sort.Slice(sortedNodes, func(i, j int) bool { return sortedNodes[i].GetName() < sortedNodes[j].GetName() })
+
+	var next *corev1.Node
+	for _, node := range sortedNodes {
+		if _, ok := node.GetAnnotations()[consts.RebootApprovedNodeAnnotation()]; ok {
+			completed := nodes.RebootCompleted(node)
+			if !completed && !nodes.RebootApprovalExpired(node, rebootApprovalTimeout, time.Now()) { // the reboot is still in progress
+				return rebootPollInterval, nil
+			}
+			if err := r.patchNode(ctx, node, nodes.FinishRebootPatch); err != nil {
+				return 0, err
+			}
+			if !completed {
+				r.logger.Info("node wasn't rebooted in time, withdrew the reboot approval", "node", node.GetName(), "timeout", rebootApprovalTimeout)
+				r.recordEvent(config, node, corev1.EventTypeWarning, events.ReasonRebootTimedOut,
+					"node "+node.GetName()+" wasn't rebooted within "+rebootApprovalTimeout.String()+", withdrew the reboot approval and uncordoned it")
+				return rebootPollInterval, nil
+			}
+			r.logger.Info("node was rebooted successfully", "node", node.GetName())
+			return rebootPollInterval, nil
+		}
+		if next == nil && nodes.Rebootable(node) && len(nodes.GetBootTimeChanges(node)) > 0 {
+			next = node
+		}
+	}
+	if next == nil {
+		return 0, nil
+	}
+
+	if err := r.patchNode(ctx, next, nodes.CordonPatch); err != nil && !errors.Is(err, errors.ErrSkipUpdate) {
+		return 0, err
+	}
+	drained, err := r.drainer.Drain(ctx, next.GetName())
+	if err != nil {
+		return 0, err
+	}
+	if !drained {
+		r.logger.V(1).Info("waiting for node to be drained", "node", next.GetName())
+		return rebootPollInterval, nil
+	}
+	if err = r.patchNode(ctx, next, nodes.ApproveRebootPatch); err != nil {
+		return 0, err
+	}
+	r.logger.Info("approved node reboot", "node", next.GetName(), "changes", nodes.GetBootTimeChanges(next))
+	return rebootPollInterval, nil
+}
+
+func (r *K3OSConfigReconciler) patchNode(ctx context.Context, node *corev1.Node, patchFunc func(*corev1.Node) ([]byte, error)) error {
+	patch, err := patchFunc(node)
+	if err != nil {
+		return err
+	}
+	_, err = r.clientset.CoreV1().Nodes().Patch(ctx, node.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
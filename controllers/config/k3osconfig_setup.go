@@ -33,6 +33,7 @@ import (
 	"github.com/annismckenzie/k3os-config-operator/config"
 	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
 	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodes"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -40,6 +41,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -59,8 +61,13 @@ type K3OSConfigReconciler struct {
 	scheme        *runtime.Scheme
 	leader        bool
 	nodeLister    listersv1.NodeLister
+	rebooter      nodes.Rebooter
+	drainer       nodes.Drainer
+	recorder      record.EventRecorder
 	shutdownCtx   context.Context
 	namespace     string
+
+	serverSideApplyUnsupported int32 // set atomically once the API server rejected server-side apply
 }
 
 // Option denotes an option for configuring this controller.
@@ -91,6 +98,26 @@ func WithConfiguration(configuration *config.Configuration) Option {
 	return &withConfigurationOpt{configuration: configuration}
 }
 
+type withRebooterOpt struct {
+	rebooter nodes.Rebooter
+}
+
+// WithRebooter returns an option to replace the rebooter that reboots the node the operator is running on.
+// By default the reboot command from the configuration is used.
+func WithRebooter(rebooter nodes.Rebooter) Option {
+	return &withRebooterOpt{rebooter: rebooter}
+}
+
+type withEventRecorderOpt struct {
+	recorder record.EventRecorder
+}
+
+// WithEventRecorder returns an option to replace the recorder of the Events emitted on nodes and K3OSConfig objects.
+// By default the event recorder of the manager is used.
+func WithEventRecorder(recorder record.EventRecorder) Option {
+	return &withEventRecorderOpt{recorder: recorder}
+}
+
 // https://github.com/kubernetes-sigs/controller-runtime/pull/921#issuecomment-662187521 doesn't work
 // but there's always another way 🥁 🥁 🥁.
 type nonLeaderLeaseNeedingManagerWrapper struct {
@@ -132,11 +159,26 @@ func (r *K3OSConfigReconciler) SetupWithManager(shutdownCtx context.Context, mgr
 		if configurationOpt, ok := option.(*withConfigurationOpt); ok {
 			r.configuration = configurationOpt.configuration
 		}
+		if rebooterOpt, ok := option.(*withRebooterOpt); ok {
+			r.rebooter = rebooterOpt.rebooter
+		}
+		if eventRecorderOpt, ok := option.(*withEventRecorderOpt); ok {
+			r.recorder = eventRecorderOpt.recorder
+		}
 	}
 
 	if r.configuration == nil {
 		return errors.New("the configuration must be provided via the WithConfiguration option")
 	}
+	if command := r.configuration.NodeRebootCommand(); r.rebooter == nil && len(command) > 0 {
+		if r.rebooter, err = nodes.NewCommandRebooter(command); err != nil {
+			return err
+		}
+	}
+	r.drainer = nodes.NewDrainer(clientset)
+	if r.recorder == nil {
+		r.recorder = mgr.GetEventRecorderFor("k3os-config-operator")
+	}
 
 	r.namespace = r.configuration.Namespace
 	r.logger = mgr.GetLogger().
@@ -144,13 +186,19 @@ func (r *K3OSConfigReconciler) SetupWithManager(shutdownCtx context.Context, mgr
 		WithName(configv1alpha1.K3OSConfigKind).
 		WithValues("podName", os.Getenv("HOSTNAME"), "leader", r.leader)
 
-	if r.leader { // if we're building the controller for the leader we can bail here
-		return ctrl.NewControllerManagedBy(mgr).For(&configv1alpha1.K3OSConfig{}).Complete(r)
+	if r.leader { // if we're building the controller for the leader we only need to watch the sync reports of all nodes
+		c := ctrl.NewControllerManagedBy(mgr).For(&configv1alpha1.K3OSConfig{})
+		c.Watches(&source.Kind{Type: &corev1.Node{}}, handler.EnqueueRequestsFromMapFunc(r.enqueueObjectsOnChanges), builder.OnlyMetadata)
+		// watch the K3OSConfigFile resources to fill in the status of new ones right away
+		c.Watches(&source.Kind{Type: &configv1alpha1.K3OSConfigFile{}}, handler.EnqueueRequestsFromMapFunc(r.enqueueObjectsOnChanges),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+		return c.Complete(r)
 	}
 
 	// wrap manager so this can run without a leader lease
 	mgr = &nonLeaderLeaseNeedingManagerWrapper{Manager: mgr}
-	c := ctrl.NewControllerManagedBy(mgr).For(&configv1alpha1.K3OSConfig{})
+	// only reconcile on spec changes because the leader updates the status of the K3OSConfig whenever a node reports back
+	c := ctrl.NewControllerManagedBy(mgr).For(&configv1alpha1.K3OSConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{}))
 
 	// construct a watch on the Secret resource that contains the node config.yaml files
 	opts := []builder.WatchesOption{
@@ -159,6 +207,20 @@ func (r *K3OSConfigReconciler) SetupWithManager(shutdownCtx context.Context, mgr
 	}
 	c.Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.enqueueObjectsOnChanges), opts...)
 
+	// construct a watch on the K3OSConfigFile resources that can replace the entries of the node config Secret
+	// (only on spec changes because the leader updates their status whenever a node reports back)
+	c.Watches(&source.Kind{Type: &configv1alpha1.K3OSConfigFile{}}, handler.EnqueueRequestsFromMapFunc(r.enqueueObjectsOnChanges),
+		builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	// construct a watch on the node config file and the config.d directory on disk to notice drift right away
+	if r.configuration.EnableNodeConfigFileManagement() {
+		watcher := nodes.NewConfigFileWatcher(r.configuration, r.logger.WithName("watcher"))
+		if err = mgr.Add(watcher); err != nil {
+			return err
+		}
+		c.Watches(&source.Channel{Source: watcher.Events()}, handler.EnqueueRequestsFromMapFunc(r.enqueueObjectsOnChanges))
+	}
+
 	// construct a watch on the Node this operator is running on
 	opts = []builder.WatchesOption{
 		builder.OnlyMetadata, // only watch and cache the metadata of the nodes because we don't need the contents
@@ -186,7 +248,7 @@ func labelSelectorPredicateForSecret() predicate.Predicate {
 }
 
 // enqueueObjectsOnChanges is used to enqueue all K3OSConfig resources in the operator's namespace when
-// changes happen to the watched resources (secrets, nodes).
+// changes happen to the watched resources (secrets, nodes, K3OSConfigFiles, node config files on disk).
 func (r *K3OSConfigReconciler) enqueueObjectsOnChanges(object client.Object) []reconcile.Request {
 	r.logger.V(1).Info("change to a watched object noticed", "namespace/name", client.ObjectKeyFromObject(object).String())
 
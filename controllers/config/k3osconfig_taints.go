@@ -0,0 +1,84 @@
+/*
+MIT License
+
+Copyright (c) 2021 Daniel Lohse
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package config
+
+import (
+	"context"
+	"sort"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodes"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// orchestrateNoExecuteTaints limits the number of nodes that carry taints with the NoExecute effect added by the operator
+// to the percentage of the taint policy: the leader approves the requests of the nodes in the order of their names as
+// long as the limit isn't reached. A node keeps its approval until it doesn't carry these taints anymore.
+func (r *K3OSConfigReconciler) orchestrateNoExecuteTaints(ctx context.Context, config *configv1alpha1.K3OSConfig, nodeList []*corev1.Node) error {
+	if config.Spec.DryRun {
+		return nil
+	}
+
+	sortedNodes := make([]*corev1.Node, len(nodeList))
+	copy(sortedNodes, nodeList)
+	sort.Slice(sortedNodes, func(i, j int) bool { return sortedNodes[i].GetName() < sortedNodes[j].GetName() })
+
+	var (
+		tainted int
+		waiting []*corev1.Node
+	)
+	for _, node := range sortedNodes {
+		approved, requested := nodes.NoExecuteApproved(node), nodes.NoExecuteRequested(node)
+		switch {
+		case nodes.ManagesNoExecuteTaints(node) || (approved && requested):
+			tainted++
+		case approved: // the node doesn't carry the taints anymore
+			if err := r.patchNode(ctx, node, nodes.ReleaseNoExecutePatch); err != nil {
+				return err
+			}
+			r.logger.V(1).Info("released NoExecute taint approval", "node", node.GetName())
+		}
+		if requested && !approved {
+			waiting = append(waiting, node)
+		}
+	}
+	if len(waiting) == 0 {
+		return nil
+	}
+
+	maxTainted := config.Spec.TaintPolicy.MaxNoExecuteNodes(len(nodeList))
+	for _, node := range waiting {
+		if tainted >= maxTainted {
+			r.logger.Info("waiting to approve NoExecute taints because the limit of nodes is reached", "node", node.GetName(), "maxNodes", maxTainted)
+			return nil
+		}
+		if err := r.patchNode(ctx, node, nodes.ApproveNoExecutePatch); err != nil {
+			return err
+		}
+		tainted++
+		r.logger.Info("approved NoExecute taints", "node", node.GetName())
+	}
+	return nil
+}
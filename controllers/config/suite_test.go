@@ -231,8 +231,8 @@ var _ = Describe("K3OSConfig controller", func() {
 			Expect(labels["newLabel"]).To(Equal("newLabelValue"))
 			checkTaint := &corev1.Taint{Key: "test", Effect: corev1.TaintEffectNoSchedule}
 			Expect(taints.TaintExists(updatedNode.Spec.Taints, checkTaint)).To(BeTrue(), "expected taint test:NoSchedule to exist")
-			Expect(annotations[consts.AddedLabelsNodeAnnotation()]).To(Equal("newLabel"))
-			Expect(annotations[consts.AddedTaintsNodeAnnotation()]).To(Equal("test:NoSchedule"))
+			Expect(annotations[consts.AddedLabelsNodeAnnotation()]).To(Equal(`{"version":1,"labels":["newLabel"]}`))
+			Expect(annotations[consts.AddedTaintsNodeAnnotation()]).To(Equal(`{"version":1,"taints":[{"key":"test","effect":"NoSchedule"}]}`))
 
 			updatedConfigFile, err := ioutil.ReadFile(tempConfigFile.Name())
 			Expect(err).ToNot(HaveOccurred())
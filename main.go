@@ -28,7 +28,9 @@ import (
 	"os"
 
 	"github.com/annismckenzie/k3os-config-operator/config"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
 	"github.com/annismckenzie/k3os-config-operator/pkg/nodes"
+	"github.com/annismckenzie/k3os-config-operator/pkg/webhooks"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
@@ -64,6 +66,11 @@ func main() {
 	ctrl.SetLogger(zap.New(zap.UseDevMode(configuration.EnableDevMode())))
 	ctx := ctrl.SetupSignalHandler()
 
+	if backup := configuration.RestoreNodeConfigBackup; backup != "" {
+		restoreNodeConfigBackup(configuration, backup)
+		return
+	}
+
 	if nodeName := configuration.NodeName; nodeName == "" {
 		setupLog.Info("unable to determine node name (is the NODE_NAME environment variable set?)")
 		os.Exit(1)
@@ -107,6 +114,18 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", configv1alpha1.K3OSConfigKind, "leader", false)
 		os.Exit(1)
 	}
+	if configuration.EnableWebhooks() {
+		if err = (&configv1alpha1.K3OSConfig{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", configv1alpha1.K3OSConfigKind)
+			os.Exit(1)
+		}
+		nodeConfigSecretWebhook, err := webhooks.NewNodeConfigSecretWebhook()
+		if err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "nodeConfigSecret")
+			os.Exit(1)
+		}
+		mgr.GetWebhookServer().Register(webhooks.NodeConfigSecretPath, nodeConfigSecretWebhook)
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -115,3 +134,19 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// restoreNodeConfigBackup restores the node config file from the given backup. The operator will overwrite the node
+// config file again on the next sync unless the node config in the Secret is changed as well.
+func restoreNodeConfigBackup(configuration *config.Configuration, backup string) {
+	logger := setupLog.WithValues("backup", backup, "location", configuration.NodeConfigFileLocation)
+	if configuration.NodeConfigFileLocation == "" {
+		logger.Info("unable to restore backup: the node config file location isn't set")
+		os.Exit(1)
+	}
+	updater := nodes.NewK3OSConfigFileUpdater(configuration)
+	if err := updater.RestoreBackup(backup); err != nil && !errors.Is(err, errors.ErrSkipUpdate) {
+		logger.Error(err, "unable to restore backup")
+		os.Exit(1)
+	}
+	logger.Info("successfully restored node config file from backup")
+}
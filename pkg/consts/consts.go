@@ -15,6 +15,65 @@ func AddedTaintsNodeAnnotation() string {
 	return consts.AddedTaintsNodeAnnotation
 }
 
+// AddedAnnotationsNodeAnnotation returns the annotation where annotations that the operator added are kept.
+func AddedAnnotationsNodeAnnotation() string {
+	return consts.AddedAnnotationsNodeAnnotation
+}
+
+// AddedSpecFieldsNodeAnnotation returns the annotation where the node spec fields that the operator set are kept.
+func AddedSpecFieldsNodeAnnotation() string {
+	return consts.AddedSpecFieldsNodeAnnotation
+}
+
+// SyncReportNodeAnnotation returns the annotation where the operator running on a node reports the sync status of the node.
+func SyncReportNodeAnnotation() string {
+	return consts.SyncReportNodeAnnotation
+}
+
+// RebootRequiredNodeAnnotation returns the annotation where the operator running on a node keeps the node config file
+// fields that changed since the node was last booted together with the boot ID of the node at that time.
+func RebootRequiredNodeAnnotation() string {
+	return consts.RebootRequiredNodeAnnotation
+}
+
+// RebootableNodeAnnotation returns the annotation that marks nodes whose operator is able to reboot them.
+func RebootableNodeAnnotation() string {
+	return consts.RebootableNodeAnnotation
+}
+
+// RebootApprovedNodeAnnotation returns the annotation where the leader stores the boot ID of the node it approved the reboot of.
+func RebootApprovedNodeAnnotation() string {
+	return consts.RebootApprovedNodeAnnotation
+}
+
+// RebootApprovedAtNodeAnnotation returns the annotation where the leader stores the time at which it approved the reboot.
+func RebootApprovedAtNodeAnnotation() string {
+	return consts.RebootApprovedAtNodeAnnotation
+}
+
+// RebootCordonedNodeAnnotation returns the annotation that marks nodes that the leader cordoned before rebooting them.
+func RebootCordonedNodeAnnotation() string {
+	return consts.RebootCordonedNodeAnnotation
+}
+
+// NoExecuteGraceNodeAnnotation returns the annotation where the operator running on a node keeps the times at which
+// the grace periods of its delayed taints with the NoExecute effect started.
+func NoExecuteGraceNodeAnnotation() string {
+	return consts.NoExecuteGraceNodeAnnotation
+}
+
+// NoExecuteRequestedNodeAnnotation returns the annotation that marks nodes that wait for the leader to approve adding
+// taints with the NoExecute effect.
+func NoExecuteRequestedNodeAnnotation() string {
+	return consts.NoExecuteRequestedNodeAnnotation
+}
+
+// NoExecuteApprovedNodeAnnotation returns the annotation that marks nodes that the leader approved adding taints
+// with the NoExecute effect to.
+func NoExecuteApprovedNodeAnnotation() string {
+	return consts.NoExecuteApprovedNodeAnnotation
+}
+
 // LabelSelectorForNodeConfigFileSecret returns the label selector for the k3OS node config file secret.
 func LabelSelectorForNodeConfigFileSecret() metav1.LabelSelector {
 	labelSelector := metav1.AddLabelToSelector(&metav1.LabelSelector{}, "app.kubernetes.io/managed-by", "k3os-config-operator")
@@ -8,6 +8,10 @@ import (
 // See documentation on errors.Is for more information.
 var Is = errors.Is
 
+// As finds the first error in err's chain that matches target, and if so, sets target to that error value and returns true.
+// See documentation on errors.As for more information.
+var As = errors.As
+
 // New returns an error that formats as the given text.
 // See documentation on errors.New for more information.
 var New = errors.New
@@ -17,3 +21,6 @@ var ErrSkipUpdate = errors.New("no updates required, skip")
 
 // ErrNilObjectPassed is returned if the caller passed a nil object.
 var ErrNilObjectPassed = errors.New("nil object was passed")
+
+// ErrInvalidNodeConfig is returned if the node config can't be parsed, merged or rendered or fails validation.
+var ErrInvalidNodeConfig = errors.New("invalid node config")
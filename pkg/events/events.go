@@ -0,0 +1,126 @@
+// Package events contains the reasons of the Events the operator emits and helpers to build their messages.
+// The reasons are part of the API of the operator: alerting can key on them so they must never change.
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Reasons of the Events emitted on the Node and the K3OSConfig when a node is synced.
+const (
+	// ReasonNodeLabelsAdded is emitted when labels of the node config were added to the node.
+	ReasonNodeLabelsAdded = "NodeLabelsAdded"
+	// ReasonNodeLabelsChanged is emitted when the values of labels of the node were changed.
+	ReasonNodeLabelsChanged = "NodeLabelsChanged"
+	// ReasonNodeLabelsRemoved is emitted when labels that were removed from the node config were removed from the node.
+	ReasonNodeLabelsRemoved = "NodeLabelsRemoved"
+	// ReasonNodeAnnotationsAdded is emitted when annotations of the node config were added to the node.
+	ReasonNodeAnnotationsAdded = "NodeAnnotationsAdded"
+	// ReasonNodeAnnotationsChanged is emitted when the values of annotations of the node were changed.
+	ReasonNodeAnnotationsChanged = "NodeAnnotationsChanged"
+	// ReasonNodeAnnotationsRemoved is emitted when annotations that were removed from the node config were removed from the node.
+	ReasonNodeAnnotationsRemoved = "NodeAnnotationsRemoved"
+	// ReasonNodeTaintsAdded is emitted when taints of the node config were added to the node.
+	ReasonNodeTaintsAdded = "NodeTaintsAdded"
+	// ReasonNodeTaintsChanged is emitted when taints of the node were changed.
+	ReasonNodeTaintsChanged = "NodeTaintsChanged"
+	// ReasonNodeTaintsRemoved is emitted when taints that were removed from the node config were removed from the node.
+	ReasonNodeTaintsRemoved = "NodeTaintsRemoved"
+	// ReasonNodeSpecUpdated is emitted when the unschedulable or providerID fields of the node spec were changed.
+	ReasonNodeSpecUpdated = "NodeSpecUpdated"
+	// ReasonNodeSpecConflict is emitted when fields of the node spec couldn't be changed to the values of the node config.
+	ReasonNodeSpecConflict = "NodeSpecConflict"
+	// ReasonOwnershipRepaired is emitted when an annotation that keeps track of the labels, taints, annotations or spec
+	// fields the operator added to the node couldn't be read and is repaired.
+	ReasonOwnershipRepaired = "OwnershipRepaired"
+	// ReasonConfigFileUpdated is emitted when the node config file on disk was rewritten.
+	ReasonConfigFileUpdated = "ConfigFileUpdated"
+	// ReasonConfigFileSkipped is emitted when the node config file on disk was already up to date.
+	ReasonConfigFileSkipped = "ConfigFileSkipped"
+	// ReasonConfigFileUpdateFailed is emitted when rewriting the node config file on disk failed.
+	ReasonConfigFileUpdateFailed = "ConfigFileUpdateFailed"
+	// ReasonConfigFileDriftDetected is emitted when the node config file on disk was changed by someone else.
+	ReasonConfigFileDriftDetected = "ConfigFileDriftDetected"
+	// ReasonConfigFileDriftCorrected is emitted when the operator overwrote a drifted node config file.
+	ReasonConfigFileDriftCorrected = "ConfigFileDriftCorrected"
+	// ReasonConfigFileMergeConflict is emitted when fields of a drifted node config file were changed in the desired config, too.
+	ReasonConfigFileMergeConflict = "ConfigFileMergeConflict"
+	// ReasonDropInFilesUpdated is emitted when config.d drop-in files on disk were written or removed.
+	ReasonDropInFilesUpdated = "DropInFilesUpdated"
+	// ReasonDropInFilesUpdateFailed is emitted when updating the config.d drop-in files on disk failed.
+	ReasonDropInFilesUpdateFailed = "DropInFilesUpdateFailed"
+	// ReasonRebootTimedOut is emitted when the leader withdrew the approval to reboot a node that wasn't rebooted in time.
+	ReasonRebootTimedOut = "RebootTimedOut"
+	// ReasonInvalidNodeConfig is emitted when the node config can't be parsed, merged, rendered or fails validation.
+	ReasonInvalidNodeConfig = "InvalidNodeConfig"
+	// ReasonSyncFailed is emitted when syncing the node failed for any other reason.
+	ReasonSyncFailed = "SyncFailed"
+)
+
+// removedLabelValue is the value the labeler reports for removed labels.
+const removedLabelValue = "(removed)"
+
+// LabelChanges splits the labels updated by the labeler into added, changed and removed ones based on the
+// labels the node had before. The entries are formatted as key=value (or key for removed labels) and sorted.
+func LabelChanges(before, updated map[string]string) (added, changed, removed []string) {
+	for key, value := range updated {
+		_, existed := before[key]
+		switch {
+		case value == removedLabelValue:
+			removed = append(removed, key)
+		case existed:
+			changed = append(changed, key+"="+value)
+		default:
+			added = append(added, key+"="+value)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return added, changed, removed
+}
+
+// AnnotationChanges splits the annotations updated by the annotator into added, changed and removed ones based on
+// the annotations the node had before. Only the keys are returned because values can be large. They are sorted.
+func AnnotationChanges(before, updated map[string]string) (added, changed, removed []string) {
+	for key, value := range updated {
+		_, existed := before[key]
+		switch {
+		case value == removedLabelValue:
+			removed = append(removed, key)
+		case existed:
+			changed = append(changed, key)
+		default:
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return added, changed, removed
+}
+
+// TaintChanges splits the taints updated by the tainter into added, changed and removed ones. The entries are sorted.
+func TaintChanges(updated map[string]string) (added, changed, removed []string) {
+	for taint, change := range updated {
+		switch change {
+		case "added":
+			added = append(added, taint)
+		case "changed":
+			changed = append(changed, taint)
+		case "removed":
+			removed = append(removed, taint)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return added, changed, removed
+}
+
+// Message returns the message of an Event about the items on the node, e.g. `added labels a=b, c=d on node n1`.
+func Message(action, kind string, items []string, nodeName string) string {
+	return fmt.Sprintf("%s %s %s on node %s", action, kind, strings.Join(items, ", "), nodeName)
+}
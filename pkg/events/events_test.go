@@ -0,0 +1,56 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelChanges(t *testing.T) {
+	before := map[string]string{"kept": "1", "changed": "old", "removed": "x", "unmanaged": "y"}
+	updated := map[string]string{"changed": "new", "removed": "(removed)", "added": "a", "also-added": "b"}
+
+	added, changed, removed := LabelChanges(before, updated)
+	if want := []string{"added=a", "also-added=b"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("LabelChanges() added = %v, want %v", added, want)
+	}
+	if want := []string{"changed=new"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("LabelChanges() changed = %v, want %v", changed, want)
+	}
+	if want := []string{"removed"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("LabelChanges() removed = %v, want %v", removed, want)
+	}
+}
+
+func TestAnnotationChanges(t *testing.T) {
+	before := map[string]string{"changed": "old", "removed": "x"}
+	updated := map[string]string{"changed": `{"large":"value"}`, "removed": "(removed)", "added": "a"}
+
+	added, changed, removed := AnnotationChanges(before, updated)
+	if want := []string{"added"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("AnnotationChanges() added = %v, want %v", added, want)
+	}
+	if want := []string{"changed"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("AnnotationChanges() changed = %v, want %v", changed, want)
+	}
+	if want := []string{"removed"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("AnnotationChanges() removed = %v, want %v", removed, want)
+	}
+}
+
+func TestTaintChanges(t *testing.T) {
+	updated := map[string]string{"b=c:NoSchedule": "added", "a=b:NoSchedule": "added", "d:NoExecute": "changed", "e=f:PreferNoSchedule": "removed"}
+
+	added, changed, removed := TaintChanges(updated)
+	if want := []string{"a=b:NoSchedule", "b=c:NoSchedule"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("TaintChanges() added = %v, want %v", added, want)
+	}
+	if want := []string{"d:NoExecute"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("TaintChanges() changed = %v, want %v", changed, want)
+	}
+	if want := []string{"e=f:PreferNoSchedule"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("TaintChanges() removed = %v, want %v", removed, want)
+	}
+	if got, want := Message("added", "taints", added, "n1"), "added taints a=b:NoSchedule, b=c:NoSchedule on node n1"; got != want {
+		t.Errorf("Message() = %q, want %q", got, want)
+	}
+}
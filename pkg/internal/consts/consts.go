@@ -5,7 +5,8 @@ import (
 )
 
 const (
-	// NodeAnnotationValueSeparator is the string that is used as the separator for the added labels and taints annotations.
+	// NodeAnnotationValueSeparator is the string that is used as the separator for the values of list annotations (and of the
+	// added labels and taints annotations before they were migrated to a JSON record).
 	NodeAnnotationValueSeparator = ","
 )
 
@@ -20,4 +21,44 @@ var (
 
 	// AddedTaintsNodeAnnotation is the annotation where taints that the operator added are kept.
 	AddedTaintsNodeAnnotation = AnnotationPrefix + "/taintsAdded"
+
+	// AddedAnnotationsNodeAnnotation is the annotation where annotations that the operator added are kept.
+	AddedAnnotationsNodeAnnotation = AnnotationPrefix + "/annotationsAdded"
+
+	// AddedSpecFieldsNodeAnnotation is the annotation where the node spec fields that the operator set are kept.
+	AddedSpecFieldsNodeAnnotation = AnnotationPrefix + "/specFieldsAdded"
+
+	// SyncReportNodeAnnotation is the annotation where the operator running on a node reports the sync status of the node.
+	SyncReportNodeAnnotation = AnnotationPrefix + "/syncReport"
+
+	// RebootRequiredNodeAnnotation is the annotation where the operator running on a node keeps the node config file
+	// fields that changed since the node was last booted together with the boot ID of the node at that time.
+	RebootRequiredNodeAnnotation = AnnotationPrefix + "/rebootRequired"
+
+	// RebootableNodeAnnotation is the annotation that marks nodes whose operator is able to reboot them.
+	// The leader only approves reboots of nodes that carry this annotation.
+	RebootableNodeAnnotation = AnnotationPrefix + "/rebootable"
+
+	// RebootApprovedNodeAnnotation is the annotation where the leader stores the boot ID of the node it approved the reboot of.
+	// Only one node in the cluster carries this annotation at a time.
+	RebootApprovedNodeAnnotation = AnnotationPrefix + "/rebootApproved"
+
+	// RebootApprovedAtNodeAnnotation is the annotation where the leader stores the time at which it approved the reboot.
+	// The approval is withdrawn if the node isn't rebooted in time.
+	RebootApprovedAtNodeAnnotation = AnnotationPrefix + "/rebootApprovedAt"
+
+	// RebootCordonedNodeAnnotation is the annotation that marks nodes that the leader cordoned before rebooting them.
+	RebootCordonedNodeAnnotation = AnnotationPrefix + "/rebootCordoned"
+
+	// NoExecuteGraceNodeAnnotation is the annotation where the operator running on a node keeps the times at which the grace
+	// periods of its delayed taints with the NoExecute effect started.
+	NoExecuteGraceNodeAnnotation = AnnotationPrefix + "/noExecuteGrace"
+
+	// NoExecuteRequestedNodeAnnotation is the annotation that marks nodes that wait for the leader to approve adding
+	// taints with the NoExecute effect.
+	NoExecuteRequestedNodeAnnotation = AnnotationPrefix + "/noExecuteRequested"
+
+	// NoExecuteApprovedNodeAnnotation is the annotation that marks nodes that the leader approved adding taints with
+	// the NoExecute effect to. It is kept as long as the node carries them.
+	NoExecuteApprovedNodeAnnotation = AnnotationPrefix + "/noExecuteApproved"
 )
@@ -0,0 +1,142 @@
+// Package metrics contains the Prometheus metrics of the operator. They are registered with the registry of
+// controller-runtime and served on the metrics endpoint of the manager (--metrics-bind-address).
+package metrics
+
+import (
+	"time"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "k3os_config_operator"
+
+// Phases of a node sync.
+const (
+	PhaseLabels      = "labels"
+	PhaseTaints      = "taints"
+	PhaseAnnotations = "annotations"
+	PhaseSpec        = "spec"
+	PhaseNode        = "node"
+	PhaseFile        = "file"
+	PhaseDropIns     = "dropins"
+)
+
+// Results of a reconcile or a phase of a node sync.
+const (
+	ResultSuccess = "success"
+	ResultSkipped = "skipped"
+	ResultError   = "error"
+)
+
+// Classes of errors that are logged but not returned to controller-runtime (and thus not retried).
+const (
+	ErrorClassForbidden  = "forbidden"
+	ErrorClassPermission = "permission"
+	ErrorClassNotFound   = "not_found"
+)
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_total",
+		Help:      "Number of node syncs by node and result.",
+	}, []string{"node", "result"})
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of node syncs by node.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"node"})
+	phaseTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_phase_total",
+		Help:      "Number of node sync phases (labels, taints, annotations, spec, node, file, dropins) by node, phase and result.",
+	}, []string{"node", "phase", "result"})
+	phaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_phase_duration_seconds",
+		Help:      "Duration of node sync phases by node and phase.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"node", "phase"})
+	managedLabels = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "managed_labels",
+		Help:      "Number of node labels managed by the operator.",
+	}, []string{"node"})
+	managedTaints = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "managed_taints",
+		Help:      "Number of node taints managed by the operator.",
+	}, []string{"node"})
+	lastSuccessfulSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_sync_timestamp_seconds",
+		Help:      "Unix time of the last successful node sync.",
+	}, []string{"node"})
+	configDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_drift",
+		Help:      "Whether the node didn't converge to its config after the last sync (1) or did (0): the sync failed, the node config file drifted, changes are only planned (dry run) or wait for a reboot.",
+	}, []string{"node"})
+	swallowedErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "swallowed_errors_total",
+		Help:      "Number of errors that were logged but not retried by class (forbidden, permission, not_found).",
+	}, []string{"class"})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(reconcileTotal, reconcileDuration, phaseTotal, phaseDuration,
+		managedLabels, managedTaints, lastSuccessfulSync, configDrift, swallowedErrors)
+}
+
+// Result returns the result of an operation based on its error. errors.ErrSkipUpdate counts as skipped.
+func Result(err error) string {
+	switch {
+	case err == nil:
+		return ResultSuccess
+	case errors.Is(err, errors.ErrSkipUpdate):
+		return ResultSkipped
+	default:
+		return ResultError
+	}
+}
+
+// ObserveReconcile records the result and duration of a node sync that started at start.
+func ObserveReconcile(nodeName string, start time.Time, err error) {
+	reconcileTotal.WithLabelValues(nodeName, Result(err)).Inc()
+	reconcileDuration.WithLabelValues(nodeName).Observe(time.Since(start).Seconds())
+}
+
+// ObservePhase records the result and duration of a phase of a node sync that started at start.
+func ObservePhase(nodeName, phase string, start time.Time, err error) {
+	phaseTotal.WithLabelValues(nodeName, phase, Result(err)).Inc()
+	phaseDuration.WithLabelValues(nodeName, phase).Observe(time.Since(start).Seconds())
+}
+
+// SetLastSuccessfulSync records the time of the last successful node sync.
+func SetLastSuccessfulSync(nodeName string, t time.Time) {
+	lastSuccessfulSync.WithLabelValues(nodeName).Set(float64(t.Unix()))
+}
+
+// SetConfigDrift records whether the node didn't converge to its config after the last sync.
+func SetConfigDrift(nodeName string, drift bool) {
+	value := 0.0
+	if drift {
+		value = 1
+	}
+	configDrift.WithLabelValues(nodeName).Set(value)
+}
+
+// SetManagedGauges records the number of labels and taints the operator manages on the node.
+func SetManagedGauges(nodeName string, labels, taints int) {
+	managedLabels.WithLabelValues(nodeName).Set(float64(labels))
+	managedTaints.WithLabelValues(nodeName).Set(float64(taints))
+}
+
+// CountSwallowedError records an error of the class that was logged but not retried.
+func CountSwallowedError(class string) {
+	swallowedErrors.WithLabelValues(class).Inc()
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestResult(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: nil, want: ResultSuccess},
+		{err: errors.ErrSkipUpdate, want: ResultSkipped},
+		{err: fmt.Errorf("wrapped: %w", errors.ErrSkipUpdate), want: ResultSkipped},
+		{err: errors.New("failed"), want: ResultError},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(fmt.Sprint(tt.err), func(t *testing.T) {
+			if got := Result(tt.err); got != tt.want {
+				t.Errorf("Result() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObservePhase(t *testing.T) {
+	start := time.Now()
+	ObservePhase("n1", PhaseLabels, start, nil)
+	ObservePhase("n1", PhaseLabels, start, errors.ErrSkipUpdate)
+	ObservePhase("n1", PhaseLabels, start, errors.ErrSkipUpdate)
+
+	if got := testutil.ToFloat64(phaseTotal.WithLabelValues("n1", PhaseLabels, ResultSkipped)); got != 2 {
+		t.Errorf("sync_phase_total{result=skipped} = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(phaseTotal.WithLabelValues("n1", PhaseLabels, ResultSuccess)); got != 1 {
+		t.Errorf("sync_phase_total{result=success} = %v, want 1", got)
+	}
+}
+
+func TestSetManagedGauges(t *testing.T) {
+	SetManagedGauges("n2", 3, 0)
+
+	if got := testutil.ToFloat64(managedLabels.WithLabelValues("n2")); got != 3 {
+		t.Errorf("managed_labels = %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(managedTaints.WithLabelValues("n2")); got != 0 {
+		t.Errorf("managed_taints = %v, want 0", got)
+	}
+}
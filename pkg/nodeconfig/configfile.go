@@ -0,0 +1,69 @@
+package nodeconfig
+
+import (
+	"fmt"
+	"sort"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SecretKeyResolver returns the value of the key of a Secret in the namespace of the operator.
+type SecretKeyResolver func(selector *corev1.SecretKeySelector) (string, error)
+
+// SelectConfigFile returns the K3OSConfigFile that contains the config of the node: the one named after the node
+// or else the one whose node selector matches the node. It returns nil if there is none and an error if the node
+// is selected by more than one.
+func SelectConfigFile(configFiles []configv1alpha1.K3OSConfigFile, node *corev1.Node) (*configv1alpha1.K3OSConfigFile, error) {
+	var selected []*configv1alpha1.K3OSConfigFile
+	for i := range configFiles {
+		configFile := &configFiles[i]
+		if configFile.GetName() == node.GetName() {
+			return configFile, nil
+		}
+		if configFile.Spec.NodeSelector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(configFile.Spec.NodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node selector of K3OSConfigFile %q: %w", configFile.GetName(), err)
+		}
+		if selector.Matches(labels.Set(node.GetLabels())) {
+			selected = append(selected, configFile)
+		}
+	}
+	switch len(selected) {
+	case 0:
+		return nil, nil
+	case 1:
+		return selected[0], nil
+	default:
+		names := make([]string, 0, len(selected))
+		for _, configFile := range selected {
+			names = append(names, configFile.GetName())
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("node %q is selected by more than one K3OSConfigFile: %v", node.GetName(), names)
+	}
+}
+
+// ConfigFileData returns the k3OS config.yaml of the K3OSConfigFile. The token and password are resolved from
+// their Secrets if they are referenced.
+func ConfigFileData(configFile *configv1alpha1.K3OSConfigFile, resolve SecretKeyResolver) ([]byte, error) {
+	spec := configFile.Spec.DeepCopy()
+	var err error
+	if ref := spec.K3OS.TokenFrom; ref != nil {
+		if spec.K3OS.Token, err = resolve(ref); err != nil {
+			return nil, fmt.Errorf("failed to resolve the token of K3OSConfigFile %q: %w", configFile.GetName(), err)
+		}
+	}
+	if ref := spec.K3OS.PasswordFrom; ref != nil {
+		if spec.K3OS.Password, err = resolve(ref); err != nil {
+			return nil, fmt.Errorf("failed to resolve the password of K3OSConfigFile %q: %w", configFile.GetName(), err)
+		}
+	}
+	return yaml.Marshal(spec)
+}
@@ -0,0 +1,164 @@
+package nodeconfig
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func configFile(name string, selector map[string]string) configv1alpha1.K3OSConfigFile {
+	configFile := configv1alpha1.K3OSConfigFile{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if selector != nil {
+		configFile.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: selector}
+	}
+	return configFile
+}
+
+func TestSelectConfigFile(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "n1", Labels: map[string]string{"role": "worker", "arch": "arm64"}}}
+
+	tests := []struct {
+		name        string
+		configFiles []configv1alpha1.K3OSConfigFile
+		want        string
+		wantErr     string
+	}{
+		{
+			name:        "no config files",
+			configFiles: nil,
+		},
+		{
+			name:        "no config file matches",
+			configFiles: []configv1alpha1.K3OSConfigFile{configFile("n2", nil), configFile("masters", map[string]string{"role": "master"})},
+		},
+		{
+			name:        "config file without node selector only applies to the node with the same name",
+			configFiles: []configv1alpha1.K3OSConfigFile{configFile("workers", nil)},
+		},
+		{
+			name:        "config file named after the node",
+			configFiles: []configv1alpha1.K3OSConfigFile{configFile("n1", nil)},
+			want:        "n1",
+		},
+		{
+			name:        "config file selecting the node",
+			configFiles: []configv1alpha1.K3OSConfigFile{configFile("masters", map[string]string{"role": "master"}), configFile("workers", map[string]string{"role": "worker"})},
+			want:        "workers",
+		},
+		{
+			name: "config file named after the node takes precedence",
+			configFiles: []configv1alpha1.K3OSConfigFile{
+				configFile("workers", map[string]string{"role": "worker"}),
+				configFile("arm", map[string]string{"arch": "arm64"}),
+				configFile("n1", nil),
+			},
+			want: "n1",
+		},
+		{
+			name: "node selected by more than one config file",
+			configFiles: []configv1alpha1.K3OSConfigFile{
+				configFile("workers", map[string]string{"role": "worker"}),
+				configFile("arm", map[string]string{"arch": "arm64"}),
+			},
+			wantErr: `node "n1" is selected by more than one K3OSConfigFile: [arm workers]`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectConfigFile(tt.configFiles, node)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("SelectConfigFile() error = %v, wantErr %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectConfigFile() unexpected error = %v", err)
+			}
+			var name string
+			if got != nil {
+				name = got.GetName()
+			}
+			if name != tt.want {
+				t.Errorf("SelectConfigFile() = %q, want %q", name, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigFileData(t *testing.T) {
+	secrets := map[string]map[string]string{"cluster": {"token": "secret-token", "password": "secret-password"}}
+	resolve := func(selector *corev1.SecretKeySelector) (string, error) {
+		value, ok := secrets[selector.Name][selector.Key]
+		if !ok {
+			return "", fmt.Errorf("key %q not found in Secret %q", selector.Key, selector.Name)
+		}
+		return value, nil
+	}
+
+	tests := []struct {
+		name    string
+		k3os    configv1alpha1.K3OSConfigFileSectionK3OS
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "inline token",
+			k3os: configv1alpha1.K3OSConfigFileSectionK3OS{Token: "inline-token"},
+			want: []string{"token: inline-token"},
+		},
+		{
+			name: "token and password from Secret",
+			k3os: configv1alpha1.K3OSConfigFileSectionK3OS{
+				Token:        "inline-token",
+				TokenFrom:    &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "cluster"}, Key: "token"},
+				PasswordFrom: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "cluster"}, Key: "password"},
+			},
+			want: []string{"token: secret-token", "password: secret-password"},
+		},
+		{
+			name:    "missing Secret key",
+			k3os:    configv1alpha1.K3OSConfigFileSectionK3OS{TokenFrom: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "cluster"}, Key: "missing"}},
+			wantErr: `failed to resolve the token of K3OSConfigFile "n1": key "missing" not found in Secret "cluster"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := &configv1alpha1.K3OSConfigFile{ObjectMeta: metav1.ObjectMeta{Name: "n1"}}
+			configFile.Spec.Hostname = "n1"
+			configFile.Spec.K3OS = tt.k3os
+			data, err := ConfigFileData(configFile, resolve)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ConfigFileData() error = %v, wantErr %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConfigFileData() unexpected error = %v", err)
+			}
+			for _, want := range append(tt.want, "hostname: n1") {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("ConfigFileData() = %s, want it to contain %q", data, want)
+				}
+			}
+			for _, unwanted := range []string{"tokenFrom", "passwordFrom", "nodeSelector"} {
+				if strings.Contains(string(data), unwanted) {
+					t.Errorf("ConfigFileData() = %s, must not contain %q", data, unwanted)
+				}
+			}
+			if _, err = configv1alpha1.ParseConfigYAML(data); err != nil {
+				t.Errorf("ConfigFileData() returned invalid config: %v", err)
+			}
+			if configFile.Spec.K3OS.Token != tt.k3os.Token {
+				t.Errorf("ConfigFileData() modified the passed K3OSConfigFile")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package nodeconfig
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Entry is an entry of the node config Secret read from a file.
+type Entry struct {
+	// Key is the key of the entry in the node config Secret.
+	Key string
+	// File is the name of the file the entry was read from.
+	File string
+	// Data contains the contents of the file.
+	Data []byte
+}
+
+// ReadDirectory reads the entries of the node config Secret from the YAML files (*.yaml and *.yml) in the directory.
+// A file is keyed by its name without the extension except for files named after the MAC address of a node as used
+// by picl-k3os-image-generator (e.g. dc:a6:32:01:02:03.yaml), which are keyed by their hostname. Subdirectories,
+// hidden files and kustomization files are ignored. The entries are returned sorted by their key.
+func ReadDirectory(dir string) ([]Entry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	filesByKey := map[string]string{}
+	for _, file := range files {
+		name := file.Name()
+		ext := filepath.Ext(name)
+		if file.IsDir() || strings.HasPrefix(name, ".") || (ext != ".yaml" && ext != ".yml") || strings.TrimSuffix(name, ext) == "kustomization" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		key, err := entryKey(strings.TrimSuffix(name, ext), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if other, ok := filesByKey[key]; ok {
+			return nil, fmt.Errorf("%s and %s both contain the config of %q", other, name, key)
+		}
+		filesByKey[key] = name
+		entries = append(entries, Entry{Key: key, File: name, Data: data})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// Data returns the data of the node config Secret containing the entries.
+func Data(entries []Entry) map[string][]byte {
+	data := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		data[entry.Key] = entry.Data
+	}
+	return data
+}
+
+// entryKey returns the key in the node config Secret of the file with the given name (without its extension).
+func entryKey(name string, data []byte) (string, error) {
+	if _, err := net.ParseMAC(name); err != nil {
+		return name, nil
+	}
+	config := struct {
+		Hostname string `yaml:"hostname"`
+	}{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("failed to read the hostname: %w", err)
+	}
+	if config.Hostname == "" {
+		return "", fmt.Errorf("the hostname must be set in files named after the MAC address of a node")
+	}
+	return config.Hostname, nil
+}
@@ -0,0 +1,115 @@
+package nodeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadDirectory(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		wantKeys []string
+		wantErr  string
+	}{
+		{
+			name:     "empty directory",
+			files:    map[string]string{},
+			wantKeys: nil,
+		},
+		{
+			name: "files named after nodes and layers",
+			files: map[string]string{
+				"n1.yaml":   "hostname: n1\n",
+				"n2.yml":    "hostname: n2\n",
+				"base.yaml": "k3os:\n  dns_nameservers:\n  - 1.1.1.1\n",
+			},
+			wantKeys: []string{"base", "n1", "n2"},
+		},
+		{
+			name: "files named after MAC addresses are keyed by their hostname",
+			files: map[string]string{
+				"dc:a6:32:01:02:03.yaml": "hostname: n1\n",
+				"dc-a6-32-01-02-04.yaml": "hostname: n2\n",
+			},
+			wantKeys: []string{"n1", "n2"},
+		},
+		{
+			name: "other files are ignored",
+			files: map[string]string{
+				"n1.yaml":            "hostname: n1\n",
+				"README.md":          "# nodes\n",
+				".n2.yaml":           "hostname: n2\n",
+				"kustomization.yaml": "secretGenerator: []\n",
+				"nested/n3.yaml":     "hostname: n3\n",
+			},
+			wantKeys: []string{"n1"},
+		},
+		{
+			name:    "file named after a MAC address without hostname",
+			files:   map[string]string{"dc:a6:32:01:02:03.yaml": "k3os: {}\n"},
+			wantErr: "dc:a6:32:01:02:03.yaml: the hostname must be set",
+		},
+		{
+			name:    "file named after a MAC address with invalid YAML",
+			files:   map[string]string{"dc:a6:32:01:02:03.yaml": "hostname: [n1\n"},
+			wantErr: "dc:a6:32:01:02:03.yaml: failed to read the hostname",
+		},
+		{
+			name: "two files for the same node",
+			files: map[string]string{
+				"dc:a6:32:01:02:03.yaml": "hostname: n1\n",
+				"n1.yaml":                "hostname: n1\n",
+			},
+			wantErr: `dc:a6:32:01:02:03.yaml and n1.yaml both contain the config of "n1"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			entries, err := ReadDirectory(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadDirectory() error = %v, wantErr %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadDirectory() error = %v", err)
+			}
+			var keys []string
+			for _, entry := range entries {
+				keys = append(keys, entry.Key)
+				if string(entry.Data) != tt.files[entry.File] {
+					t.Errorf("ReadDirectory() entry %q has data %q, want the contents of %s", entry.Key, entry.Data, entry.File)
+				}
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("ReadDirectory() keys = %v, want %v", keys, tt.wantKeys)
+			}
+			if data := Data(entries); len(data) != len(entries) {
+				t.Errorf("Data() has %d entries, want %d", len(data), len(entries))
+			}
+		})
+	}
+}
+
+func TestReadDirectoryMissing(t *testing.T) {
+	if _, err := ReadDirectory(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("ReadDirectory() error = %v, want a not exist error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package nodeconfig
+
+import (
+	"fmt"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Layers returns the layers of the config of the node from the entries of the node config Secret: the base layer,
+// the layers of the groups that select the node and the entry named after the node. Without layers only the entry
+// named after the node is returned. The entry named after the node is optional if there is any other layer.
+func Layers(spec *configv1alpha1.K3OSConfigLayers, data map[string][]byte, node *corev1.Node) ([]Layer, error) {
+	var layers []Layer
+	if spec != nil {
+		if spec.Base != "" {
+			layer, err := secretLayer(data, spec.Base, "base")
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, layer)
+		}
+		for i := range spec.Groups {
+			group := &spec.Groups[i]
+			selector, err := metav1.LabelSelectorAsSelector(&group.NodeSelector)
+			if err != nil {
+				return nil, fmt.Errorf("invalid node selector of group %q: %w", group.Name, err)
+			}
+			if !selector.Matches(labels.Set(node.GetLabels())) {
+				continue
+			}
+			layer, err := secretLayer(data, group.Key, "group "+group.Name)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, layer)
+		}
+	}
+
+	nodeData, ok := data[node.GetName()]
+	switch {
+	case ok:
+		layers = append(layers, Layer{Name: "node " + node.GetName(), Data: nodeData})
+	case len(layers) == 0:
+		return nil, fmt.Errorf("failed to find node %q in config (keys: %v)", node.GetName(), keys(data))
+	}
+	return layers, nil
+}
+
+// Merge returns the config of the node merged from its layers. If template data is passed every layer is
+// rendered as a template with it before merging.
+func Merge(spec *configv1alpha1.K3OSConfigLayers, data map[string][]byte, node *corev1.Node, templateData *TemplateData) ([]byte, error) {
+	layers, err := Layers(spec, data, node)
+	if err != nil {
+		return nil, err
+	}
+	if templateData != nil {
+		for i := range layers {
+			if layers[i].Data, err = Render(layers[i].Name, layers[i].Data, templateData); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if spec == nil || len(layers) == 1 && layers[0].Name == "node "+node.GetName() { // nothing to merge, keep the entry as is
+		return layers[0].Data, nil
+	}
+	return NewMerger(spec.ListMergeStrategies...).Merge(layers...)
+}
+
+func secretLayer(data map[string][]byte, key, name string) (Layer, error) {
+	value, ok := data[key]
+	if !ok {
+		return Layer{}, fmt.Errorf("failed to find the %s layer %q in config (keys: %v)", name, key, keys(data))
+	}
+	return Layer{Name: name, Data: value}, nil
+}
+
+func keys(data map[string][]byte) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,141 @@
+// Package nodeconfig merges k3OS node config files from several layers.
+package nodeconfig
+
+import (
+	"fmt"
+	"reflect"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultListMergeStrategies contains the merge strategies of the lists that aren't replaced by default.
+var defaultListMergeStrategies = []configv1alpha1.ListMergeStrategy{
+	{Path: "ssh_authorized_keys", Strategy: configv1alpha1.ListMergeUnion},
+	{Path: "boot_cmd", Strategy: configv1alpha1.ListMergeAppend},
+	{Path: "init_cmd", Strategy: configv1alpha1.ListMergeAppend},
+	{Path: "run_cmd", Strategy: configv1alpha1.ListMergeAppend},
+	{Path: "k3os.modules", Strategy: configv1alpha1.ListMergeUnion},
+	{Path: "write_files", Strategy: configv1alpha1.ListMergeKeyed, MergeKey: "path"},
+	{Path: "k3os.wifi", Strategy: configv1alpha1.ListMergeKeyed, MergeKey: "name"},
+}
+
+// Layer is a partial node config file.
+type Layer struct {
+	// Name identifies the layer in errors.
+	Name string
+	// Data contains the YAML of the layer.
+	Data []byte
+}
+
+// Merger merges layers of node config files.
+type Merger struct {
+	strategies map[string]configv1alpha1.ListMergeStrategy
+}
+
+// NewMerger returns a Merger that uses the given list merge strategies on top of the default ones.
+func NewMerger(strategies ...configv1alpha1.ListMergeStrategy) *Merger {
+	m := &Merger{strategies: map[string]configv1alpha1.ListMergeStrategy{}}
+	for _, strategy := range append(append([]configv1alpha1.ListMergeStrategy{}, defaultListMergeStrategies...), strategies...) {
+		m.strategies[strategy.Path] = strategy
+	}
+	return m
+}
+
+// Merge merges the layers in order: later layers take precedence over earlier ones. Maps are merged deeply,
+// lists according to their merge strategy and all other values are replaced. A null value removes the field.
+func (m *Merger) Merge(layers ...Layer) ([]byte, error) {
+	merged := map[string]interface{}{}
+	for _, layer := range layers {
+		config := map[string]interface{}{}
+		if err := yaml.Unmarshal(layer.Data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse layer %q: %w", layer.Name, err)
+		}
+		merged = m.mergeMaps("", merged, config)
+	}
+	return yaml.Marshal(merged)
+}
+
+func (m *Merger) mergeMaps(path string, dst, src map[string]interface{}) map[string]interface{} {
+	for key, value := range src {
+		childPath := key
+		if path != "" {
+			childPath = path + "." + key
+		}
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+		dst[key] = m.mergeValues(childPath, dst[key], value)
+	}
+	return dst
+}
+
+func (m *Merger) mergeValues(path string, dst, src interface{}) interface{} {
+	switch srcValue := src.(type) {
+	case map[string]interface{}:
+		if dstValue, ok := dst.(map[string]interface{}); ok {
+			return m.mergeMaps(path, dstValue, srcValue)
+		}
+	case []interface{}:
+		if dstValue, ok := dst.([]interface{}); ok {
+			return m.mergeLists(path, dstValue, srcValue)
+		}
+	}
+	return src
+}
+
+func (m *Merger) mergeLists(path string, dst, src []interface{}) []interface{} {
+	strategy, ok := m.strategies[path]
+	if !ok {
+		return src
+	}
+	switch strategy.Strategy {
+	case configv1alpha1.ListMergeAppend:
+		return append(append([]interface{}{}, dst...), src...)
+	case configv1alpha1.ListMergeUnion:
+		merged := append([]interface{}{}, dst...)
+		for _, item := range src {
+			if indexOf(merged, item) < 0 {
+				merged = append(merged, item)
+			}
+		}
+		return merged
+	case configv1alpha1.ListMergeKeyed:
+		merged := append([]interface{}{}, dst...)
+		for _, item := range src {
+			if index := indexOfKey(merged, strategy.MergeKey, item); index >= 0 {
+				merged[index] = m.mergeValues(path+"[]", merged[index], item)
+				continue
+			}
+			merged = append(merged, item)
+		}
+		return merged
+	default:
+		return src
+	}
+}
+
+// indexOfKey returns the index of the item in the list that has the same value of the merge key as the given item.
+func indexOfKey(list []interface{}, mergeKey string, item interface{}) int {
+	itemMap, ok := item.(map[string]interface{})
+	if !ok || itemMap[mergeKey] == nil {
+		return -1
+	}
+	for i, candidate := range list {
+		if candidateMap, ok := candidate.(map[string]interface{}); ok && reflect.DeepEqual(candidateMap[mergeKey], itemMap[mergeKey]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexOf returns the index of the item in the list or -1 if the list doesn't contain it.
+func indexOf(list []interface{}, item interface{}) int {
+	for i, candidate := range list {
+		if reflect.DeepEqual(candidate, item) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,231 @@
+package nodeconfig
+
+import (
+	"strings"
+	"testing"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	baseLayer = `ssh_authorized_keys:
+- github:annismckenzie
+write_files:
+- path: /etc/motd
+  content: base
+- path: /etc/issue
+  content: base
+k3os:
+  ntp_servers:
+  - 0.pool.ntp.org
+  - 1.pool.ntp.org
+  dns_nameservers:
+  - 1.1.1.1
+  sysctl:
+    kernel.printk: 4 4 1 7
+  token: secret-token
+  wifi:
+  - name: home
+    passphrase: secret-passphrase
+`
+	groupLayer = `write_files:
+- path: /etc/motd
+  content: group
+  permissions: "0644"
+k3os:
+  modules:
+  - kvm
+  sysctl:
+    vm.swappiness: "10"
+  labels:
+    group: workers
+`
+	nodeLayer = `hostname: n1
+ssh_authorized_keys:
+- github:someone
+k3os:
+  ntp_servers:
+  - time.example.com
+  dns_nameservers: null
+  labels:
+    region: shelf-1
+`
+	mergedLayers = `hostname: n1
+k3os:
+    labels:
+        group: workers
+        region: shelf-1
+    modules:
+        - kvm
+    ntp_servers:
+        - time.example.com
+    sysctl:
+        kernel.printk: 4 4 1 7
+        vm.swappiness: "10"
+    token: secret-token
+    wifi:
+        - name: home
+          passphrase: secret-passphrase
+ssh_authorized_keys:
+    - github:annismckenzie
+    - github:someone
+write_files:
+    - content: group
+      path: /etc/motd
+      permissions: "0644"
+    - content: base
+      path: /etc/issue
+`
+)
+
+func TestMerger_Merge(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategies []configv1alpha1.ListMergeStrategy
+		layers     []Layer
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:   "default strategies",
+			layers: []Layer{{Name: "base", Data: []byte(baseLayer)}, {Name: "group", Data: []byte(groupLayer)}, {Name: "node", Data: []byte(nodeLayer)}},
+			want:   mergedLayers,
+		},
+		{
+			name: "overridden strategies",
+			strategies: []configv1alpha1.ListMergeStrategy{
+				{Path: "ssh_authorized_keys", Strategy: configv1alpha1.ListMergeReplace},
+				{Path: "k3os.ntp_servers", Strategy: configv1alpha1.ListMergeAppend},
+			},
+			layers: []Layer{
+				{Name: "base", Data: []byte("ssh_authorized_keys:\n- github:a\nk3os:\n  ntp_servers:\n  - a\n")},
+				{Name: "node", Data: []byte("ssh_authorized_keys:\n- github:b\nk3os:\n  ntp_servers:\n  - b\n")},
+			},
+			want: "k3os:\n    ntp_servers:\n        - a\n        - b\nssh_authorized_keys:\n    - github:b\n",
+		},
+		{
+			name: "items present in both layers are kept once in unions but not in appended lists",
+			layers: []Layer{
+				{Name: "base", Data: []byte("ssh_authorized_keys:\n- github:a\n- github:b\nk3os:\n  modules:\n  - kvm\nrun_cmd:\n- sysctl -w vm.max_map_count=262144\n- modprobe kvm\n")},
+				{Name: "node", Data: []byte("ssh_authorized_keys:\n- github:b\n- github:c\nk3os:\n  modules:\n  - nvme\n  - kvm\nrun_cmd:\n- sysctl -w vm.max_map_count=262144\n")},
+			},
+			want: "k3os:\n    modules:\n        - kvm\n        - nvme\nrun_cmd:\n    - sysctl -w vm.max_map_count=262144\n    - modprobe kvm\n    - sysctl -w vm.max_map_count=262144\nssh_authorized_keys:\n    - github:a\n    - github:b\n    - github:c\n",
+		},
+		{
+			name:    "invalid layer",
+			layers:  []Layer{{Name: "base", Data: []byte(baseLayer)}, {Name: "node", Data: []byte("k3os: [")}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := NewMerger(tt.strategies...).Merge(tt.layers...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Merge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(merged) != tt.want {
+				t.Errorf("Merge() =\n%s\nwant\n%s", merged, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	data := map[string][]byte{
+		"base":    []byte(baseLayer),
+		"workers": []byte(groupLayer),
+		"n1":      []byte(nodeLayer),
+	}
+	layers := &configv1alpha1.K3OSConfigLayers{
+		Base: "base",
+		Groups: []configv1alpha1.K3OSConfigLayerGroup{
+			{Name: "workers", Key: "workers", NodeSelector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}}},
+		},
+	}
+	worker := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"role": "worker"}}}
+	}
+
+	tests := []struct {
+		name         string
+		layers       *configv1alpha1.K3OSConfigLayers
+		data         map[string][]byte
+		node         *corev1.Node
+		want         string
+		wantContains string
+		wantErr      string
+	}{
+		{
+			name: "without layers",
+			data: data,
+			node: worker("n1"),
+			want: nodeLayer,
+		},
+		{
+			name:    "without layers and node entry",
+			data:    data,
+			node:    worker("n2"),
+			wantErr: `failed to find node "n2"`,
+		},
+		{
+			name:   "all layers",
+			layers: layers,
+			data:   data,
+			node:   worker("n1"),
+			want:   mergedLayers,
+		},
+		{
+			name:         "node that isn't selected by the group and has no entry",
+			layers:       layers,
+			data:         data,
+			node:         &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "n2"}},
+			wantContains: "dns_nameservers:\n        - 1.1.1.1\n",
+		},
+		{
+			name:    "missing base layer",
+			layers:  &configv1alpha1.K3OSConfigLayers{Base: "missing"},
+			data:    data,
+			node:    worker("n1"),
+			wantErr: `failed to find the base layer "missing"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := Merge(tt.layers, tt.data, tt.node, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Merge() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Merge() error = %v", err)
+			}
+			if tt.wantContains != "" {
+				if !strings.Contains(string(merged), tt.wantContains) || strings.Contains(string(merged), "modules") {
+					t.Errorf("Merge() =\n%s\nwant only the base layer containing\n%s", merged, tt.wantContains)
+				}
+				return
+			}
+			if string(merged) != tt.want {
+				t.Errorf("Merge() =\n%s\nwant\n%s", merged, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactLines(t *testing.T) {
+	redactedData := string(RedactLines([]byte(mergedLayers)))
+	for _, secret := range []string{"secret-token", "secret-passphrase"} {
+		if strings.Contains(redactedData, secret) {
+			t.Errorf("RedactLines() = %s, should not contain %q", redactedData, secret)
+		}
+	}
+	if strings.Count(redactedData, "\n") != strings.Count(mergedLayers, "\n") || !strings.Contains(redactedData, "  token: REDACTED\n") {
+		t.Errorf("RedactLines() should keep the layout of the file, got %s", redactedData)
+	}
+}
@@ -0,0 +1,16 @@
+package nodeconfig
+
+import "regexp"
+
+// redacted replaces the values of secret fields.
+const redacted = "REDACTED"
+
+// secretLine matches the lines of secret fields in YAML data regardless of their section.
+var secretLine = regexp.MustCompile(`(?m)^(\s*(?:-\s+)?(?:password|token|passphrase):[ \t]*)\S.*$`)
+
+// RedactLines returns the node config file with the values of all password, token and passphrase fields replaced.
+// It keeps the layout of the file intact so it can be used for diffs. It doesn't parse the file and errs
+// on the side of redacting too much.
+func RedactLines(data []byte) []byte {
+	return secretLine.ReplaceAll(data, []byte("${1}"+redacted))
+}
@@ -0,0 +1,33 @@
+package nodeconfig
+
+import (
+	"bytes"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Normalize returns the normalized form of the node config file: keys are sorted, comments and formatting are dropped.
+func Normalize(data []byte) ([]byte, error) {
+	var config interface{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if config == nil { // an empty file or one that only contains comments
+		return []byte{}, nil
+	}
+	return yaml.Marshal(config)
+}
+
+// SemanticEqual returns whether both node config files contain the same values regardless of the order of their keys,
+// comments and formatting. Files that can't be parsed are compared byte by byte.
+func SemanticEqual(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var configA, configB interface{}
+	if yaml.Unmarshal(a, &configA) != nil || yaml.Unmarshal(b, &configB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(configA, configB)
+}
@@ -0,0 +1,39 @@
+package nodeconfig
+
+import "testing"
+
+func TestSemanticEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{name: "equal bytes", a: "hostname: a\n", b: "hostname: a\n", want: true},
+		{name: "trailing newline", a: "hostname: a", b: "hostname: a\n\n", want: true},
+		{name: "reordered keys", a: "hostname: a\nrun_cmd:\n- echo\n", b: "run_cmd: [echo]\nhostname: a\n", want: true},
+		{name: "comments", a: "# managed by the operator\nhostname: a # the hostname\n", b: "hostname: a\n", want: true},
+		{name: "reordered list items", a: "run_cmd:\n- a\n- b\n", b: "run_cmd:\n- b\n- a\n", want: false},
+		{name: "different values", a: "hostname: a\n", b: "hostname: b\n", want: false},
+		{name: "different types", a: "k3os:\n  labels:\n    a: \"1\"\n", b: "k3os:\n  labels:\n    a: 1\n", want: false},
+		{name: "empty files", a: "", b: "# nothing\n", want: true},
+		{name: "invalid YAML", a: "a: [", b: "a: [\n", want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SemanticEqual([]byte(tt.a), []byte(tt.b)); got != tt.want {
+				t.Errorf("SemanticEqual() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	normalized, err := Normalize([]byte("# comment\nrun_cmd: [echo]\nhostname: a # the hostname\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hostname: a\nrun_cmd:\n    - echo\n"; string(normalized) != want {
+		t.Errorf("Normalize() = %q, want %q", normalized, want)
+	}
+}
@@ -0,0 +1,144 @@
+package nodeconfig
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TemplateData is the data node config templates are rendered with.
+type TemplateData struct {
+	// Node contains the facts of the node the config is rendered for.
+	Node NodeFacts
+	// Values contains the data of the values ConfigMap.
+	Values map[string]string
+	// Secret contains the data of the values Secret.
+	Secret map[string]string
+}
+
+// NodeFacts contains the facts of a node that are available to node config templates.
+type NodeFacts struct {
+	// Name is the name of the node.
+	Name string
+	// Labels contains the labels of the node.
+	Labels map[string]string
+	// Addresses contains the addresses of the node keyed by their type (e.g. InternalIP, Hostname).
+	Addresses map[string]string
+	// Architecture is the architecture reported by the node (e.g. arm64).
+	Architecture string
+	// OperatingSystem is the operating system reported by the node (e.g. linux).
+	OperatingSystem string
+	// Capacity contains the capacity of the node keyed by resource name (e.g. cpu, memory).
+	Capacity map[string]string
+}
+
+// NewTemplateData returns the data to render the node config templates for the node with.
+func NewTemplateData(node *corev1.Node, values, secret map[string]string) *TemplateData {
+	facts := NodeFacts{
+		Name:            node.GetName(),
+		Labels:          map[string]string{},
+		Addresses:       map[string]string{},
+		Architecture:    node.Status.NodeInfo.Architecture,
+		OperatingSystem: node.Status.NodeInfo.OperatingSystem,
+		Capacity:        map[string]string{},
+	}
+	for key, value := range node.GetLabels() {
+		facts.Labels[key] = value
+	}
+	for _, address := range node.Status.Addresses {
+		if _, ok := facts.Addresses[string(address.Type)]; !ok { // the first address of every type wins
+			facts.Addresses[string(address.Type)] = address.Address
+		}
+	}
+	for name, quantity := range node.Status.Capacity {
+		facts.Capacity[string(name)] = quantity.String()
+	}
+	if values == nil {
+		values = map[string]string{}
+	}
+	if secret == nil {
+		secret = map[string]string{}
+	}
+	return &TemplateData{Node: facts, Values: values, Secret: secret}
+}
+
+// Render renders the node config template. Referencing missing fields or map keys is an error,
+// use `index` to look up optional keys.
+func Render(name string, data []byte, templateData *TemplateData) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(funcMap).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %q: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, templateData); err != nil {
+		return nil, fmt.Errorf("failed to render template %q: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// TemplatePlaceholder is what StubTemplate replaces template actions with.
+const TemplatePlaceholder = "__template__"
+
+var templateActionRegexp = regexp.MustCompile(`(?s){{.*?}}`)
+
+// StubTemplate checks that the node config template parses and stubs out its actions so the YAML around them can be
+// validated without rendering the template for a node: lines that only contain actions (e.g. `{{- if ... }}` and
+// `{{- end }}`) are dropped, all other actions are replaced with TemplatePlaceholder.
+func StubTemplate(name string, data []byte) ([]byte, error) {
+	if _, err := template.New(name).Funcs(funcMap).Parse(string(data)); err != nil {
+		return nil, fmt.Errorf("failed to parse template %q: %w", name, err)
+	}
+	lines := strings.Split(templateActionRegexp.ReplaceAllString(string(data), "\x00"), "\n")
+	stubbed := lines[:0]
+	for _, line := range lines {
+		if strings.Contains(line, "\x00") && strings.TrimSpace(strings.ReplaceAll(line, "\x00", "")) == "" {
+			continue
+		}
+		stubbed = append(stubbed, strings.ReplaceAll(line, "\x00", TemplatePlaceholder))
+	}
+	return []byte(strings.Join(stubbed, "\n")), nil
+}
+
+var funcMap = template.FuncMap{
+	"default": func(defaultValue, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return defaultValue
+		}
+		return value
+	},
+	"required": func(message string, value interface{}) (interface{}, error) {
+		if value == nil || value == "" {
+			return nil, fmt.Errorf("required value missing: %s", message)
+		}
+		return value, nil
+	},
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"hasPrefix": func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix": func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"split":     func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":      func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"quote":     func(s string) string { return fmt.Sprintf("%q", s) },
+	"indent": func(spaces int, s string) string {
+		padding := strings.Repeat(" ", spaces)
+		return padding + strings.ReplaceAll(s, "\n", "\n"+padding)
+	},
+	"fromYaml": func(s string) (map[string]interface{}, error) {
+		m := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(s), &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	},
+	"toYaml": func(v interface{}) (string, error) {
+		data, err := yaml.Marshal(v)
+		return strings.TrimSuffix(string(data), "\n"), err
+	},
+}
@@ -0,0 +1,181 @@
+package nodeconfig
+
+import (
+	"strings"
+	"testing"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func templateNode() *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "n1", Labels: map[string]string{"mac": "dc-a6-32-00-00-01"}},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{Architecture: "arm64", OperatingSystem: "linux"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.2"},
+				{Type: corev1.NodeHostName, Address: "n1"},
+			},
+			Capacity: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	templateData := NewTemplateData(templateNode(), map[string]string{
+		"zones": "dc-a6-32-00-00-01: shelf-1\ndc-a6-32-00-00-02: shelf-2\n",
+	}, map[string]string{"token": "secret"})
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "node facts",
+			template: "hostname: {{ .Node.Name }}-{{ .Node.Architecture }}\n# {{ .Node.Addresses.InternalIP }} {{ .Node.Capacity.memory }} {{ .Node.OperatingSystem }}\n",
+			want:     "hostname: n1-arm64\n# 10.0.0.1 4Gi linux\n",
+		},
+		{
+			name: "zone label from a MAC table",
+			template: `{{- $zones := fromYaml .Values.zones -}}
+k3os:
+  labels:
+    topology.kubernetes.io/zone: {{ index $zones (index .Node.Labels "mac") }}
+  token: {{ .Secret.token | quote }}
+`,
+			want: "k3os:\n  labels:\n    topology.kubernetes.io/zone: shelf-1\n  token: \"secret\"\n",
+		},
+		{
+			name:     "arch specific k3s args",
+			template: "k3os:\n  k3s_args:\n  {{- if eq .Node.Architecture \"arm64\" }}\n  - --kubelet-arg=feature-gates=X=true\n  {{- end }}\n",
+			want:     "k3os:\n  k3s_args:\n  - --kubelet-arg=feature-gates=X=true\n",
+		},
+		{
+			name:     "optional values",
+			template: "hostname: {{ index .Values \"hostname\" | default .Node.Name }}\n",
+			want:     "hostname: n1\n",
+		},
+		{
+			name:     "missing value",
+			template: "hostname: {{ .Values.hostname }}\n",
+			wantErr:  `failed to render template "node n1"`,
+		},
+		{
+			name:     "required value",
+			template: "hostname: {{ index .Values \"hostname\" | required \"hostname\" }}\n",
+			wantErr:  "required value missing: hostname",
+		},
+		{
+			name:     "broken template",
+			template: "hostname: {{ .Node.Name\n",
+			wantErr:  `failed to parse template "node n1"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := Render("node n1", []byte(tt.template), templateData)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if string(rendered) != tt.want {
+				t.Errorf("Render() =\n%s\nwant\n%s", rendered, tt.want)
+			}
+		})
+	}
+}
+
+func TestStubTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "no actions",
+			template: "hostname: n1\n",
+			want:     "hostname: n1\n",
+		},
+		{
+			name: "actions in values and on their own lines",
+			template: `{{- $zones := fromYaml .Values.zones }}
+hostname: {{ .Node.Name }}
+k3os:
+  labels:
+    topology.kubernetes.io/zone: {{ index $zones .Node.Name | default "unknown" }}
+  k3s_args:
+  {{- if eq .Node.Architecture "arm64" }}
+  - --kubelet-arg=image-gc-high-threshold=70
+  {{- end }}
+`,
+			want: `hostname: __template__
+k3os:
+  labels:
+    topology.kubernetes.io/zone: __template__
+  k3s_args:
+  - --kubelet-arg=image-gc-high-threshold=70
+`,
+		},
+		{
+			name:     "comment spanning lines",
+			template: "{{/* the hostname\n   is the node name */}}\nhostname: n1-{{ .Node.Name }}\n",
+			want:     "hostname: n1-__template__\n",
+		},
+		{
+			name:     "broken template",
+			template: "hostname: {{ .Node.Name\n",
+			wantErr:  `failed to parse template "n1"`,
+		},
+		{
+			name:     "unknown function",
+			template: "hostname: {{ .Node.Name | unknown }}\n",
+			wantErr:  `function "unknown" not defined`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			stubbed, err := StubTemplate("n1", []byte(tt.template))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("StubTemplate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StubTemplate() error = %v", err)
+			}
+			if string(stubbed) != tt.want {
+				t.Errorf("StubTemplate() =\n%s\nwant\n%s", stubbed, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge_templates(t *testing.T) {
+	data := map[string][]byte{
+		"base": []byte("hostname: {{ .Node.Name }}\n"),
+		"n1":   []byte("k3os:\n  labels:\n    arch: {{ .Node.Architecture }}\n"),
+	}
+	node := templateNode()
+	merged, err := Merge(&configv1alpha1.K3OSConfigLayers{Base: "base"}, data, node, NewTemplateData(node, nil, nil))
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if want := "hostname: n1\nk3os:\n    labels:\n        arch: arm64\n"; string(merged) != want {
+		t.Errorf("Merge() =\n%s\nwant\n%s", merged, want)
+	}
+}
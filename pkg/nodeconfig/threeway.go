@@ -0,0 +1,102 @@
+package nodeconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ThreeWayMerge merges the changes between the last applied and the desired node config file into the node config file
+// on disk like `kubectl apply`: fields the desired config didn't change keep their value on disk (including local edits),
+// fields only the desired config changed are applied and fields both changed in different ways are conflicts. Maps are
+// merged deeply, lists and all other values are compared as a whole. Conflicting fields keep their value on disk and
+// their paths are returned. It returns whether the merged config differs semantically from the one on disk.
+func ThreeWayMerge(lastApplied, current, desired []byte) (merged []byte, changed bool, conflicts []string, err error) {
+	var base, local, want map[string]interface{}
+	for _, c := range []struct {
+		name string
+		data []byte
+		dst  *map[string]interface{}
+	}{
+		{"last applied", lastApplied, &base},
+		{"current", current, &local},
+		{"desired", desired, &want},
+	} {
+		*c.dst = map[string]interface{}{}
+		if err = yaml.Unmarshal(c.data, c.dst); err != nil {
+			return nil, false, nil, fmt.Errorf("failed to parse %s node config file: %w", c.name, err)
+		}
+	}
+
+	result, conflicts := mergeThreeWay("", base, local, want)
+	sort.Strings(conflicts)
+	if reflect.DeepEqual(result, local) {
+		return current, false, conflicts, nil
+	}
+	if merged, err = yaml.Marshal(result); err != nil {
+		return nil, false, nil, err
+	}
+	return merged, true, conflicts, nil
+}
+
+func mergeThreeWay(path string, base, local, desired map[string]interface{}) (map[string]interface{}, []string) {
+	var conflicts []string
+	result := map[string]interface{}{}
+	for key := range unionMapKeys(base, local, desired) {
+		childPath := key
+		if path != "" {
+			childPath = path + "." + key
+		}
+		baseValue, inBase := base[key]
+		localValue, inLocal := local[key]
+		desiredValue, inDesired := desired[key]
+
+		switch {
+		case inBase == inDesired && reflect.DeepEqual(baseValue, desiredValue): // unchanged by the desired config, keep local edits
+			if inLocal {
+				result[key] = localValue
+			}
+		case inBase == inLocal && reflect.DeepEqual(baseValue, localValue), // unchanged locally, apply the desired change
+			inLocal == inDesired && reflect.DeepEqual(localValue, desiredValue): // same change on both sides
+			if inDesired {
+				result[key] = desiredValue
+			}
+		default: // changed on both sides
+			baseMap, baseOk := asMap(baseValue, inBase)
+			localMap, localOk := localValue.(map[string]interface{})
+			desiredMap, desiredOk := desiredValue.(map[string]interface{})
+			if baseOk && localOk && desiredOk {
+				merged, childConflicts := mergeThreeWay(childPath, baseMap, localMap, desiredMap)
+				result[key] = merged
+				conflicts = append(conflicts, childConflicts...)
+				continue
+			}
+			conflicts = append(conflicts, childPath)
+			if inLocal {
+				result[key] = localValue
+			}
+		}
+	}
+	return result, conflicts
+}
+
+// asMap returns the value as a map. A missing value counts as an empty map so sections that were added on both sides are merged.
+func asMap(value interface{}, ok bool) (map[string]interface{}, bool) {
+	if !ok {
+		return map[string]interface{}{}, true
+	}
+	m, ok := value.(map[string]interface{})
+	return m, ok
+}
+
+func unionMapKeys(maps ...map[string]interface{}) map[string]struct{} {
+	keys := map[string]struct{}{}
+	for _, m := range maps {
+		for key := range m {
+			keys[key] = struct{}{}
+		}
+	}
+	return keys
+}
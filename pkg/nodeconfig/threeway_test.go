@@ -0,0 +1,83 @@
+package nodeconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestThreeWayMerge(t *testing.T) {
+	tests := []struct {
+		name          string
+		lastApplied   string
+		current       string
+		desired       string
+		want          string
+		wantChanged   bool
+		wantConflicts []string
+	}{
+		{
+			name:        "local edits are kept if the desired config didn't change",
+			lastApplied: "hostname: a\n",
+			current:     "hostname: a\nrun_cmd:\n- echo local\n",
+			desired:     "hostname: a\n",
+			want:        "hostname: a\nrun_cmd:\n- echo local\n",
+		},
+		{
+			name:        "whitespace-only differences aren't rewritten",
+			lastApplied: "hostname: a\n",
+			current:     "hostname:   a\n\n",
+			desired:     "hostname: a\n",
+			want:        "hostname:   a\n\n",
+		},
+		{
+			name:        "desired changes are applied next to local edits",
+			lastApplied: "hostname: a\nk3os:\n  labels:\n    a: b\n",
+			current:     "hostname: a\nk3os:\n  labels:\n    a: b\n  dns_nameservers:\n  - 1.1.1.1\n",
+			desired:     "hostname: b\nk3os:\n  labels:\n    a: c\n",
+			want:        "hostname: b\nk3os:\n    dns_nameservers:\n        - 1.1.1.1\n    labels:\n        a: c\n",
+			wantChanged: true,
+		},
+		{
+			name:        "fields removed from the desired config are removed",
+			lastApplied: "hostname: a\nrun_cmd:\n- echo a\n",
+			current:     "hostname: a\nrun_cmd:\n- echo a\n",
+			desired:     "hostname: a\n",
+			want:        "hostname: a\n",
+			wantChanged: true,
+		},
+		{
+			name:          "conflicting changes keep the local value",
+			lastApplied:   "hostname: a\nk3os:\n  modules:\n  - kvm\n  labels:\n    a: b\n",
+			current:       "hostname: local\nk3os:\n  modules:\n  - kvm\n  - nvme\n  labels:\n    a: b\n",
+			desired:       "hostname: desired\nk3os:\n  modules:\n  - kvm\n  - vhost_net\n  labels:\n    a: c\n",
+			want:          "hostname: local\nk3os:\n    labels:\n        a: c\n    modules:\n        - kvm\n        - nvme\n",
+			wantChanged:   true,
+			wantConflicts: []string{"hostname", "k3os.modules"},
+		},
+		{
+			name:        "same change on both sides",
+			lastApplied: "hostname: a\n",
+			current:     "hostname: b\n",
+			desired:     "hostname: b\n",
+			want:        "hostname: b\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			merged, changed, conflicts, err := ThreeWayMerge([]byte(tt.lastApplied), []byte(tt.current), []byte(tt.desired))
+			if err != nil {
+				t.Fatalf("ThreeWayMerge() error = %v", err)
+			}
+			if string(merged) != tt.want {
+				t.Errorf("ThreeWayMerge() merged =\n%s\nwant\n%s", merged, tt.want)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("ThreeWayMerge() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("ThreeWayMerge() conflicts = %v, want %v", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package nodes
+
+import (
+	"fmt"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NodeAnnotator allows reconciling node annotations.
+type NodeAnnotator interface {
+	Reconcile(*corev1.Node, map[string]string) error
+	UpdatedAnnotations() map[string]string
+	OwnershipError() error
+}
+
+// annotator implements the NodeAnnotator interface.
+var _ NodeAnnotator = (*annotator)(nil)
+
+type annotator struct {
+	updatedAnnotations map[string]string
+	ownershipErr       error
+}
+
+// NewAnnotator returns an initialized annotation reconciler.
+func NewAnnotator() NodeAnnotator {
+	return &annotator{
+		updatedAnnotations: map[string]string{},
+	}
+}
+
+// Reconcile updates a node's annotations according to the provided node annotations.
+// An annotationsAdded annotation in the format of earlier versions is migrated and one that can't be read is repaired
+// (see OwnershipError).
+// It will return errors.ErrSkipUpdate if no updates to the node are required.
+// The provided Node object is updated and the caller must persist the updated
+// Node with the Kubernetes API server on their own.
+func (a *annotator) Reconcile(node *corev1.Node, configNodeAnnotations map[string]string) error {
+	if node == nil {
+		return fmt.Errorf("node: %w", errors.ErrNilObjectPassed)
+	}
+	nodeAnnotations := node.GetAnnotations()
+	if nodeAnnotations == nil {
+		nodeAnnotations = map[string]string{}
+	}
+
+	addedAnnotationsMap, update, err := addedAnnotations(node)
+	a.ownershipErr = err
+	for addedAnnotation := range addedAnnotationsMap {
+		if _, ok := configNodeAnnotations[addedAnnotation]; !ok { // an annotation that we added was removed, drop it
+			delete(nodeAnnotations, addedAnnotation)
+			delete(addedAnnotationsMap, addedAnnotation)
+			update = true
+			a.updatedAnnotations[addedAnnotation] = "(removed)"
+		}
+	}
+
+	for annotationKey, annotationValue := range configNodeAnnotations {
+		if _, ok := addedAnnotationsMap[annotationKey]; ok && annotationValue == nodeAnnotations[annotationKey] { // annotation already exists and hasn't been changed, skip
+			continue
+		}
+
+		update = true
+		addedAnnotationsMap[annotationKey] = struct{}{}
+		nodeAnnotations[annotationKey] = annotationValue
+		a.updatedAnnotations[annotationKey] = annotationValue
+	}
+
+	if update {
+		node.Annotations = nodeAnnotations
+		updateAddedAnnotations(node, addedAnnotationsMap)
+		return nil
+	}
+
+	return errors.ErrSkipUpdate
+}
+
+// UpdatedAnnotations returns the updated (added, removed, changed) annotations after Reconcile was called.
+func (a *annotator) UpdatedAnnotations() map[string]string {
+	return a.updatedAnnotations
+}
+
+// OwnershipError returns the *OwnershipError describing why the annotationsAdded annotation couldn't be read completely
+// after Reconcile was called. Annotations that couldn't be read aren't removed from the node anymore.
+func (a *annotator) OwnershipError() error {
+	return a.ownershipErr
+}
@@ -0,0 +1,93 @@
+package nodes
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func annotatedNode(annotations map[string]string) *corev1.Node {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node", Annotations: map[string]string{"existing": "value"}}}
+	if err := NewAnnotator().Reconcile(node, annotations); err != nil {
+		panic(err)
+	}
+	return node
+}
+
+func Test_annotator_Reconcile(t *testing.T) {
+	tests := []struct {
+		name                  string
+		node                  *corev1.Node
+		configAnnotations     map[string]string
+		wantErr               error
+		wantAnnotations       map[string]string
+		wantUpdatedAnnotation map[string]string
+	}{
+		{
+			name:    "passing a nil Node object",
+			wantErr: errors.ErrNilObjectPassed,
+		},
+		{
+			name:                  "Node has no annotations and none are added",
+			node:                  &corev1.Node{},
+			wantErr:               errors.ErrSkipUpdate,
+			wantUpdatedAnnotation: map[string]string{},
+		},
+		{
+			name:              "Node has no annotations and we add some",
+			node:              &corev1.Node{},
+			configAnnotations: map[string]string{"b": "2", "a": "1"},
+			wantAnnotations: map[string]string{
+				"a": "1", "b": "2",
+				consts.AddedAnnotationsNodeAnnotation(): `{"version":1,"annotations":["a","b"]}`,
+			},
+			wantUpdatedAnnotation: map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			name:                  "annotations we added are unchanged",
+			node:                  annotatedNode(map[string]string{"a": "1"}),
+			configAnnotations:     map[string]string{"a": "1"},
+			wantErr:               errors.ErrSkipUpdate,
+			wantUpdatedAnnotation: map[string]string{},
+		},
+		{
+			name:              "change and remove annotations we added and keep the others",
+			node:              annotatedNode(map[string]string{"a": "1", "b": "2"}),
+			configAnnotations: map[string]string{"a": "changed"},
+			wantAnnotations: map[string]string{
+				"existing": "value", "a": "changed",
+				consts.AddedAnnotationsNodeAnnotation(): `{"version":1,"annotations":["a"]}`,
+			},
+			wantUpdatedAnnotation: map[string]string{"a": "changed", "b": "(removed)"},
+		},
+		{
+			name:                  "remove all annotations we added",
+			node:                  annotatedNode(map[string]string{"a": "1"}),
+			wantAnnotations:       map[string]string{"existing": "value"},
+			wantUpdatedAnnotation: map[string]string{"a": "(removed)"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAnnotator()
+			err := a.Reconcile(tt.node, tt.configAnnotations)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("annotator.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.node == nil {
+				return
+			}
+			if tt.wantAnnotations != nil && !reflect.DeepEqual(tt.node.GetAnnotations(), tt.wantAnnotations) {
+				t.Errorf("annotator.Reconcile() annotations = %v, want %v", tt.node.GetAnnotations(), tt.wantAnnotations)
+			}
+			if !reflect.DeepEqual(a.UpdatedAnnotations(), tt.wantUpdatedAnnotation) {
+				t.Errorf("annotator.UpdatedAnnotations() = %v, want %v", a.UpdatedAnnotations(), tt.wantUpdatedAnnotation)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// mirrorPodAnnotation is set by the kubelet on the API server representation of static pods.
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// Drainer evicts the pods of a node.
+type Drainer interface {
+	// Drain evicts all pods from the node that aren't managed by a DaemonSet and aren't mirror pods.
+	// Evictions honor PodDisruptionBudgets. It returns whether all these pods are gone. Drain is
+	// meant to be called repeatedly until it returns true.
+	Drain(ctx context.Context, nodeName string) (bool, error)
+}
+
+// NewDrainer returns an initialized Drainer.
+func NewDrainer(clientset kubernetes.Interface) Drainer {
+	return &drainer{clientset: clientset}
+}
+
+type drainer struct {
+	clientset kubernetes.Interface
+}
+
+// Drain evicts the pods of the node.
+func (d *drainer) Drain(ctx context.Context, nodeName string) (bool, error) {
+	podList, err := d.clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list pods of node %q: %w", nodeName, err)
+	}
+
+	drained := true
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if !evictable(pod) {
+			continue
+		}
+		drained = false
+		if pod.GetDeletionTimestamp() != nil { // already being evicted
+			continue
+		}
+		eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: pod.GetName(), Namespace: pod.GetNamespace()}}
+		err = d.clientset.PolicyV1().Evictions(pod.GetNamespace()).Evict(ctx, eviction)
+		switch {
+		case err == nil, apierrors.IsNotFound(err):
+		case apierrors.IsTooManyRequests(err): // a PodDisruptionBudget doesn't allow the eviction right now, try again later
+		default:
+			return false, fmt.Errorf("failed to evict pod %s/%s: %w", pod.GetNamespace(), pod.GetName(), err)
+		}
+	}
+	return drained, nil
+}
+
+// evictable returns whether the pod has to be evicted before the node can be rebooted.
+func evictable(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := pod.GetAnnotations()[mirrorPodAnnotation]; ok {
+		return false
+	}
+	if controller := metav1.GetControllerOf(pod); controller != nil && controller.Kind == "DaemonSet" {
+		return false
+	}
+	return true
+}
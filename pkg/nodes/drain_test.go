@@ -0,0 +1,108 @@
+package nodes
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func drainPod(name string, mutate func(*corev1.Pod)) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if mutate != nil {
+		mutate(pod)
+	}
+	return pod
+}
+
+func TestDrainer_Drain(t *testing.T) {
+	isController := true
+	daemonSetPod := drainPod("daemonset", func(pod *corev1.Pod) {
+		pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "ds", Controller: &isController}}
+	})
+	mirrorPod := drainPod("mirror", func(pod *corev1.Pod) {
+		pod.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+	})
+	completedPod := drainPod("completed", func(pod *corev1.Pod) { pod.Status.Phase = corev1.PodSucceeded })
+	terminatingPod := drainPod("terminating", func(pod *corev1.Pod) {
+		now := metav1.Now()
+		pod.DeletionTimestamp = &now
+	})
+
+	tests := []struct {
+		name          string
+		pods          []runtime.Object
+		blockedByPDB  map[string]bool
+		wantDrained   bool
+		wantEvictions []string
+	}{
+		{
+			name:        "node without pods",
+			wantDrained: true,
+		},
+		{
+			name:        "node with pods that don't need to be evicted",
+			pods:        []runtime.Object{daemonSetPod, mirrorPod, completedPod},
+			wantDrained: true,
+		},
+		{
+			name:          "node with pods that need to be evicted",
+			pods:          []runtime.Object{daemonSetPod, drainPod("a", nil), drainPod("b", nil)},
+			wantEvictions: []string{"a", "b"},
+		},
+		{
+			name: "node with pods that are already terminating",
+			pods: []runtime.Object{terminatingPod},
+		},
+		{
+			name:          "eviction blocked by a PodDisruptionBudget",
+			pods:          []runtime.Object{drainPod("a", nil), drainPod("b", nil)},
+			blockedByPDB:  map[string]bool{"a": true},
+			wantEvictions: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.pods...)
+			var evictions []string
+			clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "eviction" {
+					return false, nil, nil
+				}
+				eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+				evictions = append(evictions, eviction.GetName())
+				if tt.blockedByPDB[eviction.GetName()] {
+					return true, nil, apierrors.NewTooManyRequests("cannot evict pod as it would violate the pod's disruption budget", 10)
+				}
+				return true, nil, nil
+			})
+
+			drained, err := NewDrainer(clientset).Drain(context.Background(), "node")
+			if err != nil {
+				t.Fatalf("Drain() error = %v", err)
+			}
+			if drained != tt.wantDrained {
+				t.Errorf("Drain() = %v, want %v", drained, tt.wantDrained)
+			}
+			if len(evictions) != len(tt.wantEvictions) {
+				t.Fatalf("evictions = %v, want %v", evictions, tt.wantEvictions)
+			}
+			for i := range evictions {
+				if evictions[i] != tt.wantEvictions[i] {
+					t.Errorf("evictions = %v, want %v", evictions, tt.wantEvictions)
+				}
+			}
+		})
+	}
+}
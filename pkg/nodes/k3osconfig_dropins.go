@@ -0,0 +1,238 @@
+package nodes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/config"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodeconfig"
+	"github.com/annismckenzie/k3os-config-operator/pkg/util/atomicfile"
+)
+
+// dropInNodeSeparator separates the node name from the fragment name in the keys of the drop-in Secret.
+// Fragment names cannot contain it; keys are split at its last occurrence so node names may.
+const dropInNodeSeparator = "--"
+
+// NodeDropIns returns the drop-in fragments that apply to the node keyed by their file name.
+// Keys of the drop-in Secret are either `<fragment>` (all nodes) or `<node name>--<fragment>` (only that node).
+// Fragments of a node take precedence over fragments with the same name for all nodes.
+func NodeDropIns(data map[string][]byte, nodeName string) map[string][]byte {
+	fragments := map[string][]byte{}
+	for key, value := range data {
+		if !strings.Contains(key, dropInNodeSeparator) {
+			if _, ok := fragments[key]; !ok {
+				fragments[key] = value
+			}
+			continue
+		}
+		if node, fragment := splitDropInKey(key); node == nodeName {
+			fragments[fragment] = value
+		}
+	}
+	return fragments
+}
+
+func splitDropInKey(key string) (string, string) {
+	i := strings.LastIndex(key, dropInNodeSeparator)
+	return key[:i], key[i+len(dropInNodeSeparator):]
+}
+
+// K3OSConfigDropInUpdater handles updating the k3OS config.d drop-in files on disk.
+type K3OSConfigDropInUpdater interface {
+	Update(fragments map[string][]byte) error
+	BootTimeChanges() []string
+}
+
+// NewK3OSConfigDropInUpdater returns an initialized K3OSConfigDropInUpdater.
+func NewK3OSConfigDropInUpdater(configuration *config.Configuration) K3OSConfigDropInUpdater {
+	return &k3OSConfigDropInUpdater{configuration: configuration}
+}
+
+type k3OSConfigDropInUpdater struct {
+	configuration   *config.Configuration
+	bootTimeChanges []string
+}
+
+// dropInManifest lists the drop-in files the operator owns. It's stored next to the config.d directory
+// because k3OS reads every file inside of it.
+type dropInManifest struct {
+	Files []string `json:"files"`
+}
+
+func (u *k3OSConfigDropInUpdater) enabled() bool {
+	return u.configuration.EnableNodeConfigDropInManagement()
+}
+
+func (u *k3OSConfigDropInUpdater) dir() string {
+	return u.configuration.NodeConfigDropInDir
+}
+
+func (u *k3OSConfigDropInUpdater) manifestLocation() string {
+	dir := filepath.Clean(u.dir())
+	return filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+".manifest.json")
+}
+
+// Update writes the fragments into the config.d directory and removes the fragments the operator wrote before
+// that aren't passed anymore. Files that weren't written by the operator are never touched: a fragment with the
+// name of such a file is rejected. It returns errors.ErrSkipUpdate if the feature isn't enabled or all
+// drop-in files are already up to date.
+func (u *k3OSConfigDropInUpdater) Update(fragments map[string][]byte) error {
+	if !u.enabled() {
+		return errors.ErrSkipUpdate
+	}
+
+	names := make([]string, 0, len(fragments))
+	for name, data := range fragments {
+		if err := validateDropIn(name, data); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifest, err := u.readManifest()
+	if err != nil {
+		return err
+	}
+	owned := make(map[string]bool, len(manifest.Files))
+	for _, name := range manifest.Files {
+		owned[name] = true
+	}
+
+	if err = os.MkdirAll(u.dir(), 0755); err != nil { //nolint:gomnd // same permissions as created by k3OS
+		return fmt.Errorf("failed to create config.d directory: %w", err)
+	}
+
+	// collect the changes before touching any file
+	var (
+		current          = map[string][]byte{}
+		wanted           = make(map[string]bool, len(names))
+		writes, removals []string
+		changes          []string
+	)
+	for _, name := range names {
+		wanted[name] = true
+		data, err := ioutil.ReadFile(filepath.Join(u.dir(), name))
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return fmt.Errorf("failed to read drop-in file %q: %w", name, err)
+		case !owned[name]:
+			return fmt.Errorf("refusing to overwrite drop-in file %q that wasn't written by the operator", name)
+		}
+		if data != nil && nodeconfig.SemanticEqual(data, fragments[name]) {
+			continue
+		}
+		current[name] = data
+		writes = append(writes, name)
+	}
+	for _, name := range manifest.Files {
+		if !wanted[name] {
+			removals = append(removals, name)
+		}
+	}
+	if len(writes) == 0 && len(removals) == 0 {
+		return errors.ErrSkipUpdate
+	}
+
+	// record new files as owned before writing them so a crash in between doesn't turn them into foreign files
+	if err = u.writeManifest(unionStrings(manifest.Files, names)); err != nil {
+		return err
+	}
+	for _, name := range writes {
+		if err = atomicfile.WriteFile(filepath.Join(u.dir(), name), fragments[name], 0600); err != nil {
+			return fmt.Errorf("failed to write drop-in file %q: %w", name, err)
+		}
+		changes = append(changes, dropInBootTimeChanges(name, current[name], fragments[name])...)
+	}
+	for _, name := range removals {
+		location := filepath.Join(u.dir(), name)
+		data, err := ioutil.ReadFile(location)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read drop-in file %q: %w", name, err)
+		}
+		if err = os.Remove(location); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove drop-in file %q: %w", name, err)
+		}
+		changes = append(changes, dropInBootTimeChanges(name, data, nil)...)
+	}
+	if err = atomicfile.SyncDir(u.dir()); err != nil {
+		return err
+	}
+	u.bootTimeChanges = changes
+	return u.writeManifest(names)
+}
+
+// BootTimeChanges returns the fields of the drop-in files that were changed by the last update and only take effect
+// when the node boots. The fields are prefixed with the path of the drop-in file, e.g. config.d/10-modules.yaml:k3os.modules.
+func (u *k3OSConfigDropInUpdater) BootTimeChanges() []string {
+	return u.bootTimeChanges
+}
+
+func (u *k3OSConfigDropInUpdater) readManifest() (*dropInManifest, error) {
+	manifest := &dropInManifest{}
+	data, err := ioutil.ReadFile(u.manifestLocation())
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read drop-in manifest: %w", err)
+	}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse drop-in manifest %q: %w", u.manifestLocation(), err)
+	}
+	return manifest, nil
+}
+
+func (u *k3OSConfigDropInUpdater) writeManifest(files []string) error {
+	data, err := json.Marshal(&dropInManifest{Files: files})
+	if err != nil {
+		return err
+	}
+	if err = atomicfile.WriteFile(u.manifestLocation(), data, 0600); err != nil {
+		return fmt.Errorf("failed to write drop-in manifest: %w", err)
+	}
+	return nil
+}
+
+func validateDropIn(name string, data []byte) error {
+	if name == "" || strings.HasPrefix(name, ".") || filepath.Base(name) != name {
+		return fmt.Errorf("invalid drop-in file name %q", name)
+	}
+	if _, err := configv1alpha1.ParseConfigYAML(data); err != nil {
+		return fmt.Errorf("drop-in file %q is invalid: %w", name, err)
+	}
+	return nil
+}
+
+func dropInBootTimeChanges(name string, oldData, newData []byte) []string {
+	prefix := "config.d/" + name
+	changes, err := BootTimeChanges(oldData, newData)
+	if err != nil { // the drop-in file on disk is corrupt so it changed completely
+		return []string{prefix}
+	}
+	for i := range changes {
+		changes[i] = prefix + ":" + changes[i]
+	}
+	return changes
+}
+
+func unionStrings(a, b []string) []string {
+	set := make(map[string]bool, len(a)+len(b))
+	for _, s := range append(append([]string{}, a...), b...) {
+		set[s] = true
+	}
+	union := make([]string, 0, len(set))
+	for s := range set {
+		union = append(union, s)
+	}
+	sort.Strings(union)
+	return union
+}
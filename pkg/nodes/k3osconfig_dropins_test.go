@@ -0,0 +1,202 @@
+package nodes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/annismckenzie/k3os-config-operator/config"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+)
+
+func TestNodeDropIns(t *testing.T) {
+	data := map[string][]byte{
+		"10-modules.yaml":        []byte("all"),
+		"20-sysctl.yaml":         []byte("all"),
+		"40_ntp.yaml":            []byte("all"),
+		"n1--20-sysctl.yaml":     []byte("n1"),
+		"n1--30-wifi.yaml":       []byte("n1"),
+		"n2.local--30-wifi.yaml": []byte("n2"),
+		"n4--edge--30-wifi.yaml": []byte("n4"),
+		"n4--edge--40_ntp.yaml":  []byte("n4"),
+	}
+	tests := []struct {
+		name     string
+		nodeName string
+		want     map[string][]byte
+	}{
+		{
+			name:     "node with own fragments",
+			nodeName: "n1",
+			want: map[string][]byte{
+				"10-modules.yaml": []byte("all"),
+				"20-sysctl.yaml":  []byte("n1"),
+				"30-wifi.yaml":    []byte("n1"),
+				"40_ntp.yaml":     []byte("all"),
+			},
+		},
+		{
+			name:     "node name containing dots",
+			nodeName: "n2.local",
+			want: map[string][]byte{
+				"10-modules.yaml": []byte("all"),
+				"20-sysctl.yaml":  []byte("all"),
+				"30-wifi.yaml":    []byte("n2"),
+				"40_ntp.yaml":     []byte("all"),
+			},
+		},
+		{
+			name:     "node name containing the separator",
+			nodeName: "n4--edge",
+			want: map[string][]byte{
+				"10-modules.yaml": []byte("all"),
+				"20-sysctl.yaml":  []byte("all"),
+				"30-wifi.yaml":    []byte("n4"),
+				"40_ntp.yaml":     []byte("n4"),
+			},
+		},
+		{
+			name:     "node without own fragments",
+			nodeName: "n3",
+			want: map[string][]byte{
+				"10-modules.yaml": []byte("all"),
+				"20-sysctl.yaml":  []byte("all"),
+				"40_ntp.yaml":     []byte("all"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NodeDropIns(data, tt.nodeName); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NodeDropIns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func listDropIns(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, entry := range entries {
+		data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func TestK3OSConfigDropInUpdater_Update(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config.d")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "00-hand-written.yaml"), []byte("hostname: manual\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	configuration := &config.Configuration{NodeConfigDropInDir: dir}
+
+	steps := []struct {
+		name                string
+		fragments           map[string]string
+		wantSkip            bool
+		wantErr             bool
+		wantFiles           map[string]string
+		wantBootTimeChanges []string
+	}{
+		{
+			name: "write fragments",
+			fragments: map[string]string{
+				"10-modules.yaml": "k3os:\n  modules:\n  - kvm\n",
+				"20-labels.yaml":  "k3os:\n  labels:\n    region: shelf-1\n",
+			},
+			wantFiles: map[string]string{
+				"00-hand-written.yaml": "hostname: manual\n",
+				"10-modules.yaml":      "k3os:\n  modules:\n  - kvm\n",
+				"20-labels.yaml":       "k3os:\n  labels:\n    region: shelf-1\n",
+			},
+			wantBootTimeChanges: []string{"config.d/10-modules.yaml:k3os.modules"},
+		},
+		{
+			name: "unchanged fragments",
+			fragments: map[string]string{
+				"10-modules.yaml": "k3os:\n  modules:\n  - kvm\n",
+				"20-labels.yaml":  "k3os:\n  labels:\n    region: shelf-1\n",
+			},
+			wantSkip: true,
+		},
+		{
+			name: "remove owned fragment and change another one",
+			fragments: map[string]string{
+				"20-labels.yaml": "k3os:\n  labels:\n    region: shelf-2\n",
+			},
+			wantFiles: map[string]string{
+				"00-hand-written.yaml": "hostname: manual\n",
+				"20-labels.yaml":       "k3os:\n  labels:\n    region: shelf-2\n",
+			},
+			wantBootTimeChanges: []string{"config.d/10-modules.yaml:k3os.modules"},
+		},
+		{
+			name: "refuse to overwrite hand-written file",
+			fragments: map[string]string{
+				"00-hand-written.yaml": "hostname: operator\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid fragment",
+			fragments: map[string]string{
+				"30-broken.yaml": "k3os: [",
+			},
+			wantErr: true,
+		},
+		{
+			name:      "remove all owned fragments",
+			fragments: map[string]string{},
+			wantFiles: map[string]string{
+				"00-hand-written.yaml": "hostname: manual\n",
+			},
+		},
+	}
+	for _, step := range steps {
+		fragments := map[string][]byte{}
+		for name, data := range step.fragments {
+			fragments[name] = []byte(data)
+		}
+		updater := NewK3OSConfigDropInUpdater(configuration)
+		err := updater.Update(fragments)
+		if step.wantSkip {
+			if !errors.Is(err, errors.ErrSkipUpdate) {
+				t.Fatalf("%s: Update() error = %v, want %v", step.name, err, errors.ErrSkipUpdate)
+			}
+			continue
+		}
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: Update() error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if files := listDropIns(t, dir); !reflect.DeepEqual(files, step.wantFiles) {
+			t.Errorf("%s: drop-in files = %v, want %v", step.name, files, step.wantFiles)
+		}
+		changes := updater.BootTimeChanges()
+		sort.Strings(changes)
+		if !reflect.DeepEqual(changes, step.wantBootTimeChanges) {
+			t.Errorf("%s: BootTimeChanges() = %v, want %v", step.name, changes, step.wantBootTimeChanges)
+		}
+	}
+
+	if err := NewK3OSConfigDropInUpdater(&config.Configuration{}).Update(nil); !errors.Is(err, errors.ErrSkipUpdate) {
+		t.Errorf("Update() with disabled drop-in management error = %v, want %v", err, errors.ErrSkipUpdate)
+	}
+}
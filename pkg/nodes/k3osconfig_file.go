@@ -5,24 +5,49 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
 	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
 	"github.com/annismckenzie/k3os-config-operator/config"
 	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodeconfig"
+	"github.com/annismckenzie/k3os-config-operator/pkg/util/atomicfile"
+	"github.com/annismckenzie/k3os-config-operator/pkg/util/diff"
 )
 
+// backupTimeFormat is the format of the timestamp in the file names of node config file backups.
+// Backups sort chronologically by name.
+const backupTimeFormat = "20060102T150405.000Z"
+
+// backupSuffix is the suffix of the file names of node config file backups.
+const backupSuffix = ".bak"
+
+// lastAppliedSuffix is the suffix of the hidden file next to the node config file that contains the contents the
+// operator last wrote. Changes to the node config file that aren't reflected in it were made by someone else.
+const lastAppliedSuffix = ".last-applied"
+
 // K3OSConfigFileUpdater handles updating the k3OS config file on disk.
 type K3OSConfigFileUpdater interface {
 	Update(*configv1alpha1.K3OSConfigFileSpec) error
+	Plan(*configv1alpha1.K3OSConfigFileSpec) (string, error)
+	Drift() (string, error)
+	Merge(*configv1alpha1.K3OSConfigFileSpec) ([]string, error)
+	BootTimeChanges() []string
+	Backups() ([]string, error)
+	RestoreBackup(name string) error
 }
 
 // NewK3OSConfigFileUpdater returns an initialized K3OSConfigFileUpdater.
 func NewK3OSConfigFileUpdater(configuration *config.Configuration) K3OSConfigFileUpdater {
-	return &k3OSConfigFileUpdater{configuration: configuration}
+	return &k3OSConfigFileUpdater{configuration: configuration, now: time.Now}
 }
 
 type k3OSConfigFileUpdater struct {
-	configuration *config.Configuration
+	configuration   *config.Configuration
+	bootTimeChanges []string
+	now             func() time.Time
 }
 
 func (u *k3OSConfigFileUpdater) enabled() bool {
@@ -32,41 +57,263 @@ func (u *k3OSConfigFileUpdater) enabled() bool {
 // Update handles updating the k3OS config file on disk.
 // It can be called anytime and will return errors.ErrSkipUpdate if
 // the feature isn't enabled or the config file is already up to date.
-func (u *k3OSConfigFileUpdater) Update(configFileSpec *configv1alpha1.K3OSConfigFileSpec) (err error) {
-	if !u.enabled() {
+// Config files are compared semantically: differences in the order of keys, comments or formatting alone don't
+// cause a rewrite. The current config file is backed up before it is atomically replaced.
+func (u *k3OSConfigFileUpdater) Update(configFileSpec *configv1alpha1.K3OSConfigFileSpec) error {
+	configFileBytes, err := u.prepare(configFileSpec)
+	if errors.Is(err, errors.ErrSkipUpdate) && u.enabled() { // start detecting drift of config files the operator didn't write
+		if err = u.recordLastApplied(configFileSpec.Data); err != nil {
+			return err
+		}
 		return errors.ErrSkipUpdate
 	}
+	if err != nil {
+		return err
+	}
+	newBytes, err := u.format(configFileSpec.Data)
+	if err != nil {
+		return err
+	}
+	if err = u.write(configFileBytes, newBytes); err != nil {
+		return err
+	}
+	return u.recordLastApplied(configFileSpec.Data)
+}
+
+// format returns the config file as it should be written to disk according to the configured format.
+func (u *k3OSConfigFileUpdater) format(data []byte) ([]byte, error) {
+	if !u.configuration.NormalizeNodeConfigFile() {
+		return data, nil
+	}
+	normalized, err := nodeconfig.Normalize(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize node config file: %w", err)
+	}
+	return normalized, nil
+}
+
+// Merge merges the changes of the passed config file since the operator last wrote the k3OS config file into the
+// config file on disk, keeping changes made by someone else (see nodeconfig.ThreeWayMerge). It returns the paths of the
+// fields that were changed on both sides in different ways; they keep their value on disk. Without a record of the
+// contents the operator last wrote it behaves like Update. It returns errors.ErrSkipUpdate (along with the conflicts)
+// if the feature isn't enabled or the merged config file doesn't differ semantically from the one on disk.
+func (u *k3OSConfigFileUpdater) Merge(configFileSpec *configv1alpha1.K3OSConfigFileSpec) ([]string, error) {
+	if !u.enabled() {
+		return nil, errors.ErrSkipUpdate
+	}
+	if err := configFileSpec.Validate(); err != nil {
+		return nil, fmt.Errorf("the provided config file is invalid: %w", err)
+	}
+	lastAppliedBytes, err := ioutil.ReadFile(u.lastAppliedLocation())
+	if os.IsNotExist(err) {
+		return nil, u.Update(configFileSpec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last applied node config file: %w", err)
+	}
+	configFileBytes, err := u.read()
+	if err != nil {
+		return nil, err
+	}
+
+	merged, changed, conflicts, err := nodeconfig.ThreeWayMerge(lastAppliedBytes, configFileBytes, configFileSpec.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge node config file: %w", err)
+	}
+	if !changed {
+		if err = u.recordLastApplied(configFileSpec.Data); err != nil {
+			return conflicts, err
+		}
+		return conflicts, errors.ErrSkipUpdate
+	}
+	if _, err = configv1alpha1.ParseConfigYAML(merged); err != nil {
+		return conflicts, fmt.Errorf("the merged config file is invalid: %w", err)
+	}
+	if u.bootTimeChanges, err = BootTimeChanges(configFileBytes, merged); err != nil {
+		if u.bootTimeChanges, err = BootTimeChanges(nil, merged); err != nil {
+			return conflicts, err
+		}
+	}
+	if err = u.write(configFileBytes, merged); err != nil {
+		return conflicts, err
+	}
+	return conflicts, u.recordLastApplied(configFileSpec.Data)
+}
+
+// Plan returns the unified diff between the k3OS config file on disk and the passed one without touching the file.
+// The values of secret fields are redacted in the diff. Like Update it records the boot-time changes and returns
+// errors.ErrSkipUpdate if the feature isn't enabled or the config file is already up to date.
+func (u *k3OSConfigFileUpdater) Plan(configFileSpec *configv1alpha1.K3OSConfigFileSpec) (string, error) {
+	configFileBytes, err := u.prepare(configFileSpec)
+	if err != nil {
+		return "", err
+	}
+	newBytes, err := u.format(configFileSpec.Data)
+	if err != nil {
+		return "", err
+	}
+	location := u.configuration.NodeConfigFileLocation
+	return diff.Unified(location, location, nodeconfig.RedactLines(configFileBytes), nodeconfig.RedactLines(newBytes)), nil
+}
+
+// Drift returns the unified diff between the contents the operator last wrote and the node config file on disk if
+// someone else changed the file since. The values of secret fields are redacted in the diff. It returns an empty string
+// if the file didn't drift or the operator didn't write it yet.
+func (u *k3OSConfigFileUpdater) Drift() (string, error) {
+	if !u.enabled() {
+		return "", nil
+	}
+	lastAppliedBytes, err := ioutil.ReadFile(u.lastAppliedLocation())
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read last applied node config file: %w", err)
+	}
+	configFileBytes, err := u.read()
+	if err != nil {
+		return "", err
+	}
+	if nodeconfig.SemanticEqual(lastAppliedBytes, configFileBytes) {
+		return "", nil
+	}
+	location := u.configuration.NodeConfigFileLocation
+	return diff.Unified(location+" (last applied)", location, nodeconfig.RedactLines(lastAppliedBytes), nodeconfig.RedactLines(configFileBytes)), nil
+}
+
+func (u *k3OSConfigFileUpdater) lastAppliedLocation() string {
+	dir, base := filepath.Split(u.configuration.NodeConfigFileLocation)
+	return filepath.Join(dir, "."+base+lastAppliedSuffix)
+}
+
+// recordLastApplied stores the contents the operator wrote to the node config file.
+func (u *k3OSConfigFileUpdater) recordLastApplied(data []byte) error {
+	current, err := ioutil.ReadFile(u.lastAppliedLocation())
+	if err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	if err = atomicfile.WriteFile(u.lastAppliedLocation(), data, 0600); err != nil {
+		return fmt.Errorf("failed to write last applied node config file: %w", err)
+	}
+	return nil
+}
+
+// prepare validates the config file, reads the current one from disk and records the boot-time changes.
+func (u *k3OSConfigFileUpdater) prepare(configFileSpec *configv1alpha1.K3OSConfigFileSpec) (configFileBytes []byte, err error) {
+	if !u.enabled() {
+		return nil, errors.ErrSkipUpdate
+	}
 
 	if err = configFileSpec.Validate(); err != nil {
-		return fmt.Errorf("the provided config file is invalid: %w", err)
+		return nil, fmt.Errorf("the provided config file is invalid: %w", err)
 	}
 
-	// open the config file for read-write
-	var configFile *os.File
-	if configFile, err = os.OpenFile(u.configuration.NodeConfigFileLocation, os.O_RDWR, 0); err != nil {
-		return fmt.Errorf("failed to open node config file: %w", err)
+	if configFileBytes, err = u.read(); err != nil {
+		return nil, err
 	}
-	defer func() {
-		// leaves the original error in place but still tries to close the file
-		if errClose := configFile.Close(); err == nil {
-			err = errClose
+
+	if nodeconfig.SemanticEqual(configFileBytes, configFileSpec.Data) {
+		return nil, errors.ErrSkipUpdate
+	}
+
+	if u.bootTimeChanges, err = BootTimeChanges(configFileBytes, configFileSpec.Data); err != nil {
+		// the config file on disk is corrupt so every field of the new config file has to be applied on boot
+		if u.bootTimeChanges, err = BootTimeChanges(nil, configFileSpec.Data); err != nil {
+			return nil, err
 		}
-	}()
+	}
+	return configFileBytes, nil
+}
 
-	var configFileBytes []byte
-	if configFileBytes, err = ioutil.ReadAll(configFile); err != nil {
-		return
+// BootTimeChanges returns the fields that were changed by the last update and only take effect when the node boots.
+func (u *k3OSConfigFileUpdater) BootTimeChanges() []string {
+	return u.bootTimeChanges
+}
+
+// Backups returns the file names of the backups of the node config file, oldest first.
+func (u *k3OSConfigFileUpdater) Backups() ([]string, error) {
+	dir, base := filepath.Split(u.configuration.NodeConfigFileLocation)
+	matches, err := filepath.Glob(filepath.Join(dir, base+".*"+backupSuffix))
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]string, 0, len(matches))
+	for _, match := range matches {
+		backups = append(backups, filepath.Base(match))
 	}
+	sort.Strings(backups)
+	return backups, nil
+}
 
-	if bytes.Equal(configFileBytes, configFileSpec.Data) {
+// RestoreBackup replaces the node config file with the backup with the given file name.
+// The name "latest" restores the most recent backup. The current config file is backed up first.
+func (u *k3OSConfigFileUpdater) RestoreBackup(name string) error {
+	backups, err := u.Backups()
+	if err != nil {
+		return err
+	}
+	if name == "latest" && len(backups) > 0 {
+		name = backups[len(backups)-1]
+	}
+	index := sort.SearchStrings(backups, name)
+	if index == len(backups) || backups[index] != name {
+		return fmt.Errorf("failed to find backup %q of the node config file (backups: %v)", name, backups)
+	}
+
+	backupBytes, err := ioutil.ReadFile(filepath.Join(filepath.Dir(u.configuration.NodeConfigFileLocation), name))
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	configFileBytes, err := u.read()
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(configFileBytes, backupBytes) {
 		return errors.ErrSkipUpdate
 	}
+	if err = u.write(configFileBytes, backupBytes); err != nil {
+		return err
+	}
+	return u.recordLastApplied(backupBytes)
+}
+
+func (u *k3OSConfigFileUpdater) read() ([]byte, error) {
+	configFileBytes, err := ioutil.ReadFile(u.configuration.NodeConfigFileLocation)
+	if err != nil && !os.IsNotExist(err) { // a missing config file is created
+		return nil, fmt.Errorf("failed to read node config file: %w", err)
+	}
+	return configFileBytes, nil
+}
 
-	// truncate the file
-	if err = configFile.Truncate(0); err != nil {
-		return
+// write backs up the current contents of the config file, prunes old backups and atomically replaces the config file.
+func (u *k3OSConfigFileUpdater) write(currentBytes, newBytes []byte) error {
+	location := u.configuration.NodeConfigFileLocation
+	if keep := u.configuration.NodeConfigFileBackups; keep > 0 && len(currentBytes) > 0 {
+		backup := fmt.Sprintf("%s.%s%s", location, u.now().UTC().Format(backupTimeFormat), backupSuffix)
+		if err := atomicfile.WriteFile(backup, currentBytes, 0600); err != nil {
+			return fmt.Errorf("failed to back up node config file: %w", err)
+		}
+		if err := u.pruneBackups(keep); err != nil {
+			return fmt.Errorf("failed to prune node config file backups: %w", err)
+		}
+	}
+
+	if err := atomicfile.WriteFile(location, newBytes, 0600); err != nil {
+		return fmt.Errorf("failed to write node config file: %w", err)
+	}
+	return nil
+}
+
+func (u *k3OSConfigFileUpdater) pruneBackups(keep int) error {
+	backups, err := u.Backups()
+	if err != nil || len(backups) <= keep {
+		return err
+	}
+	dir := filepath.Dir(u.configuration.NodeConfigFileLocation)
+	for _, backup := range backups[:len(backups)-keep] {
+		if err = os.Remove(filepath.Join(dir, backup)); err != nil {
+			return err
+		}
 	}
-	// write the new configuration data
-	_, err = configFile.WriteAt(configFileSpec.Data, 0)
-	return err
+	return atomicfile.SyncDir(dir)
 }
@@ -1 +1,268 @@
 package nodes
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/config"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+)
+
+func testConfigFileUpdater(t *testing.T, backups int) *k3OSConfigFileUpdater {
+	t.Helper()
+	now := time.Date(2021, 10, 11, 12, 0, 0, 0, time.UTC)
+	updater := NewK3OSConfigFileUpdater(&config.Configuration{
+		ManageNodeConfigFile:   true,
+		NodeConfigFileLocation: filepath.Join(t.TempDir(), "config.yaml"),
+		NodeConfigFileBackups:  backups,
+	}).(*k3OSConfigFileUpdater)
+	updater.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	return updater
+}
+
+func testConfigFileSpec(t *testing.T, data string) *configv1alpha1.K3OSConfigFileSpec {
+	t.Helper()
+	spec, err := configv1alpha1.ParseConfigYAML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func readConfigFile(t *testing.T, filename string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestK3OSConfigFileUpdater_Update(t *testing.T) {
+	t.Run("disabled node config file management", func(t *testing.T) {
+		updater := NewK3OSConfigFileUpdater(&config.Configuration{})
+		if err := updater.Update(testConfigFileSpec(t, "hostname: a\n")); !errors.Is(err, errors.ErrSkipUpdate) {
+			t.Errorf("Update() error = %v, want %v", err, errors.ErrSkipUpdate)
+		}
+	})
+
+	t.Run("backs up and prunes old config files", func(t *testing.T) {
+		updater := testConfigFileUpdater(t, 2)
+		location := updater.configuration.NodeConfigFileLocation
+
+		for _, hostname := range []string{"a", "b", "c", "d"} {
+			if err := updater.Update(testConfigFileSpec(t, "hostname: "+hostname+"\n")); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if data := readConfigFile(t, location); data != "hostname: "+hostname+"\n" {
+				t.Fatalf("config file = %q, want hostname %q", data, hostname)
+			}
+		}
+		if err := updater.Update(testConfigFileSpec(t, "hostname: d\n")); !errors.Is(err, errors.ErrSkipUpdate) {
+			t.Errorf("Update() with unchanged config error = %v, want %v", err, errors.ErrSkipUpdate)
+		}
+
+		backups, err := updater.Backups()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantBackups := []string{"config.yaml.20211011T120200.000Z.bak", "config.yaml.20211011T120300.000Z.bak"}
+		if !reflect.DeepEqual(backups, wantBackups) {
+			t.Fatalf("Backups() = %v, want %v", backups, wantBackups)
+		}
+		if data := readConfigFile(t, filepath.Join(filepath.Dir(location), backups[1])); data != "hostname: c\n" {
+			t.Errorf("latest backup = %q, want hostname c", data)
+		}
+	})
+
+	t.Run("without backups", func(t *testing.T) {
+		updater := testConfigFileUpdater(t, 0)
+		for _, hostname := range []string{"a", "b"} {
+			if err := updater.Update(testConfigFileSpec(t, "hostname: "+hostname+"\n")); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+		}
+		if backups, err := updater.Backups(); err != nil || len(backups) != 0 {
+			t.Errorf("Backups() = %v, %v, want no backups", backups, err)
+		}
+	})
+}
+
+func TestK3OSConfigFileUpdater_RestoreBackup(t *testing.T) {
+	updater := testConfigFileUpdater(t, 3)
+	location := updater.configuration.NodeConfigFileLocation
+	for _, hostname := range []string{"a", "b", "c"} {
+		if err := updater.Update(testConfigFileSpec(t, "hostname: "+hostname+"\n")); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+
+	if err := updater.RestoreBackup("config.yaml.missing.bak"); err == nil {
+		t.Error("RestoreBackup() of a missing backup should fail")
+	}
+	if err := updater.RestoreBackup("latest"); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	if data := readConfigFile(t, location); data != "hostname: b\n" {
+		t.Errorf("config file = %q, want hostname b", data)
+	}
+	if err := updater.RestoreBackup("config.yaml.20211011T120100.000Z.bak"); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	if data := readConfigFile(t, location); data != "hostname: a\n" {
+		t.Errorf("config file = %q, want hostname a", data)
+	}
+
+	backups, err := updater.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 {
+		t.Errorf("Backups() = %v, want 3 backups", backups)
+	}
+}
+
+func TestK3OSConfigFileUpdater_Plan(t *testing.T) {
+	updater := testConfigFileUpdater(t, 3)
+	location := updater.configuration.NodeConfigFileLocation
+	if err := updater.Update(testConfigFileSpec(t, "hostname: a\nk3os:\n  token: old-token\n")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	planned, err := updater.Plan(testConfigFileSpec(t, "hostname: a\nk3os:\n  token: new-token\n  modules:\n  - kvm\n"))
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	wantDiff := "--- " + location + "\n+++ " + location + "\n@@ -1,3 +1,5 @@\n hostname: a\n k3os:\n   token: REDACTED\n+  modules:\n+  - kvm\n"
+	if planned != wantDiff {
+		t.Errorf("Plan() = %q, want %q", planned, wantDiff)
+	}
+	if want := []string{"k3os.modules", "k3os.token"}; !reflect.DeepEqual(updater.BootTimeChanges(), want) {
+		t.Errorf("BootTimeChanges() = %v, want %v", updater.BootTimeChanges(), want)
+	}
+	if data := readConfigFile(t, location); data != "hostname: a\nk3os:\n  token: old-token\n" {
+		t.Errorf("Plan() changed the config file to %q", data)
+	}
+	if backups, err := updater.Backups(); err != nil || len(backups) != 0 {
+		t.Errorf("Plan() created backups %v, %v", backups, err)
+	}
+	if _, err = updater.Plan(testConfigFileSpec(t, "hostname: a\nk3os:\n  token: old-token\n")); !errors.Is(err, errors.ErrSkipUpdate) {
+		t.Errorf("Plan() with unchanged config error = %v, want %v", err, errors.ErrSkipUpdate)
+	}
+}
+
+func TestK3OSConfigFileUpdater_Drift(t *testing.T) {
+	updater := testConfigFileUpdater(t, 0)
+	location := updater.configuration.NodeConfigFileLocation
+
+	if err := ioutil.WriteFile(location, []byte("hostname: a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if drift, err := updater.Drift(); err != nil || drift != "" {
+		t.Errorf("Drift() of a config file the operator didn't write = %q, %v, want no drift", drift, err)
+	}
+	if err := updater.Update(testConfigFileSpec(t, "hostname: a\n")); !errors.Is(err, errors.ErrSkipUpdate) {
+		t.Fatalf("Update() error = %v, want %v", err, errors.ErrSkipUpdate)
+	}
+	if drift, err := updater.Drift(); err != nil || drift != "" {
+		t.Errorf("Drift() of an unchanged config file = %q, %v, want no drift", drift, err)
+	}
+
+	if err := ioutil.WriteFile(location, []byte("hostname: b\nk3os:\n  token: manual-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	drift, err := updater.Drift()
+	if err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	wantDrift := "--- " + location + " (last applied)\n+++ " + location + "\n@@ -1 +1,3 @@\n-hostname: a\n+hostname: b\n+k3os:\n+  token: REDACTED\n"
+	if drift != wantDrift {
+		t.Errorf("Drift() = %q, want %q", drift, wantDrift)
+	}
+
+	if err = updater.Update(testConfigFileSpec(t, "hostname: a\n")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if drift, err := updater.Drift(); err != nil || drift != "" {
+		t.Errorf("Drift() after correcting the config file = %q, %v, want no drift", drift, err)
+	}
+}
+
+func TestK3OSConfigFileUpdater_Merge(t *testing.T) {
+	updater := testConfigFileUpdater(t, 0)
+	location := updater.configuration.NodeConfigFileLocation
+
+	// without a last applied file the desired config file is written as is
+	if _, err := updater.Merge(testConfigFileSpec(t, "hostname: a\nk3os:\n  labels:\n    a: b\n")); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if data := readConfigFile(t, location); data != "hostname: a\nk3os:\n  labels:\n    a: b\n" {
+		t.Fatalf("config file = %q", data)
+	}
+
+	// edit the file by hand
+	if err := ioutil.WriteFile(location, []byte("hostname: local\nk3os:\n  labels:\n    a: b\n  modules:\n  - kvm\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	conflicts, err := updater.Merge(testConfigFileSpec(t, "hostname: desired\nk3os:\n  labels:\n    a: c\n"))
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if want := []string{"hostname"}; !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("Merge() conflicts = %v, want %v", conflicts, want)
+	}
+	if data, want := readConfigFile(t, location), "hostname: local\nk3os:\n    labels:\n        a: c\n    modules:\n        - kvm\n"; data != want {
+		t.Errorf("config file = %q, want %q", data, want)
+	}
+	if want := []string(nil); !reflect.DeepEqual(updater.BootTimeChanges(), want) {
+		t.Errorf("BootTimeChanges() = %v, want %v", updater.BootTimeChanges(), want)
+	}
+
+	// merging the same desired config again doesn't touch the file
+	if _, err = updater.Merge(testConfigFileSpec(t, "hostname: desired\nk3os:\n  labels:\n    a: c\n")); !errors.Is(err, errors.ErrSkipUpdate) {
+		t.Errorf("Merge() of an unchanged config error = %v, want %v", err, errors.ErrSkipUpdate)
+	}
+}
+
+func TestK3OSConfigFileUpdater_Update_semantic(t *testing.T) {
+	t.Run("semantically equal config files aren't rewritten", func(t *testing.T) {
+		updater := testConfigFileUpdater(t, 3)
+		location := updater.configuration.NodeConfigFileLocation
+		if err := updater.Update(testConfigFileSpec(t, "hostname: a\nrun_cmd:\n- echo\n")); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		for _, data := range []string{"run_cmd: [echo]\nhostname: a", "# managed by the operator\nhostname: a\nrun_cmd:\n  - echo\n\n"} {
+			if err := updater.Update(testConfigFileSpec(t, data)); !errors.Is(err, errors.ErrSkipUpdate) {
+				t.Errorf("Update(%q) error = %v, want %v", data, err, errors.ErrSkipUpdate)
+			}
+		}
+		if data := readConfigFile(t, location); data != "hostname: a\nrun_cmd:\n- echo\n" {
+			t.Errorf("config file = %q, want it unchanged", data)
+		}
+		if drift, err := updater.Drift(); err != nil || drift != "" {
+			t.Errorf("Drift() = %q, %v, want no drift", drift, err)
+		}
+	})
+
+	t.Run("normalized config files", func(t *testing.T) {
+		updater := testConfigFileUpdater(t, 0)
+		updater.configuration.NodeConfigFileFormat = "normalize"
+		location := updater.configuration.NodeConfigFileLocation
+		if err := updater.Update(testConfigFileSpec(t, "# comment\nrun_cmd: [echo]\nhostname: a\n")); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if data, want := readConfigFile(t, location), "hostname: a\nrun_cmd:\n    - echo\n"; data != want {
+			t.Errorf("config file = %q, want %q", data, want)
+		}
+		if drift, err := updater.Drift(); err != nil || drift != "" {
+			t.Errorf("Drift() = %q, %v, want no drift", drift, err)
+		}
+	})
+}
@@ -2,11 +2,9 @@ package nodes
 
 import (
 	"fmt"
-	"strings"
 
-	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
 	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
-	internalConsts "github.com/annismckenzie/k3os-config-operator/pkg/internal/consts"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -14,23 +12,33 @@ import (
 type Labeler interface {
 	Reconcile(*corev1.Node, map[string]string) error
 	UpdatedLabels() map[string]string
+	SkippedLabels() []string
+	OwnershipError() error
 }
 
 // labeler implements the Labeler interface.
 var _ Labeler = (*labeler)(nil)
 
 type labeler struct {
+	policy        *configv1alpha1.LabelPolicy
 	updatedLabels map[string]string
+	skippedLabels []string
+	ownershipErr  error
 }
 
-// NewLabeler returns an initialized label reconciler.
-func NewLabeler() Labeler {
+// NewLabeler returns an initialized label reconciler that only syncs the labels the policy allows
+// (the default policy is used if it is nil).
+func NewLabeler(policy *configv1alpha1.LabelPolicy) Labeler {
 	return &labeler{
+		policy:        policy,
 		updatedLabels: map[string]string{},
 	}
 }
 
 // Reconcile updates a node's labels according to the provided node labels.
+// Labels the policy denies are skipped as if they weren't provided unless the operator added them before and only the
+// default policy denies them (see LabelPolicy.FilterOwned). A labelsAdded annotation in the format of earlier
+// versions is migrated and one that can't be read is repaired (see OwnershipError).
 // It will return errors.ErrSkipUpdate if no updates to the node are required.
 // The provided Node object is updated and the caller must persist the updated
 // Node with the Kubernetes API server on their own.
@@ -43,8 +51,9 @@ func (l *labeler) Reconcile(node *corev1.Node, configNodeLabels map[string]strin
 		nodeLabels = map[string]string{}
 	}
 
-	var update bool
-	addedLabelsMap := addedLabels(node)
+	addedLabelsMap, update, err := addedLabels(node)
+	l.ownershipErr = err
+	configNodeLabels, l.skippedLabels = l.policy.FilterOwned(configNodeLabels, addedLabelsMap)
 	for addedLabel := range addedLabelsMap {
 		if _, ok := configNodeLabels[addedLabel]; !ok { // a label that we added was removed, drop it
 			delete(nodeLabels, addedLabel)
@@ -79,31 +88,13 @@ func (l *labeler) UpdatedLabels() map[string]string {
 	return l.updatedLabels
 }
 
-func addedLabels(node *corev1.Node) map[string]struct{} {
-	if node == nil {
-		return nil
-	}
-
-	addedLabelsMap := map[string]struct{}{}
-	if addedLabelsAnnotation := node.GetAnnotations()[consts.AddedLabelsNodeAnnotation()]; addedLabelsAnnotation != "" {
-		for _, addedLabel := range strings.Split(addedLabelsAnnotation, internalConsts.NodeAnnotationValueSeparator) {
-			addedLabelsMap[addedLabel] = struct{}{}
-		}
-	}
-	return addedLabelsMap
+// SkippedLabels returns the sorted keys of the provided labels that the policy denies after Reconcile was called.
+func (l *labeler) SkippedLabels() []string {
+	return l.skippedLabels
 }
 
-func updateAddedLabels(node *corev1.Node, addedLabelsMap map[string]struct{}) {
-	addedLabels := make([]string, len(addedLabelsMap))
-	var i int
-	for addedLabel := range addedLabelsMap {
-		addedLabels[i] = addedLabel
-		i++
-	}
-	annotations := node.GetAnnotations()
-	if annotations == nil {
-		annotations = map[string]string{}
-	}
-	annotations[consts.AddedLabelsNodeAnnotation()] = strings.Join(addedLabels, internalConsts.NodeAnnotationValueSeparator)
-	node.Annotations = annotations
+// OwnershipError returns the *OwnershipError describing why the labelsAdded annotation couldn't be read completely
+// after Reconcile was called. Labels that couldn't be read aren't removed from the node anymore.
+func (l *labeler) OwnershipError() error {
+	return l.ownershipErr
 }
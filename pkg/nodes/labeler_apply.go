@@ -0,0 +1,221 @@
+package nodes
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FieldManager is the field manager the operator applies node labels with when server-side apply is used.
+const FieldManager = "k3os-config-operator"
+
+// ApplyLabeler allows reconciling node labels with server-side apply. The managedFields of the node track
+// which labels the operator owns instead of the labelsAdded annotation.
+type ApplyLabeler interface {
+	Labeler
+	ApplyPatch() ([]byte, error)
+	MigrationPatch() ([]byte, error)
+}
+
+// applyLabeler implements the ApplyLabeler interface.
+var _ ApplyLabeler = (*applyLabeler)(nil)
+
+type applyLabeler struct {
+	policy        *configv1alpha1.LabelPolicy
+	updatedLabels map[string]string
+	skippedLabels []string
+	ownershipErr  error
+	nodeName      string
+	labels        map[string]string
+	staleLabels   []string
+	migrate       bool
+}
+
+// NewApplyLabeler returns an initialized label reconciler that uses server-side apply and only syncs the labels
+// the policy allows (the default policy is used if it is nil).
+func NewApplyLabeler(policy *configv1alpha1.LabelPolicy) ApplyLabeler {
+	return &applyLabeler{
+		policy:        policy,
+		updatedLabels: map[string]string{},
+	}
+}
+
+// Reconcile computes the changes to a node's labels according to the provided node labels.
+// Labels the policy denies are skipped as if they weren't provided unless the operator owns them already and only the
+// default policy denies them (see LabelPolicy.FilterOwned).
+// It will return errors.ErrSkipUpdate if no updates to the node are required.
+// The provided Node object is not updated: the caller must send ApplyPatch (and MigrationPatch
+// if it isn't nil) to the Kubernetes API server on their own.
+func (l *applyLabeler) Reconcile(node *corev1.Node, configNodeLabels map[string]string) error {
+	if node == nil {
+		return fmt.Errorf("node: %w", errors.ErrNilObjectPassed)
+	}
+	l.nodeName = node.GetName()
+	nodeLabels := node.GetLabels()
+
+	appliedLabelsMap, err := appliedLabels(node)
+	if err != nil {
+		return err
+	}
+	// labels added before the switch to server-side apply are migrated once: applying takes ownership of the
+	// ones that are still configured, the others are removed together with the annotation
+	var addedLabelsMap map[string]struct{}
+	if _, l.migrate = node.GetAnnotations()[consts.AddedLabelsNodeAnnotation()]; l.migrate {
+		addedLabelsMap, _, l.ownershipErr = addedLabels(node)
+	}
+	ownedLabelsMap := make(map[string]struct{}, len(appliedLabelsMap)+len(addedLabelsMap))
+	for _, labelsMap := range []map[string]struct{}{appliedLabelsMap, addedLabelsMap} {
+		for label := range labelsMap {
+			ownedLabelsMap[label] = struct{}{}
+		}
+	}
+	configNodeLabels, l.skippedLabels = l.policy.FilterOwned(configNodeLabels, ownedLabelsMap)
+	l.labels = configNodeLabels
+
+	var apply bool
+	for appliedLabel := range appliedLabelsMap {
+		if _, ok := configNodeLabels[appliedLabel]; !ok { // a label that we applied was removed, applying drops it
+			apply = true
+			if _, ok := nodeLabels[appliedLabel]; ok {
+				l.updatedLabels[appliedLabel] = "(removed)"
+			}
+		}
+	}
+
+	if l.migrate {
+		apply = true
+		for addedLabel := range addedLabelsMap {
+			if _, ok := configNodeLabels[addedLabel]; ok {
+				continue
+			}
+			if _, ok := nodeLabels[addedLabel]; ok {
+				l.staleLabels = append(l.staleLabels, addedLabel)
+				l.updatedLabels[addedLabel] = "(removed)"
+			}
+		}
+		sort.Strings(l.staleLabels)
+	}
+
+	for labelKey, labelValue := range configNodeLabels {
+		if value, ok := nodeLabels[labelKey]; !ok || value != labelValue {
+			l.updatedLabels[labelKey] = labelValue
+			apply = true
+		} else if _, ok := appliedLabelsMap[labelKey]; !ok { // label exists but isn't owned yet
+			apply = true
+		}
+	}
+
+	if apply {
+		return nil
+	}
+	return errors.ErrSkipUpdate
+}
+
+// UpdatedLabels returns the updated (added, removed, changed) labels after Reconcile was called.
+func (l *applyLabeler) UpdatedLabels() map[string]string {
+	return l.updatedLabels
+}
+
+// SkippedLabels returns the sorted keys of the provided labels that the policy denies after Reconcile was called.
+func (l *applyLabeler) SkippedLabels() []string {
+	return l.skippedLabels
+}
+
+// OwnershipError returns the *OwnershipError describing why the labelsAdded annotation that is migrated couldn't be
+// read completely after Reconcile was called. Labels that couldn't be read aren't removed from the node.
+func (l *applyLabeler) OwnershipError() error {
+	return l.ownershipErr
+}
+
+// ApplyPatch returns the server-side apply patch that sets the labels passed to Reconcile. It must be sent with
+// FieldManager as the field manager: labels that were applied before and are missing from the patch are removed.
+func (l *applyLabeler) ApplyPatch() ([]byte, error) {
+	labels := l.labels
+	if labels == nil {
+		labels = map[string]string{} // an empty map (instead of none) releases all labels that were applied before
+	}
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Node",
+		"metadata": map[string]interface{}{
+			"name":   l.nodeName,
+			"labels": labels,
+		},
+	})
+}
+
+// MigrationPatch returns the JSON merge patch that removes the labelsAdded annotation and the labels the operator
+// added before that aren't configured anymore. It returns nil if the node doesn't need to be migrated.
+func (l *applyLabeler) MigrationPatch() ([]byte, error) {
+	if !l.migrate {
+		return nil, nil
+	}
+	labels := map[string]interface{}{}
+	for _, staleLabel := range l.staleLabels {
+		labels[staleLabel] = nil
+	}
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{consts.AddedLabelsNodeAnnotation(): nil},
+			"labels":      labels,
+		},
+	})
+}
+
+// ManagedLabels returns the labels the operator manages on the node: the ones it applied with server-side apply
+// and the ones kept in the labelsAdded annotation.
+func ManagedLabels(node *corev1.Node) []string {
+	managedLabelsMap, _ := appliedLabels(node)
+	addedLabelsMap, _, _ := addedLabels(node)
+	for addedLabel := range addedLabelsMap {
+		managedLabelsMap[addedLabel] = struct{}{}
+	}
+	managedLabels := make([]string, 0, len(managedLabelsMap))
+	for managedLabel := range managedLabelsMap {
+		managedLabels = append(managedLabels, managedLabel)
+	}
+	sort.Strings(managedLabels)
+	return managedLabels
+}
+
+// ManagedTaints returns the sorted taints the operator manages on the node as they are kept in the taintsAdded annotation.
+func ManagedTaints(node *corev1.Node) []string {
+	addedTaintsMap, _, _ := getAddedTaints(node)
+	managedTaints := make([]string, 0, len(addedTaintsMap))
+	for addedTaint := range addedTaintsMap {
+		managedTaints = append(managedTaints, addedTaint.ToString())
+	}
+	sort.Strings(managedTaints)
+	return managedTaints
+}
+
+// appliedLabels returns the labels that FieldManager owns on the node according to its managedFields.
+func appliedLabels(node *corev1.Node) (map[string]struct{}, error) {
+	appliedLabelsMap := map[string]struct{}{}
+	for _, entry := range node.GetManagedFields() {
+		if entry.Manager != FieldManager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := struct {
+			Metadata struct {
+				Labels map[string]json.RawMessage `json:"f:labels"`
+			} `json:"f:metadata"`
+		}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return appliedLabelsMap, fmt.Errorf("failed to parse managedFields of field manager %q: %w", FieldManager, err)
+		}
+		for field := range fields.Metadata.Labels {
+			if strings.HasPrefix(field, "f:") {
+				appliedLabelsMap[strings.TrimPrefix(field, "f:")] = struct{}{}
+			}
+		}
+	}
+	return appliedLabelsMap, nil
+}
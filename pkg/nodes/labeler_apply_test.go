@@ -0,0 +1,159 @@
+package nodes
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func appliedNode(labels map[string]string, appliedLabels ...string) *corev1.Node {
+	node := defaultNode()
+	fields := map[string]interface{}{}
+	for key, value := range labels {
+		node.Labels[key] = value
+	}
+	for _, appliedLabel := range appliedLabels {
+		fields["f:"+appliedLabel] = map[string]interface{}{}
+	}
+	raw, _ := json.Marshal(map[string]interface{}{"f:metadata": map[string]interface{}{"f:labels": fields}})
+	node.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-label", Operation: metav1.ManagedFieldsOperationUpdate, FieldsType: "FieldsV1", FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:someExistingLabel":{}}}}`)}},
+		{Manager: FieldManager, Operation: metav1.ManagedFieldsOperationApply, FieldsType: "FieldsV1", FieldsV1: &metav1.FieldsV1{Raw: raw}},
+	}
+	return node
+}
+
+func Test_applyLabeler_Reconcile(t *testing.T) {
+	tests := []struct {
+		name              string
+		node              *corev1.Node
+		configNodeLabels  map[string]string
+		wantErr           error
+		wantUpdatedLabels map[string]string
+		wantApplyLabels   map[string]string
+		wantMigration     string
+	}{
+		{
+			name:              "add labels",
+			node:              defaultNode(),
+			configNodeLabels:  map[string]string{"region": "shelf-1"},
+			wantUpdatedLabels: map[string]string{"region": "shelf-1"},
+			wantApplyLabels:   map[string]string{"region": "shelf-1"},
+		},
+		{
+			name:              "labels already applied",
+			node:              appliedNode(map[string]string{"region": "shelf-1"}, "region"),
+			configNodeLabels:  map[string]string{"region": "shelf-1"},
+			wantErr:           errors.ErrSkipUpdate,
+			wantUpdatedLabels: map[string]string{},
+		},
+		{
+			name:              "take ownership of an existing label without changing it",
+			node:              defaultNode(),
+			configNodeLabels:  map[string]string{"someExistingLabel": "existingValue"},
+			wantUpdatedLabels: map[string]string{},
+			wantApplyLabels:   map[string]string{"someExistingLabel": "existingValue"},
+		},
+		{
+			name:              "change and remove applied labels",
+			node:              appliedNode(map[string]string{"region": "shelf-1", "zone": "a"}, "region", "zone"),
+			configNodeLabels:  map[string]string{"region": "shelf-2"},
+			wantUpdatedLabels: map[string]string{"region": "shelf-2", "zone": "(removed)"},
+			wantApplyLabels:   map[string]string{"region": "shelf-2"},
+		},
+		{
+			name:              "remove all applied labels",
+			node:              appliedNode(map[string]string{"region": "shelf-1"}, "region"),
+			wantUpdatedLabels: map[string]string{"region": "(removed)"},
+			wantApplyLabels:   map[string]string{},
+		},
+		{
+			name:              "keep applied labels that only the default policy denies",
+			node:              appliedNode(map[string]string{"node.kubernetes.io/exclude-from-external-load-balancers": "true"}, "node.kubernetes.io/exclude-from-external-load-balancers"),
+			configNodeLabels:  map[string]string{"node.kubernetes.io/exclude-from-external-load-balancers": "true", "kubernetes.io/os": "linux"},
+			wantErr:           errors.ErrSkipUpdate,
+			wantUpdatedLabels: map[string]string{},
+		},
+		{
+			name:              "migrate labels from the annotation",
+			node:              labeledNode(map[string]string{"region": "shelf-1", "zone": "a"}),
+			configNodeLabels:  map[string]string{"region": "shelf-1"},
+			wantUpdatedLabels: map[string]string{"zone": "(removed)"},
+			wantApplyLabels:   map[string]string{"region": "shelf-1"},
+			wantMigration:     `{"metadata":{"annotations":{"` + consts.AddedLabelsNodeAnnotation() + `":null},"labels":{"zone":null}}}`,
+		},
+		{
+			name:    "nil node",
+			wantErr: errors.ErrNilObjectPassed,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var node *corev1.Node
+			if tt.node != nil {
+				node = tt.node.DeepCopy()
+			}
+			l := NewApplyLabeler(nil)
+			err := l.Reconcile(node, tt.configNodeLabels)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.node == nil {
+				return
+			}
+			if !reflect.DeepEqual(node, tt.node) {
+				t.Errorf("Reconcile() must not update the node")
+			}
+			if !reflect.DeepEqual(l.UpdatedLabels(), tt.wantUpdatedLabels) {
+				t.Errorf("UpdatedLabels() = %v, want %v", l.UpdatedLabels(), tt.wantUpdatedLabels)
+			}
+			if err != nil {
+				return
+			}
+
+			patch, err := l.ApplyPatch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			applyConfig := struct {
+				APIVersion string `json:"apiVersion"`
+				Kind       string `json:"kind"`
+				Metadata   struct {
+					Name   string            `json:"name"`
+					Labels map[string]string `json:"labels"`
+				} `json:"metadata"`
+			}{}
+			if err = json.Unmarshal(patch, &applyConfig); err != nil {
+				t.Fatal(err)
+			}
+			if applyConfig.APIVersion != "v1" || applyConfig.Kind != "Node" || applyConfig.Metadata.Name != tt.node.GetName() {
+				t.Errorf("ApplyPatch() = %s, want the apiVersion, kind and name of the node", patch)
+			}
+			if applyConfig.Metadata.Labels == nil || !reflect.DeepEqual(applyConfig.Metadata.Labels, tt.wantApplyLabels) {
+				t.Errorf("ApplyPatch() labels = %v, want %v", applyConfig.Metadata.Labels, tt.wantApplyLabels)
+			}
+
+			migration, err := l.MigrationPatch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(migration) != tt.wantMigration {
+				t.Errorf("MigrationPatch() = %s, want %s", migration, tt.wantMigration)
+			}
+		})
+	}
+}
+
+func TestManagedLabels(t *testing.T) {
+	node := appliedNode(map[string]string{"region": "shelf-1"}, "region")
+	node.Annotations = labeledNode(map[string]string{"zone": "a"}).Annotations
+	if got, want := ManagedLabels(node), []string{"region", "zone"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ManagedLabels() = %v, want %v", got, want)
+	}
+}
@@ -1,10 +1,12 @@
 package nodes
 
 import (
+	"reflect"
 	"sort"
 	"strings"
 	"testing"
 
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
 	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
 	internalConsts "github.com/annismckenzie/k3os-config-operator/pkg/internal/consts"
 	corev1 "k8s.io/api/core/v1"
@@ -27,9 +29,21 @@ func defaultNode() *corev1.Node {
 	}).DeepCopy()
 }
 
+// ownedLabelsNode returns a node carrying the labels as if the operator added them regardless of the label policy.
+func ownedLabelsNode(labels map[string]string) *corev1.Node {
+	node := defaultNode()
+	addedLabelsMap := map[string]struct{}{}
+	for key, value := range labels {
+		node.Labels[key] = value
+		addedLabelsMap[key] = struct{}{}
+	}
+	updateAddedLabels(node, addedLabelsMap)
+	return node
+}
+
 func labeledNode(updateLabels map[string]string) *corev1.Node {
 	node := defaultNode()
-	l := NewLabeler()
+	l := NewLabeler(nil)
 	if err := l.Reconcile(node, updateLabels); err != nil {
 		panic(err)
 	}
@@ -44,9 +58,11 @@ func Test_labeler_Reconcile(t *testing.T) {
 	tests := []struct {
 		name                          string
 		args                          args
+		policy                        *configv1alpha1.LabelPolicy
 		wantErr                       error
 		expectedLabels                map[string]string
 		updatedLabels                 map[string]string
+		skippedLabels                 []string
 		expectedAddedLabelsAnnotation string
 	}{
 		{
@@ -164,11 +180,78 @@ func Test_labeler_Reconcile(t *testing.T) {
 			},
 			expectedAddedLabelsAnnotation: "",
 		},
+		{
+			name: "labels with reserved keys are skipped by default",
+			args: args{
+				node: &corev1.Node{},
+				configNodeLabels: map[string]string{
+					"someNewLabel":                   "value",
+					"node-role.kubernetes.io/worker": "true",
+					"kubernetes.io/arch":             "amd64",
+					"node.k3s.io/foo":                "bar",
+				},
+			},
+			expectedLabels: map[string]string{
+				"someNewLabel":                   "value",
+				"node-role.kubernetes.io/worker": "true",
+			},
+			updatedLabels: map[string]string{
+				"someNewLabel":                   "value",
+				"node-role.kubernetes.io/worker": "true",
+			},
+			skippedLabels:                 []string{"kubernetes.io/arch", "node.k3s.io/foo"},
+			expectedAddedLabelsAnnotation: "node-role.kubernetes.io/worker,someNewLabel",
+		},
+		{
+			name: "labels denied by the policy are skipped and labels we added before are removed",
+			args: args{
+				node: labeledNode(map[string]string{"rack": "a", "region": "shelf-1"}),
+				configNodeLabels: map[string]string{
+					"rack":   "a",
+					"region": "shelf-1",
+				},
+			},
+			policy: &configv1alpha1.LabelPolicy{Allowed: []string{"region"}, Denied: []string{"*"}},
+			expectedLabels: map[string]string{
+				"k3s.io/hostname":                  "n2-node",
+				"kubernetes.io/arch":               "arm64",
+				"node.kubernetes.io/instance-type": "k3s",
+				"someExistingLabel":                "existingValue",
+				"region":                           "shelf-1",
+			},
+			updatedLabels: map[string]string{
+				"rack": "(removed)",
+			},
+			skippedLabels:                 []string{"rack"},
+			expectedAddedLabelsAnnotation: "region",
+		},
+		{
+			name: "labels we added before that only the default policy denies are kept",
+			args: args{
+				node: ownedLabelsNode(map[string]string{"node.kubernetes.io/exclude-from-external-load-balancers": "true"}),
+				configNodeLabels: map[string]string{
+					"node.kubernetes.io/exclude-from-external-load-balancers": "false",
+					"node.kubernetes.io/other":                                "true",
+				},
+			},
+			expectedLabels: map[string]string{
+				"k3s.io/hostname":                  "n2-node",
+				"kubernetes.io/arch":               "arm64",
+				"node.kubernetes.io/instance-type": "k3s",
+				"someExistingLabel":                "existingValue",
+				"node.kubernetes.io/exclude-from-external-load-balancers": "false",
+			},
+			updatedLabels: map[string]string{
+				"node.kubernetes.io/exclude-from-external-load-balancers": "false",
+			},
+			skippedLabels:                 []string{"node.kubernetes.io/other"},
+			expectedAddedLabelsAnnotation: "node.kubernetes.io/exclude-from-external-load-balancers",
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			l := NewLabeler()
+			l := NewLabeler(tt.policy)
 			err := l.Reconcile(tt.args.node, tt.args.configNodeLabels)
 			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("labeler.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
@@ -185,6 +268,9 @@ func Test_labeler_Reconcile(t *testing.T) {
 					}
 				}
 			}
+			if !reflect.DeepEqual(l.SkippedLabels(), tt.skippedLabels) {
+				t.Errorf("labeler.SkippedLabels() = %v, want %v", l.SkippedLabels(), tt.skippedLabels)
+			}
 			updatedLabels := l.UpdatedLabels()
 			if len(tt.updatedLabels) != len(updatedLabels) {
 				t.Errorf("labeler.UpdatedLabels() expected updated labels = %v (len: %d), got %v (len: %d)",
@@ -199,7 +285,7 @@ func Test_labeler_Reconcile(t *testing.T) {
 				}
 			}
 
-			addedLabelsMap := addedLabels(tt.args.node)
+			addedLabelsMap, _, _ := addedLabels(tt.args.node)
 			var addedLabels []string
 			for addedLabel := range addedLabelsMap {
 				addedLabels = append(addedLabels, addedLabel)
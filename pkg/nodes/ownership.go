@@ -0,0 +1,214 @@
+package nodes
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	internalConsts "github.com/annismckenzie/k3os-config-operator/pkg/internal/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/util/taints"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ownershipVersion is the version of the format of the labelsAdded, taintsAdded, annotationsAdded and specFieldsAdded
+// annotations.
+const ownershipVersion = 1
+
+// ownershipRecord is the format of the labelsAdded, taintsAdded, annotationsAdded and specFieldsAdded annotations where
+// the operator keeps the labels, taints, annotations and spec fields it added to a node. Earlier versions kept them as
+// a comma-separated list which is migrated on the next update of the node.
+type ownershipRecord struct {
+	Version     int            `json:"version"`
+	Labels      []string       `json:"labels,omitempty"`
+	Taints      []corev1.Taint `json:"taints,omitempty"`
+	Annotations []string       `json:"annotations,omitempty"`
+	SpecFields  []string       `json:"specFields,omitempty"`
+}
+
+// OwnershipError is returned when the annotation where the operator keeps the labels, taints, annotations or spec
+// fields it added to a node can't be read completely. The entries that could be read are used and the annotation is rewritten on the next update.
+type OwnershipError struct {
+	Annotation string
+	Err        error
+}
+
+func (e *OwnershipError) Error() string {
+	return fmt.Sprintf("failed to parse annotation %s (it is repaired on the next update): %v", e.Annotation, e.Err)
+}
+
+func (e *OwnershipError) Unwrap() error {
+	return e.Err
+}
+
+// ownership reads the ownership record kept in the annotation. It returns whether the annotation has to be rewritten
+// because it uses the comma-separated format or can't be read. A record that can't be read is returned empty.
+func ownership(node *corev1.Node, annotation string) (record ownershipRecord, legacy []string, rewrite bool, err error) {
+	if node == nil {
+		return record, nil, false, nil
+	}
+	value, ok := node.GetAnnotations()[annotation]
+	if !ok {
+		return record, nil, false, nil
+	}
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") { // comma-separated format of earlier versions
+		for _, entry := range strings.Split(value, internalConsts.NodeAnnotationValueSeparator) {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				legacy = append(legacy, entry)
+			}
+		}
+		return record, legacy, true, nil
+	}
+	if err = json.Unmarshal([]byte(value), &record); err != nil {
+		return ownershipRecord{}, nil, true, &OwnershipError{Annotation: annotation, Err: err}
+	}
+	if record.Version != ownershipVersion {
+		return ownershipRecord{}, nil, true, &OwnershipError{Annotation: annotation, Err: fmt.Errorf("unsupported version %d", record.Version)}
+	}
+	return record, nil, false, nil
+}
+
+// setOwnership stores the ownership record in the annotation or removes the annotation if the record is empty.
+func setOwnership(node *corev1.Node, annotation string, record ownershipRecord) {
+	if len(record.Labels) == 0 && len(record.Taints) == 0 && len(record.Annotations) == 0 && len(record.SpecFields) == 0 {
+		delete(node.Annotations, annotation)
+		return
+	}
+	record.Version = ownershipVersion
+	value, _ := json.Marshal(record) // a record of strings and taints without a time always marshals
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[annotation] = string(value)
+}
+
+// addedLabels returns the labels the operator added to the node and kept in the labelsAdded annotation. It returns
+// whether the annotation has to be rewritten and an *OwnershipError if it can't be read completely.
+func addedLabels(node *corev1.Node) (map[string]struct{}, bool, error) {
+	record, legacy, rewrite, err := ownership(node, consts.AddedLabelsNodeAnnotation())
+	addedLabelsMap := map[string]struct{}{}
+	for _, addedLabel := range record.Labels {
+		addedLabelsMap[addedLabel] = struct{}{}
+	}
+	var invalid []string
+	for _, addedLabel := range legacy {
+		if len(validation.IsQualifiedName(addedLabel)) > 0 {
+			invalid = append(invalid, addedLabel)
+			continue
+		}
+		addedLabelsMap[addedLabel] = struct{}{}
+	}
+	if len(invalid) > 0 && err == nil {
+		err = &OwnershipError{Annotation: consts.AddedLabelsNodeAnnotation(), Err: fmt.Errorf("invalid label keys %q", invalid)}
+	}
+	return addedLabelsMap, rewrite, err
+}
+
+// updateAddedLabels stores the labels the operator added to the node in the labelsAdded annotation.
+func updateAddedLabels(node *corev1.Node, addedLabelsMap map[string]struct{}) {
+	record := ownershipRecord{Labels: make([]string, 0, len(addedLabelsMap))}
+	for addedLabel := range addedLabelsMap {
+		record.Labels = append(record.Labels, addedLabel)
+	}
+	sort.Strings(record.Labels)
+	setOwnership(node, consts.AddedLabelsNodeAnnotation(), record)
+}
+
+// getAddedTaints returns the taints the operator added to the node and kept in the taintsAdded annotation. It returns
+// whether the annotation has to be rewritten and an *OwnershipError if it can't be read completely.
+func getAddedTaints(node *corev1.Node) (map[corev1.Taint]struct{}, bool, error) {
+	record, legacy, rewrite, err := ownership(node, consts.AddedTaintsNodeAnnotation())
+	addedTaintsMap := map[corev1.Taint]struct{}{}
+	for _, addedTaint := range record.Taints {
+		addedTaint.TimeAdded = nil
+		addedTaintsMap[addedTaint] = struct{}{}
+	}
+	var invalid []string
+	for _, addedTaint := range legacy {
+		parsed, _, parseErr := taints.ParseTaints([]string{addedTaint})
+		if parseErr != nil || len(parsed) != 1 {
+			invalid = append(invalid, addedTaint)
+			continue
+		}
+		addedTaintsMap[parsed[0]] = struct{}{}
+	}
+	if len(invalid) > 0 && err == nil {
+		err = &OwnershipError{Annotation: consts.AddedTaintsNodeAnnotation(), Err: fmt.Errorf("invalid taints %q", invalid)}
+	}
+	return addedTaintsMap, rewrite, err
+}
+
+// updateAddedTaints stores the taints the operator added to the node in the taintsAdded annotation.
+func updateAddedTaints(node *corev1.Node, addedTaintsMap map[corev1.Taint]struct{}) {
+	record := ownershipRecord{Taints: make([]corev1.Taint, 0, len(addedTaintsMap))}
+	for addedTaint := range addedTaintsMap {
+		addedTaint.TimeAdded = nil
+		record.Taints = append(record.Taints, addedTaint)
+	}
+	sort.Slice(record.Taints, func(i, j int) bool { return record.Taints[i].ToString() < record.Taints[j].ToString() })
+	setOwnership(node, consts.AddedTaintsNodeAnnotation(), record)
+}
+
+// addedAnnotations returns the annotations the operator added to the node and kept in the annotationsAdded annotation.
+// It returns whether the annotation has to be rewritten and an *OwnershipError if it can't be read completely.
+func addedAnnotations(node *corev1.Node) (map[string]struct{}, bool, error) {
+	record, legacy, rewrite, err := ownership(node, consts.AddedAnnotationsNodeAnnotation())
+	addedAnnotationsMap := map[string]struct{}{}
+	for _, addedAnnotation := range record.Annotations {
+		addedAnnotationsMap[addedAnnotation] = struct{}{}
+	}
+	var invalid []string
+	for _, addedAnnotation := range legacy {
+		if len(validation.IsQualifiedName(addedAnnotation)) > 0 {
+			invalid = append(invalid, addedAnnotation)
+			continue
+		}
+		addedAnnotationsMap[addedAnnotation] = struct{}{}
+	}
+	if len(invalid) > 0 && err == nil {
+		err = &OwnershipError{Annotation: consts.AddedAnnotationsNodeAnnotation(), Err: fmt.Errorf("invalid annotation keys %q", invalid)}
+	}
+	return addedAnnotationsMap, rewrite, err
+}
+
+// updateAddedAnnotations stores the annotations the operator added to the node in the annotationsAdded annotation.
+func updateAddedAnnotations(node *corev1.Node, addedAnnotationsMap map[string]struct{}) {
+	record := ownershipRecord{Annotations: make([]string, 0, len(addedAnnotationsMap))}
+	for addedAnnotation := range addedAnnotationsMap {
+		record.Annotations = append(record.Annotations, addedAnnotation)
+	}
+	sort.Strings(record.Annotations)
+	setOwnership(node, consts.AddedAnnotationsNodeAnnotation(), record)
+}
+
+// addedSpecFields returns the node spec fields the operator set and kept in the specFieldsAdded annotation. It returns
+// whether the annotation has to be rewritten and an *OwnershipError if it can't be read completely.
+func addedSpecFields(node *corev1.Node) (map[string]struct{}, bool, error) {
+	record, legacy, rewrite, err := ownership(node, consts.AddedSpecFieldsNodeAnnotation())
+	addedSpecFieldsMap := map[string]struct{}{}
+	var invalid []string
+	for _, addedSpecField := range append(record.SpecFields, legacy...) {
+		if addedSpecField != SpecFieldUnschedulable && addedSpecField != SpecFieldProviderID {
+			invalid = append(invalid, addedSpecField)
+			rewrite = true
+			continue
+		}
+		addedSpecFieldsMap[addedSpecField] = struct{}{}
+	}
+	if len(invalid) > 0 && err == nil {
+		err = &OwnershipError{Annotation: consts.AddedSpecFieldsNodeAnnotation(), Err: fmt.Errorf("unknown spec fields %q", invalid)}
+	}
+	return addedSpecFieldsMap, rewrite, err
+}
+
+// updateAddedSpecFields stores the node spec fields the operator set in the specFieldsAdded annotation.
+func updateAddedSpecFields(node *corev1.Node, addedSpecFieldsMap map[string]struct{}) {
+	record := ownershipRecord{SpecFields: make([]string, 0, len(addedSpecFieldsMap))}
+	for addedSpecField := range addedSpecFieldsMap {
+		record.SpecFields = append(record.SpecFields, addedSpecField)
+	}
+	sort.Strings(record.SpecFields)
+	setOwnership(node, consts.AddedSpecFieldsNodeAnnotation(), record)
+}
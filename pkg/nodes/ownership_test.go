@@ -0,0 +1,291 @@
+package nodes
+
+import (
+	"reflect"
+	"testing"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func ownedNode(labelsAdded, taintsAdded string) *corev1.Node {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: map[string]string{}, Annotations: map[string]string{}}}
+	if labelsAdded != "" {
+		node.Annotations[consts.AddedLabelsNodeAnnotation()] = labelsAdded
+	}
+	if taintsAdded != "" {
+		node.Annotations[consts.AddedTaintsNodeAnnotation()] = taintsAdded
+	}
+	return node
+}
+
+func Test_labeler_Reconcile_ownership(t *testing.T) {
+	tests := []struct {
+		name             string
+		node             *corev1.Node
+		configNodeLabels map[string]string
+		wantErr          error
+		wantOwnershipErr bool
+		wantLabels       map[string]string
+		wantAnnotation   string
+	}{
+		{
+			name: "current format is kept",
+			node: func() *corev1.Node {
+				node := ownedNode(`{"version":1,"labels":["region"]}`, "")
+				node.Labels["region"] = "shelf-1"
+				return node
+			}(),
+			configNodeLabels: map[string]string{"region": "shelf-1"},
+			wantErr:          errors.ErrSkipUpdate,
+			wantAnnotation:   `{"version":1,"labels":["region"]}`,
+		},
+		{
+			name: "comma-separated format is migrated",
+			node: func() *corev1.Node {
+				node := ownedNode("region,zone", "")
+				node.Labels = map[string]string{"region": "shelf-1", "zone": "a"}
+				return node
+			}(),
+			configNodeLabels: map[string]string{"region": "shelf-1"},
+			wantLabels:       map[string]string{"region": "shelf-1"},
+			wantAnnotation:   `{"version":1,"labels":["region"]}`,
+		},
+		{
+			name:             "unchanged labels in the comma-separated format are migrated",
+			node:             ownedNode("region", ""),
+			configNodeLabels: map[string]string{"region": "shelf-1"},
+			wantAnnotation:   `{"version":1,"labels":["region"]}`,
+		},
+		{
+			name:             "invalid label keys in the comma-separated format are dropped",
+			node:             ownedNode("region,not a label", ""),
+			configNodeLabels: map[string]string{"region": "shelf-1"},
+			wantOwnershipErr: true,
+			wantAnnotation:   `{"version":1,"labels":["region"]}`,
+		},
+		{
+			name:             "corrupt annotation is repaired",
+			node:             ownedNode(`{"version":1,"labels":["region"`, ""),
+			configNodeLabels: map[string]string{"region": "shelf-1"},
+			wantOwnershipErr: true,
+			wantAnnotation:   `{"version":1,"labels":["region"]}`,
+		},
+		{
+			name:             "annotation with an unsupported version is repaired",
+			node:             ownedNode(`{"version":2,"labels":["region"]}`, ""),
+			wantOwnershipErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLabeler(nil)
+			err := l.Reconcile(tt.node, tt.configNodeLabels)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("labeler.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var ownershipErr *OwnershipError
+			if gotOwnershipErr := errors.As(l.OwnershipError(), &ownershipErr); gotOwnershipErr != tt.wantOwnershipErr {
+				t.Errorf("labeler.OwnershipError() = %v, want an error %v", l.OwnershipError(), tt.wantOwnershipErr)
+			}
+			if tt.wantLabels != nil && !reflect.DeepEqual(tt.node.GetLabels(), tt.wantLabels) {
+				t.Errorf("labeler.Reconcile() labels = %v, want %v", tt.node.GetLabels(), tt.wantLabels)
+			}
+			if got := tt.node.GetAnnotations()[consts.AddedLabelsNodeAnnotation()]; got != tt.wantAnnotation {
+				t.Errorf("labeler.Reconcile() annotation = %q, want %q", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
+
+func Test_tainter_Reconcile_ownership(t *testing.T) {
+	taint := corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule}
+
+	tests := []struct {
+		name             string
+		node             *corev1.Node
+		configNodeTaints []string
+		wantErr          error
+		wantOwnershipErr bool
+		wantTaints       []corev1.Taint
+		wantAnnotation   string
+	}{
+		{
+			name: "current format is kept",
+			node: func() *corev1.Node {
+				node := ownedNode("", `{"version":1,"taints":[{"key":"dedicated","value":"db","effect":"NoSchedule"}]}`)
+				node.Spec.Taints = []corev1.Taint{taint}
+				return node
+			}(),
+			configNodeTaints: []string{"dedicated=db:NoSchedule"},
+			wantErr:          errors.ErrSkipUpdate,
+			wantAnnotation:   `{"version":1,"taints":[{"key":"dedicated","value":"db","effect":"NoSchedule"}]}`,
+		},
+		{
+			name: "unchanged taints in the comma-separated format are migrated",
+			node: func() *corev1.Node {
+				node := ownedNode("", "dedicated=db:NoSchedule")
+				node.Spec.Taints = []corev1.Taint{taint}
+				return node
+			}(),
+			configNodeTaints: []string{"dedicated=db:NoSchedule"},
+			wantTaints:       []corev1.Taint{taint},
+			wantAnnotation:   `{"version":1,"taints":[{"key":"dedicated","value":"db","effect":"NoSchedule"}]}`,
+		},
+		{
+			name: "taints that can't be parsed don't panic and are dropped",
+			node: func() *corev1.Node {
+				node := ownedNode("", "dedicated=db:NoSchedule,broken=taint=:Sometimes")
+				node.Spec.Taints = []corev1.Taint{taint}
+				return node
+			}(),
+			wantOwnershipErr: true,
+		},
+		{
+			name:             "corrupt annotation is repaired",
+			node:             ownedNode("", `{"version":1,"taints":"dedicated"}`),
+			configNodeTaints: []string{"dedicated=db:NoSchedule"},
+			wantOwnershipErr: true,
+			wantTaints:       []corev1.Taint{taint},
+			wantAnnotation:   `{"version":1,"taints":[{"key":"dedicated","value":"db","effect":"NoSchedule"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tainter := NewTainter(nil)
+			err := tainter.Reconcile(tt.node, tt.configNodeTaints)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("tainter.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var ownershipErr *OwnershipError
+			if gotOwnershipErr := errors.As(tainter.OwnershipError(), &ownershipErr); gotOwnershipErr != tt.wantOwnershipErr {
+				t.Errorf("tainter.OwnershipError() = %v, want an error %v", tainter.OwnershipError(), tt.wantOwnershipErr)
+			}
+			if tt.wantTaints != nil && !reflect.DeepEqual(tt.node.Spec.Taints, tt.wantTaints) {
+				t.Errorf("tainter.Reconcile() taints = %v, want %v", tt.node.Spec.Taints, tt.wantTaints)
+			}
+			if got := tt.node.GetAnnotations()[consts.AddedTaintsNodeAnnotation()]; got != tt.wantAnnotation {
+				t.Errorf("tainter.Reconcile() annotation = %q, want %q", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
+
+func Test_annotator_Reconcile_ownership(t *testing.T) {
+	tests := []struct {
+		name              string
+		annotationsAdded  string
+		configAnnotations map[string]string
+		wantErr           error
+		wantOwnershipErr  bool
+		wantAnnotation    string
+	}{
+		{
+			name:              "current format is kept",
+			annotationsAdded:  `{"version":1,"annotations":["example.com/rack"]}`,
+			configAnnotations: map[string]string{"example.com/rack": "1"},
+			wantErr:           errors.ErrSkipUpdate,
+			wantAnnotation:    `{"version":1,"annotations":["example.com/rack"]}`,
+		},
+		{
+			name:              "comma-separated format is migrated",
+			annotationsAdded:  "example.com/rack,example.com/row",
+			configAnnotations: map[string]string{"example.com/rack": "1"},
+			wantAnnotation:    `{"version":1,"annotations":["example.com/rack"]}`,
+		},
+		{
+			name:              "invalid annotation keys in the comma-separated format are dropped",
+			annotationsAdded:  "example.com/rack,not an annotation",
+			configAnnotations: map[string]string{"example.com/rack": "1"},
+			wantOwnershipErr:  true,
+			wantAnnotation:    `{"version":1,"annotations":["example.com/rack"]}`,
+		},
+		{
+			name:              "corrupt annotation is repaired",
+			annotationsAdded:  `{"version":1,"annotations":"example.com/rack"}`,
+			configAnnotations: map[string]string{"example.com/rack": "1"},
+			wantOwnershipErr:  true,
+			wantAnnotation:    `{"version":1,"annotations":["example.com/rack"]}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			node := ownedNode("", "")
+			node.Annotations[consts.AddedAnnotationsNodeAnnotation()] = tt.annotationsAdded
+			node.Annotations["example.com/rack"] = "1"
+			a := NewAnnotator()
+			err := a.Reconcile(node, tt.configAnnotations)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("annotator.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var ownershipErr *OwnershipError
+			if gotOwnershipErr := errors.As(a.OwnershipError(), &ownershipErr); gotOwnershipErr != tt.wantOwnershipErr {
+				t.Errorf("annotator.OwnershipError() = %v, want an error %v", a.OwnershipError(), tt.wantOwnershipErr)
+			}
+			if got := node.GetAnnotations()[consts.AddedAnnotationsNodeAnnotation()]; got != tt.wantAnnotation {
+				t.Errorf("annotator.Reconcile() annotation = %q, want %q", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
+
+func Test_specReconciler_Reconcile_ownership(t *testing.T) {
+	yes := true
+
+	tests := []struct {
+		name             string
+		specFieldsAdded  string
+		wantErr          error
+		wantOwnershipErr bool
+		wantAnnotation   string
+	}{
+		{
+			name:            "current format is kept",
+			specFieldsAdded: `{"version":1,"specFields":["unschedulable"]}`,
+			wantErr:         errors.ErrSkipUpdate,
+			wantAnnotation:  `{"version":1,"specFields":["unschedulable"]}`,
+		},
+		{
+			name:            "comma-separated format is migrated",
+			specFieldsAdded: "unschedulable",
+			wantAnnotation:  `{"version":1,"specFields":["unschedulable"]}`,
+		},
+		{
+			name:             "unknown spec fields are dropped",
+			specFieldsAdded:  `{"version":1,"specFields":["podCIDR","unschedulable"]}`,
+			wantOwnershipErr: true,
+			wantAnnotation:   `{"version":1,"specFields":["unschedulable"]}`,
+		},
+		{
+			name:             "corrupt annotation is removed",
+			specFieldsAdded:  `{"version":1,"specFields":["unschedulable"`,
+			wantOwnershipErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			node := ownedNode("", "")
+			node.Annotations[consts.AddedSpecFieldsNodeAnnotation()] = tt.specFieldsAdded
+			node.Spec.Unschedulable = true
+			r := NewNodeSpecReconciler("")
+			err := r.Reconcile(node, &configv1alpha1.K3OSConfigFileSectionOperator{Unschedulable: &yes})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("specReconciler.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var ownershipErr *OwnershipError
+			if gotOwnershipErr := errors.As(r.OwnershipError(), &ownershipErr); gotOwnershipErr != tt.wantOwnershipErr {
+				t.Errorf("specReconciler.OwnershipError() = %v, want an error %v", r.OwnershipError(), tt.wantOwnershipErr)
+			}
+			if got := node.GetAnnotations()[consts.AddedSpecFieldsNodeAnnotation()]; got != tt.wantAnnotation {
+				t.Errorf("specReconciler.Reconcile() annotation = %q, want %q", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
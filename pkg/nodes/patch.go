@@ -0,0 +1,158 @@
+package nodes
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	jsonpatch "github.com/evanphx/json-patch"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// jsonPatchOperation is an operation of a JSON patch (RFC 6902).
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// jsonNull is the value of a test operation that checks that a field doesn't exist.
+var jsonNull = json.RawMessage("null")
+
+// NodePatch returns a JSON patch (RFC 6902) that changes the labels, annotations, taints, unschedulable and
+// providerID fields of the original node to the ones of the updated node; all other fields are left alone.
+// Every change is preceded by a test of the original value of the field so the patch fails (see
+// IsPatchTestFailed) instead of overwriting a concurrent change to the same field while changes to other
+// fields of the node (e.g. the status updated by the kubelet) don't cause conflicts. It will return
+// errors.ErrSkipUpdate if nothing changed.
+func NodePatch(original, updated *corev1.Node) ([]byte, error) {
+	if original == nil || updated == nil {
+		return nil, errors.ErrNilObjectPassed
+	}
+	var operations []jsonPatchOperation
+	for _, ops := range [][]jsonPatchOperation{
+		mapOperations("/metadata/labels", original.GetLabels(), updated.GetLabels()),
+		mapOperations("/metadata/annotations", original.GetAnnotations(), updated.GetAnnotations()),
+	} {
+		operations = append(operations, ops...)
+	}
+
+	if !equality.Semantic.DeepEqual(original.Spec.Taints, updated.Spec.Taints) {
+		ops, err := taintsOperations(original.Spec.Taints, updated.Spec.Taints)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, ops...)
+	}
+	if original.Spec.Unschedulable != updated.Spec.Unschedulable {
+		operations = append(operations, scalarOperations("/spec/unschedulable", original.Spec.Unschedulable, updated.Spec.Unschedulable, !original.Spec.Unschedulable, !updated.Spec.Unschedulable)...)
+	}
+	if original.Spec.ProviderID != updated.Spec.ProviderID {
+		operations = append(operations, scalarOperations("/spec/providerID", original.Spec.ProviderID, updated.Spec.ProviderID, original.Spec.ProviderID == "", updated.Spec.ProviderID == "")...)
+	}
+
+	if len(operations) == 0 {
+		return nil, errors.ErrSkipUpdate
+	}
+	return json.Marshal(operations)
+}
+
+// IsPatchTestFailed returns whether the error was returned because a test operation of a patch returned by
+// NodePatch failed, i.e. a field the patch changes was changed concurrently.
+func IsPatchTestFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, jsonpatch.ErrTestFailed) { // returned as is by fake clientsets
+		return true
+	}
+	return apierrors.IsInvalid(err) && strings.Contains(err.Error(), jsonpatch.ErrTestFailed.Error())
+}
+
+func mapOperations(path string, original, updated map[string]string) []jsonPatchOperation {
+	if len(original) == 0 { // the map doesn't exist (empty maps are omitted)
+		if len(updated) == 0 {
+			return nil
+		}
+		value, _ := json.Marshal(updated)
+		return []jsonPatchOperation{{Op: "test", Path: path, Value: jsonNull}, {Op: "add", Path: path, Value: value}}
+	}
+
+	keys := make([]string, 0, len(original)+len(updated))
+	for key := range original {
+		keys = append(keys, key)
+	}
+	for key := range updated {
+		if _, ok := original[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var operations []jsonPatchOperation
+	for _, key := range keys {
+		keyPath := path + "/" + escapeJSONPointer(key)
+		originalValue, inOriginal := original[key]
+		updatedValue, inUpdated := updated[key]
+		switch {
+		case inOriginal && !inUpdated:
+			operations = append(operations, testOperation(keyPath, originalValue), jsonPatchOperation{Op: "remove", Path: keyPath})
+		case !inOriginal && inUpdated:
+			operations = append(operations, jsonPatchOperation{Op: "test", Path: keyPath, Value: jsonNull}, stringOperation("add", keyPath, updatedValue))
+		case originalValue != updatedValue:
+			operations = append(operations, testOperation(keyPath, originalValue), stringOperation("replace", keyPath, updatedValue))
+		}
+	}
+	return operations
+}
+
+func taintsOperations(original, updated []corev1.Taint) ([]jsonPatchOperation, error) {
+	const path = "/spec/taints"
+	originalValue, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+	updatedValue, err := json.Marshal(updated)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(original) == 0:
+		return []jsonPatchOperation{{Op: "test", Path: path, Value: jsonNull}, {Op: "add", Path: path, Value: updatedValue}}, nil
+	case len(updated) == 0:
+		return []jsonPatchOperation{{Op: "test", Path: path, Value: originalValue}, {Op: "remove", Path: path}}, nil
+	default:
+		return []jsonPatchOperation{{Op: "test", Path: path, Value: originalValue}, {Op: "replace", Path: path, Value: updatedValue}}, nil
+	}
+}
+
+// scalarOperations returns the operations that change a scalar field of the node spec that is omitted if it is empty.
+func scalarOperations(path string, original, updated interface{}, originalEmpty, updatedEmpty bool) []jsonPatchOperation {
+	originalValue, _ := json.Marshal(original)
+	updatedValue, _ := json.Marshal(updated)
+	switch {
+	case originalEmpty:
+		return []jsonPatchOperation{{Op: "test", Path: path, Value: jsonNull}, {Op: "add", Path: path, Value: updatedValue}}
+	case updatedEmpty:
+		return []jsonPatchOperation{{Op: "test", Path: path, Value: originalValue}, {Op: "remove", Path: path}}
+	default:
+		return []jsonPatchOperation{{Op: "test", Path: path, Value: originalValue}, {Op: "replace", Path: path, Value: updatedValue}}
+	}
+}
+
+func testOperation(path, value string) jsonPatchOperation {
+	return stringOperation("test", path, value)
+}
+
+func stringOperation(op, path, value string) jsonPatchOperation {
+	raw, _ := json.Marshal(value)
+	return jsonPatchOperation{Op: op, Path: path, Value: raw}
+}
+
+// escapeJSONPointer escapes a map key for use in a JSON pointer (RFC 6901).
+func escapeJSONPointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -0,0 +1,148 @@
+package nodes
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodePatch(t *testing.T) {
+	taint := corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule}
+	otherTaint := corev1.Taint{Key: "node.kubernetes.io/unreachable", Effect: corev1.TaintEffectNoExecute}
+
+	tests := []struct {
+		name       string
+		original   *corev1.Node
+		update     func(*corev1.Node)
+		concurrent func(*corev1.Node)
+		wantErr    error
+		wantFailed bool
+	}{
+		{
+			name:     "nothing changed",
+			original: defaultNode(),
+			update:   func(*corev1.Node) {},
+			wantErr:  errors.ErrSkipUpdate,
+		},
+		{
+			name:     "add, change and remove labels and annotations",
+			original: defaultNode(),
+			update: func(node *corev1.Node) {
+				node.Labels["region"] = "shelf-1"
+				node.Labels["someExistingLabel"] = "changedValue"
+				delete(node.Labels, "k3s.io/hostname")
+				node.Annotations = map[string]string{"example.com/annotation": "value"}
+			},
+		},
+		{
+			name:     "add taints",
+			original: defaultNode(),
+			update:   func(node *corev1.Node) { node.Spec.Taints = []corev1.Taint{taint} },
+		},
+		{
+			name: "change and remove taints",
+			original: func() *corev1.Node {
+				node := defaultNode()
+				node.Spec.Taints = []corev1.Taint{taint, otherTaint}
+				return node
+			}(),
+			update: func(node *corev1.Node) { node.Spec.Taints = node.Spec.Taints[1:] },
+		},
+		{
+			name:     "cordon the node and set the provider ID",
+			original: defaultNode(),
+			update: func(node *corev1.Node) {
+				node.Spec.Unschedulable = true
+				node.Spec.ProviderID = "k3os://node"
+			},
+		},
+		{
+			name: "uncordon the node",
+			original: func() *corev1.Node {
+				node := defaultNode()
+				node.Spec.Unschedulable = true
+				return node
+			}(),
+			update: func(node *corev1.Node) { node.Spec.Unschedulable = false },
+		},
+		{
+			name:     "concurrent changes to other fields don't conflict",
+			original: defaultNode(),
+			update:   func(node *corev1.Node) { node.Labels["region"] = "shelf-1" },
+			concurrent: func(node *corev1.Node) {
+				node.Labels["kubernetes.io/os"] = "linux"
+				node.Annotations = map[string]string{"node.alpha.kubernetes.io/ttl": "0"}
+				node.Status.Phase = corev1.NodeRunning
+			},
+		},
+		{
+			name:       "concurrent change to a changed label fails",
+			original:   defaultNode(),
+			update:     func(node *corev1.Node) { node.Labels["someExistingLabel"] = "changedValue" },
+			concurrent: func(node *corev1.Node) { node.Labels["someExistingLabel"] = "otherValue" },
+			wantFailed: true,
+		},
+		{
+			name:       "concurrent change to the taints fails",
+			original:   defaultNode(),
+			update:     func(node *corev1.Node) { node.Spec.Taints = []corev1.Taint{taint} },
+			concurrent: func(node *corev1.Node) { node.Spec.Taints = []corev1.Taint{otherTaint} },
+			wantFailed: true,
+		},
+		{
+			name:       "concurrent change to the provider ID fails",
+			original:   defaultNode(),
+			update:     func(node *corev1.Node) { node.Spec.ProviderID = "k3os://node" },
+			concurrent: func(node *corev1.Node) { node.Spec.ProviderID = "aws:///eu-central-1a/i-0123" },
+			wantFailed: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			updated := tt.original.DeepCopy()
+			tt.update(updated)
+			patch, err := NodePatch(tt.original, updated)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NodePatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			current := tt.original.DeepCopy()
+			if tt.concurrent != nil {
+				tt.concurrent(current)
+			}
+			clientset := fake.NewSimpleClientset(current)
+			patched, err := clientset.CoreV1().Nodes().Patch(context.Background(), current.GetName(), types.JSONPatchType, patch, metav1.PatchOptions{})
+			if IsPatchTestFailed(err) != tt.wantFailed {
+				t.Fatalf("Patch() error = %v, wantFailed %v", err, tt.wantFailed)
+			}
+			if tt.wantFailed {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := updated.DeepCopy()
+			if tt.concurrent != nil {
+				tt.concurrent(want) // concurrent changes to fields the patch doesn't change are kept
+				if patched.Status.Phase != want.Status.Phase {
+					t.Errorf("Patch() status = %v, want %v", patched.Status, want.Status)
+				}
+			}
+			if !reflect.DeepEqual(patched.Labels, want.Labels) || !reflect.DeepEqual(patched.Annotations, want.Annotations) || !reflect.DeepEqual(patched.Spec.Taints, want.Spec.Taints) ||
+				patched.Spec.Unschedulable != want.Spec.Unschedulable || patched.Spec.ProviderID != want.Spec.ProviderID {
+				t.Errorf("Patch() = %v, want %v", patched, want)
+			}
+		})
+	}
+}
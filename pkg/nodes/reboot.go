@@ -0,0 +1,284 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// liveFields contains the paths of the node config file fields that the operator applies to a running node.
+// The k3os_config_operator section is only read by the operator and is live as a whole.
+// Changes to all other fields only take effect when the node boots.
+var liveFields = map[string]bool{
+	"k3os.labels":          true,
+	"k3os.taints":          true,
+	"k3os_config_operator": true,
+}
+
+// BootTimeChanges returns the paths (e.g. k3os.modules, run_cmd) of the fields that differ between both node config files
+// and that only take effect when the node boots. Labels, taints and the k3os_config_operator section are applied to the
+// running node and are never returned.
+func BootTimeChanges(oldData, newData []byte) ([]string, error) {
+	oldConfig, err := parseGenericConfig(oldData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse current node config file: %w", err)
+	}
+	newConfig, err := parseGenericConfig(newData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new node config file: %w", err)
+	}
+
+	var changes []string
+	for _, key := range unionKeys(oldConfig, newConfig) {
+		oldSection, oldOk := oldConfig[key].(map[string]interface{})
+		newSection, newOk := newConfig[key].(map[string]interface{})
+		if key != "k3os" || !oldOk || !newOk { // only the k3os section contains live fields
+			if !reflect.DeepEqual(oldConfig[key], newConfig[key]) && !liveFields[key] {
+				changes = append(changes, key)
+			}
+			continue
+		}
+		for _, sectionKey := range unionKeys(oldSection, newSection) {
+			path := key + "." + sectionKey
+			if !reflect.DeepEqual(oldSection[sectionKey], newSection[sectionKey]) && !liveFields[path] {
+				changes = append(changes, path)
+			}
+		}
+	}
+	return changes, nil
+}
+
+func parseGenericConfig(data []byte) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if config["k3os"] == nil { // compare the fields of a missing k3os section with the ones of an existing one
+		config["k3os"] = map[string]interface{}{}
+	}
+	return config, nil
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Rebooter reboots the node the operator is running on.
+type Rebooter interface {
+	Reboot(ctx context.Context) error
+}
+
+// NewCommandRebooter returns a Rebooter that reboots the node by running the given command.
+func NewCommandRebooter(command []string) (Rebooter, error) {
+	if len(command) == 0 {
+		return nil, errors.New("the reboot command must not be empty")
+	}
+	return &commandRebooter{command: command}, nil
+}
+
+type commandRebooter struct {
+	command []string
+}
+
+// Reboot runs the reboot command. It returns an error containing the output of the command if it fails.
+func (r *commandRebooter) Reboot(ctx context.Context) error {
+	output, err := exec.CommandContext(ctx, r.command[0], r.command[1:]...).CombinedOutput() //nolint:gosec // the command is configured by the cluster admin
+	if err != nil {
+		return fmt.Errorf("failed to run reboot command %q: %w (output: %s)", strings.Join(r.command, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// rebootRequired is the value of the rebootRequired annotation. The boot ID tells whether the changes were recorded
+// in the current boot of the node: once the node booted again, they took effect.
+type rebootRequired struct {
+	BootID  string   `json:"bootID"`
+	Changes []string `json:"changes"`
+}
+
+// GetBootTimeChanges returns the boot-time changes recorded on the node that are waiting for a reboot.
+// Changes that were recorded before the node was last booted already took effect and aren't returned.
+func GetBootTimeChanges(node *corev1.Node) []string {
+	value, ok := node.GetAnnotations()[consts.RebootRequiredNodeAnnotation()]
+	if !ok {
+		return nil
+	}
+	record := rebootRequired{}
+	if err := json.Unmarshal([]byte(value), &record); err != nil || record.BootID != node.Status.NodeInfo.BootID {
+		return nil
+	}
+	return record.Changes
+}
+
+// Rebootable returns whether the operator running on the node is able to reboot it.
+func Rebootable(node *corev1.Node) bool {
+	_, ok := node.GetAnnotations()[consts.RebootableNodeAnnotation()]
+	return ok
+}
+
+// RebootRequiredPatch returns a JSON merge patch that records the boot-time changes on the node in its current boot,
+// merging them with the changes that are already waiting for a reboot. Changes recorded before the node was last
+// booted are dropped. The node is marked as rebootable if its operator is able to reboot it so that the leader
+// only approves reboots that are carried out. It returns ErrSkipUpdate if the annotations are up to date.
+func RebootRequiredPatch(node *corev1.Node, changes []string, rebootable bool) ([]byte, error) {
+	merged := map[string]bool{}
+	for _, change := range append(GetBootTimeChanges(node), changes...) {
+		merged[change] = true
+	}
+	all := make([]string, 0, len(merged))
+	for change := range merged {
+		all = append(all, change)
+	}
+	sort.Strings(all)
+
+	desired := map[string]string{}
+	if len(all) > 0 {
+		value, err := json.Marshal(rebootRequired{BootID: node.Status.NodeInfo.BootID, Changes: all})
+		if err != nil {
+			return nil, err
+		}
+		desired[consts.RebootRequiredNodeAnnotation()] = string(value)
+	}
+	if rebootable {
+		desired[consts.RebootableNodeAnnotation()] = "true"
+	}
+	return desiredAnnotationsPatch(node, desired, consts.RebootRequiredNodeAnnotation(), consts.RebootableNodeAnnotation())
+}
+
+// DisableRebootsPatch returns a JSON merge patch that removes all reboot annotations from the node and uncordons it
+// if the operator cordoned it. The boot-time changes are forgotten because nobody is going to reboot the node.
+// It returns ErrSkipUpdate if the node doesn't carry any reboot annotations.
+func DisableRebootsPatch(node *corev1.Node) ([]byte, error) {
+	annotations := map[string]interface{}{}
+	for _, key := range []string{
+		consts.RebootRequiredNodeAnnotation(), consts.RebootableNodeAnnotation(), consts.RebootApprovedNodeAnnotation(),
+		consts.RebootApprovedAtNodeAnnotation(), consts.RebootCordonedNodeAnnotation(),
+	} {
+		if _, ok := node.GetAnnotations()[key]; ok {
+			annotations[key] = nil
+		}
+	}
+	if len(annotations) == 0 {
+		return nil, errors.ErrSkipUpdate
+	}
+	var spec map[string]interface{}
+	if _, ok := annotations[consts.RebootCordonedNodeAnnotation()]; ok {
+		spec = map[string]interface{}{"unschedulable": nil}
+	}
+	return annotationsPatch(annotations, spec)
+}
+
+// RebootApproved returns whether the leader approved rebooting the node in its current boot.
+func RebootApproved(node *corev1.Node) bool {
+	bootID, ok := node.GetAnnotations()[consts.RebootApprovedNodeAnnotation()]
+	return ok && bootID == node.Status.NodeInfo.BootID
+}
+
+// RebootCompleted returns whether the node was rebooted after the leader approved the reboot and is Ready again.
+func RebootCompleted(node *corev1.Node) bool {
+	bootID, ok := node.GetAnnotations()[consts.RebootApprovedNodeAnnotation()]
+	return ok && bootID != node.Status.NodeInfo.BootID && IsReady(node)
+}
+
+// IsReady returns whether the Ready condition of the node is true.
+func IsReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// RebootApprovalExpired returns whether the leader approved rebooting the node longer than timeout ago.
+// Approvals without a valid approval time are expired so that they don't block other nodes forever.
+func RebootApprovalExpired(node *corev1.Node, timeout time.Duration, now time.Time) bool {
+	approvedAt, err := time.Parse(time.RFC3339, node.GetAnnotations()[consts.RebootApprovedAtNodeAnnotation()])
+	return err != nil || now.Sub(approvedAt) > timeout
+}
+
+// ApproveRebootPatch returns a JSON merge patch that approves rebooting the node in its current boot.
+func ApproveRebootPatch(node *corev1.Node) ([]byte, error) {
+	return annotationsPatch(map[string]interface{}{
+		consts.RebootApprovedNodeAnnotation():   node.Status.NodeInfo.BootID,
+		consts.RebootApprovedAtNodeAnnotation(): time.Now().UTC().Format(time.RFC3339),
+	}, nil)
+}
+
+// CordonPatch returns a JSON merge patch that cordons the node and marks it as cordoned by the operator.
+// Nodes that are already unschedulable are left alone so they aren't uncordoned after the reboot.
+func CordonPatch(node *corev1.Node) ([]byte, error) {
+	if node.Spec.Unschedulable {
+		return nil, errors.ErrSkipUpdate
+	}
+	return annotationsPatch(map[string]interface{}{
+		consts.RebootCordonedNodeAnnotation(): "true",
+	}, map[string]interface{}{"unschedulable": true})
+}
+
+// FinishRebootPatch returns a JSON merge patch that removes the reboot annotations set by the leader and the boot-time
+// changes from the node and uncordons it if the operator cordoned it.
+func FinishRebootPatch(node *corev1.Node) ([]byte, error) {
+	var spec map[string]interface{}
+	if _, ok := node.GetAnnotations()[consts.RebootCordonedNodeAnnotation()]; ok {
+		spec = map[string]interface{}{"unschedulable": nil}
+	}
+	return annotationsPatch(map[string]interface{}{
+		consts.RebootRequiredNodeAnnotation():   nil,
+		consts.RebootApprovedNodeAnnotation():   nil,
+		consts.RebootApprovedAtNodeAnnotation(): nil,
+		consts.RebootCordonedNodeAnnotation():   nil,
+	}, spec)
+}
+
+// desiredAnnotationsPatch returns a JSON merge patch that sets the given annotations of the node to the desired values
+// and removes the ones that aren't desired. It returns ErrSkipUpdate if they're already up to date.
+func desiredAnnotationsPatch(node *corev1.Node, desired map[string]string, keys ...string) ([]byte, error) {
+	annotations := map[string]interface{}{}
+	for _, key := range keys {
+		value, ok := node.GetAnnotations()[key]
+		desiredValue, desiredOk := desired[key]
+		switch {
+		case desiredOk && (!ok || value != desiredValue):
+			annotations[key] = desiredValue
+		case !desiredOk && ok:
+			annotations[key] = nil
+		}
+	}
+	if len(annotations) == 0 {
+		return nil, errors.ErrSkipUpdate
+	}
+	return annotationsPatch(annotations, nil)
+}
+
+func annotationsPatch(annotations, spec map[string]interface{}) ([]byte, error) {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	}
+	if spec != nil {
+		patch["spec"] = spec
+	}
+	return json.Marshal(patch)
+}
@@ -0,0 +1,344 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+const bootTimeChangesBaseConfig = `hostname: node
+ssh_authorized_keys:
+- github:annismckenzie
+k3os:
+  modules:
+  - kvm
+  labels:
+    region: shelf-1
+  taints:
+  - key1=value1:NoSchedule
+`
+
+func TestBootTimeChanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldData     string
+		newData     string
+		wantChanges []string
+		wantErr     bool
+	}{
+		{
+			name:    "identical config files",
+			oldData: bootTimeChangesBaseConfig,
+			newData: bootTimeChangesBaseConfig,
+		},
+		{
+			name:    "only labels and taints changed",
+			oldData: bootTimeChangesBaseConfig,
+			newData: "hostname: node\nssh_authorized_keys:\n- github:annismckenzie\nk3os:\n  modules:\n  - kvm\n  labels:\n    region: shelf-2\n",
+		},
+		{
+			name:    "only the k3os_config_operator section changed",
+			oldData: bootTimeChangesBaseConfig,
+			newData: bootTimeChangesBaseConfig + "k3os_config_operator:\n  annotations:\n    example.com/rack: \"2\"\n  roles:\n  - worker\n  unschedulable: true\n  provider_id: k3os://home/node\n",
+		},
+		{
+			name:        "boot-time fields changed",
+			oldData:     bootTimeChangesBaseConfig,
+			newData:     "hostname: other\nboot_cmd:\n- echo hi\nk3os:\n  modules:\n  - kvm\n  - nvme\n  k3s_args:\n  - server\n  labels:\n    region: shelf-1\n",
+			wantChanges: []string{"boot_cmd", "hostname", "k3os.k3s_args", "k3os.modules", "ssh_authorized_keys"},
+		},
+		{
+			name:        "k3os section added",
+			oldData:     "hostname: node\n",
+			newData:     "hostname: node\nk3os:\n  labels:\n    region: shelf-1\n  sysctl:\n    kernel.printk: 4 4 1 7\n",
+			wantChanges: []string{"k3os.sysctl"},
+		},
+		{
+			name:        "empty config file on disk",
+			newData:     bootTimeChangesBaseConfig,
+			wantChanges: []string{"hostname", "k3os.modules", "ssh_authorized_keys"},
+		},
+		{
+			name:    "corrupt config file on disk",
+			oldData: "k3os: [",
+			newData: bootTimeChangesBaseConfig,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := BootTimeChanges([]byte(tt.oldData), []byte(tt.newData))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BootTimeChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("BootTimeChanges() = %v, want %v", changes, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func rebootNode(bootID string, annotations map[string]string, ready corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node", Annotations: annotations},
+		Status: corev1.NodeStatus{
+			NodeInfo:   corev1.NodeSystemInfo{BootID: bootID},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+		},
+	}
+}
+
+func applyPatch(t *testing.T, node *corev1.Node, patch []byte) *corev1.Node {
+	t.Helper()
+	original, err := json.Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, patch, corev1.Node{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &corev1.Node{}
+	if err = json.Unmarshal(patched, result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestRebootLifecycle(t *testing.T) {
+	node := rebootNode("boot-1", nil, corev1.ConditionTrue)
+
+	patch, err := RebootRequiredPatch(node, []string{"k3os.modules", "hostname"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node = applyPatch(t, node, patch)
+	if patch, err = RebootRequiredPatch(node, []string{"hostname", "run_cmd"}, true); err != nil {
+		t.Fatal(err)
+	}
+	node = applyPatch(t, node, patch)
+	if _, err = RebootRequiredPatch(node, nil, true); !errors.Is(err, errors.ErrSkipUpdate) {
+		t.Fatalf("RebootRequiredPatch() without new changes error = %v, want %v", err, errors.ErrSkipUpdate)
+	}
+	if !Rebootable(node) {
+		t.Fatal("expected node to be rebootable")
+	}
+	if changes, want := GetBootTimeChanges(node), []string{"hostname", "k3os.modules", "run_cmd"}; !reflect.DeepEqual(changes, want) {
+		t.Fatalf("GetBootTimeChanges() = %v, want %v", changes, want)
+	}
+	if RebootApproved(node) || RebootCompleted(node) {
+		t.Fatal("expected reboot to be neither approved nor completed")
+	}
+
+	if patch, err = CordonPatch(node); err != nil {
+		t.Fatal(err)
+	}
+	node = applyPatch(t, node, patch)
+	if !node.Spec.Unschedulable {
+		t.Fatal("expected node to be cordoned")
+	}
+	if _, err = CordonPatch(node); !errors.Is(err, errors.ErrSkipUpdate) {
+		t.Fatalf("CordonPatch() on a cordoned node error = %v, want %v", err, errors.ErrSkipUpdate)
+	}
+
+	if patch, err = ApproveRebootPatch(node); err != nil {
+		t.Fatal(err)
+	}
+	node = applyPatch(t, node, patch)
+	if !RebootApproved(node) || RebootCompleted(node) {
+		t.Fatal("expected reboot to be approved but not completed")
+	}
+	if RebootApprovalExpired(node, time.Minute, time.Now()) || !RebootApprovalExpired(node, time.Minute, time.Now().Add(2*time.Minute)) {
+		t.Fatal("expected reboot approval to expire after the timeout")
+	}
+
+	node.Status.NodeInfo.BootID = "boot-2" // rebooting
+	node.Status.Conditions[0].Status = corev1.ConditionUnknown
+	if RebootApproved(node) || RebootCompleted(node) {
+		t.Fatal("expected reboot of NotReady node to be neither approved nor completed")
+	}
+	node.Status.Conditions[0].Status = corev1.ConditionTrue
+	if !RebootCompleted(node) {
+		t.Fatal("expected reboot to be completed")
+	}
+	if changes := GetBootTimeChanges(node); changes != nil {
+		t.Fatalf("GetBootTimeChanges() after the reboot = %v, want none", changes)
+	}
+
+	if patch, err = FinishRebootPatch(node); err != nil {
+		t.Fatal(err)
+	}
+	node = applyPatch(t, node, patch)
+	if node.Spec.Unschedulable {
+		t.Error("expected node to be uncordoned")
+	}
+	if want := map[string]string{consts.RebootableNodeAnnotation(): "true"}; !reflect.DeepEqual(node.GetAnnotations(), want) {
+		t.Errorf("expected all reboot annotations but the rebootable marker to be removed, got %v", node.GetAnnotations())
+	}
+}
+
+func TestRebootRequiredPatch(t *testing.T) {
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		changes         []string
+		rebootable      bool
+		wantAnnotations map[string]string
+		wantErr         error
+	}{
+		{
+			name:       "changes of a rebootable node",
+			changes:    []string{"hostname"},
+			rebootable: true,
+			wantAnnotations: map[string]string{
+				consts.RebootRequiredNodeAnnotation(): `{"bootID":"boot-2","changes":["hostname"]}`,
+				consts.RebootableNodeAnnotation():     "true",
+			},
+		},
+		{
+			name:    "changes of a node that can't be rebooted",
+			changes: []string{"hostname"},
+			annotations: map[string]string{
+				consts.RebootableNodeAnnotation(): "true",
+			},
+			wantAnnotations: map[string]string{
+				consts.RebootRequiredNodeAnnotation(): `{"bootID":"boot-2","changes":["hostname"]}`,
+			},
+		},
+		{
+			name:    "changes recorded before the node was last booted are dropped",
+			changes: []string{"run_cmd"},
+			annotations: map[string]string{
+				consts.RebootRequiredNodeAnnotation(): `{"bootID":"boot-1","changes":["hostname"]}`,
+			},
+			wantAnnotations: map[string]string{
+				consts.RebootRequiredNodeAnnotation(): `{"bootID":"boot-2","changes":["run_cmd"]}`,
+			},
+		},
+		{
+			name: "stale changes are removed",
+			annotations: map[string]string{
+				consts.RebootRequiredNodeAnnotation(): `{"bootID":"boot-1","changes":["hostname"]}`,
+			},
+			wantAnnotations: map[string]string{},
+		},
+		{
+			name: "corrupt changes are removed",
+			annotations: map[string]string{
+				consts.RebootRequiredNodeAnnotation(): "hostname,run_cmd",
+			},
+			wantAnnotations: map[string]string{},
+		},
+		{
+			name:       "up to date",
+			rebootable: true,
+			annotations: map[string]string{
+				consts.RebootRequiredNodeAnnotation(): `{"bootID":"boot-2","changes":["hostname"]}`,
+				consts.RebootableNodeAnnotation():     "true",
+			},
+			wantErr: errors.ErrSkipUpdate,
+		},
+		{
+			name:    "nothing to record",
+			wantErr: errors.ErrSkipUpdate,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			node := rebootNode("boot-2", tt.annotations, corev1.ConditionTrue)
+			patch, err := RebootRequiredPatch(node, tt.changes, tt.rebootable)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RebootRequiredPatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			annotations := applyPatch(t, node, patch).GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			if !reflect.DeepEqual(annotations, tt.wantAnnotations) {
+				t.Errorf("RebootRequiredPatch() annotations = %v, want %v", annotations, tt.wantAnnotations)
+			}
+		})
+	}
+}
+
+func TestDisableRebootsPatch(t *testing.T) {
+	node := rebootNode("boot-1", map[string]string{
+		consts.RebootRequiredNodeAnnotation():   `{"bootID":"boot-1","changes":["hostname"]}`,
+		consts.RebootableNodeAnnotation():       "true",
+		consts.RebootApprovedNodeAnnotation():   "boot-1",
+		consts.RebootApprovedAtNodeAnnotation(): "2021-06-01T12:00:00Z",
+		consts.RebootCordonedNodeAnnotation():   "true",
+	}, corev1.ConditionTrue)
+	node.Spec.Unschedulable = true
+
+	patch, err := DisableRebootsPatch(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node = applyPatch(t, node, patch)
+	if node.Spec.Unschedulable {
+		t.Error("expected node to be uncordoned")
+	}
+	if len(node.GetAnnotations()) != 0 {
+		t.Errorf("expected all reboot annotations to be removed, got %v", node.GetAnnotations())
+	}
+	if _, err = DisableRebootsPatch(node); !errors.Is(err, errors.ErrSkipUpdate) {
+		t.Fatalf("DisableRebootsPatch() without reboot annotations error = %v, want %v", err, errors.ErrSkipUpdate)
+	}
+}
+
+func TestRebootApprovalExpired_invalidApprovalTime(t *testing.T) {
+	node := rebootNode("boot-1", map[string]string{consts.RebootApprovedNodeAnnotation(): "boot-1"}, corev1.ConditionTrue)
+	if !RebootApprovalExpired(node, time.Hour, time.Now()) {
+		t.Error("expected reboot approval without an approval time to be expired")
+	}
+}
+
+func TestFinishRebootPatch_keepsNodesCordonedByOthers(t *testing.T) {
+	node := rebootNode("boot-2", map[string]string{
+		consts.RebootRequiredNodeAnnotation(): `{"bootID":"boot-1","changes":["hostname"]}`,
+		consts.RebootApprovedNodeAnnotation(): "boot-1",
+	}, corev1.ConditionTrue)
+	node.Spec.Unschedulable = true
+
+	patch, err := FinishRebootPatch(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node = applyPatch(t, node, patch)
+	if !node.Spec.Unschedulable {
+		t.Error("expected node that wasn't cordoned by the operator to stay cordoned")
+	}
+}
+
+func TestNewCommandRebooter(t *testing.T) {
+	if _, err := NewCommandRebooter(nil); err == nil {
+		t.Error("NewCommandRebooter() with an empty command should fail")
+	}
+	rebooter, err := NewCommandRebooter([]string{"true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rebooter.Reboot(context.Background()); err != nil {
+		t.Errorf("Reboot() error = %v", err)
+	}
+	if rebooter, err = NewCommandRebooter([]string{"false"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = rebooter.Reboot(context.Background()); err == nil {
+		t.Error("Reboot() with a failing command should fail")
+	}
+}
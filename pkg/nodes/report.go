@@ -0,0 +1,69 @@
+package nodes
+
+import (
+	"encoding/json"
+	"fmt"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// SyncReport contains what the operator running on a node reports about syncing that node.
+// It is stored as JSON in the sync report annotation of the node and aggregated by the leader.
+type SyncReport struct {
+	// K3OSConfig is the name of the K3OSConfig that was synced.
+	K3OSConfig string `json:"k3osConfig"`
+
+	configv1alpha1.K3OSConfigNodeStatus `json:",inline"`
+}
+
+// GetSyncReport returns the sync report stored on the node. It returns nil if the node has no sync report.
+func GetSyncReport(node *corev1.Node) (*SyncReport, error) {
+	if node == nil {
+		return nil, fmt.Errorf("node: %w", errors.ErrNilObjectPassed)
+	}
+	value, ok := node.GetAnnotations()[consts.SyncReportNodeAnnotation()]
+	if !ok || value == "" {
+		return nil, nil
+	}
+	report := &SyncReport{}
+	if err := json.Unmarshal([]byte(value), report); err != nil {
+		return nil, fmt.Errorf("failed to parse sync report of node %q: %w", node.GetName(), err)
+	}
+	return report, nil
+}
+
+// SyncReportPatch returns a JSON merge patch that stores the sync report on a node.
+func SyncReportPatch(report *SyncReport) ([]byte, error) {
+	value, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				consts.SyncReportNodeAnnotation(): string(value),
+			},
+		},
+	}
+	return json.Marshal(patch)
+}
+
+// EqualIgnoringTimes returns whether both sync reports are equal when ignoring their timestamps.
+func (r *SyncReport) EqualIgnoringTimes(other *SyncReport) bool {
+	if r == nil || other == nil {
+		return r == other
+	}
+	a, b := r.withoutTimes(), other.withoutTimes()
+	return equality.Semantic.DeepEqual(a, b)
+}
+
+func (r *SyncReport) withoutTimes() *SyncReport {
+	report := &SyncReport{K3OSConfig: r.K3OSConfig, K3OSConfigNodeStatus: *r.K3OSConfigNodeStatus.DeepCopy()}
+	report.LastSyncTime = nil
+	report.LastSuccessfulSyncTime = nil
+	return report
+}
@@ -0,0 +1,112 @@
+package nodes
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testSyncReport(syncTime time.Time) *SyncReport {
+	lastSyncTime := metav1.NewTime(syncTime)
+	return &SyncReport{
+		K3OSConfig: "k3osconfig",
+		K3OSConfigNodeStatus: configv1alpha1.K3OSConfigNodeStatus{
+			NodeName:           "node",
+			ObservedGeneration: 2,
+			ConfigHash:         "hash",
+			UpdatedLabels:      map[string]string{"label": "value"},
+			ConfigFileUpdate:   configv1alpha1.ConfigFileSkipped,
+			LastSyncTime:       &lastSyncTime,
+		},
+	}
+}
+
+func TestGetSyncReport(t *testing.T) {
+	syncTime := time.Date(2021, 7, 28, 12, 0, 0, 0, time.UTC)
+	report := testSyncReport(syncTime)
+	patch, err := SyncReportPatch(report)
+	if err != nil {
+		t.Fatalf("SyncReportPatch() error = %v", err)
+	}
+	var patched corev1.Node
+	if err = json.Unmarshal(patch, &patched); err != nil {
+		t.Fatalf("failed to unmarshal patch: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		node       *corev1.Node
+		wantReport *SyncReport
+		wantErr    bool
+	}{
+		{
+			name:    "passing a nil Node object",
+			wantErr: true,
+		},
+		{
+			name: "Node without sync report",
+			node: defaultNode(),
+		},
+		{
+			name: "Node with corrupt sync report",
+			node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{consts.SyncReportNodeAnnotation(): "{"},
+			}},
+			wantErr: true,
+		},
+		{
+			name:       "Node with sync report that was stored by applying the patch",
+			node:       &patched,
+			wantReport: report,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetSyncReport(tt.node)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSyncReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.node == nil && !errors.Is(err, errors.ErrNilObjectPassed) {
+				t.Errorf("GetSyncReport() error = %v, want %v", err, errors.ErrNilObjectPassed)
+			}
+			if !got.EqualIgnoringTimes(tt.wantReport) {
+				t.Errorf("GetSyncReport() = %+v, want %+v", got, tt.wantReport)
+			}
+			if got != nil && !got.LastSyncTime.Equal(tt.wantReport.LastSyncTime) {
+				t.Errorf("GetSyncReport() last sync time = %v, want %v", got.LastSyncTime, tt.wantReport.LastSyncTime)
+			}
+		})
+	}
+}
+
+func TestSyncReport_EqualIgnoringTimes(t *testing.T) {
+	now := time.Now()
+	changed := testSyncReport(now)
+	changed.LastError = "failed"
+
+	tests := []struct {
+		name  string
+		a, b  *SyncReport
+		equal bool
+	}{
+		{name: "both nil", equal: true},
+		{name: "one nil", a: testSyncReport(now)},
+		{name: "only times differ", a: testSyncReport(now), b: testSyncReport(now.Add(time.Hour)), equal: true},
+		{name: "error differs", a: testSyncReport(now), b: changed},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.EqualIgnoringTimes(tt.b); got != tt.equal {
+				t.Errorf("SyncReport.EqualIgnoringTimes() = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
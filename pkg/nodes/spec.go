@@ -0,0 +1,143 @@
+package nodes
+
+import (
+	"fmt"
+	"strconv"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Node spec fields the NodeSpecReconciler manages.
+const (
+	SpecFieldUnschedulable = "unschedulable"
+	SpecFieldProviderID    = "providerID"
+)
+
+// NodeSpecReconciler allows reconciling select fields of the node spec.
+type NodeSpecReconciler interface {
+	Reconcile(*corev1.Node, *configv1alpha1.K3OSConfigFileSectionOperator) error
+	UpdatedFields() map[string]string
+	Conflicts() []string
+	OwnershipError() error
+}
+
+// specReconciler implements the NodeSpecReconciler interface.
+var _ NodeSpecReconciler = (*specReconciler)(nil)
+
+type specReconciler struct {
+	policy        configv1alpha1.UnschedulablePolicy
+	updatedFields map[string]string
+	conflicts     []string
+	ownershipErr  error
+}
+
+// NewNodeSpecReconciler returns an initialized node spec reconciler that overrides the unschedulable field
+// of nodes it didn't change itself according to the policy.
+func NewNodeSpecReconciler(policy configv1alpha1.UnschedulablePolicy) NodeSpecReconciler {
+	return &specReconciler{
+		policy:        policy,
+		updatedFields: map[string]string{},
+	}
+}
+
+// Reconcile updates the unschedulable and providerID fields of a node's spec according to the provided section
+// of the node config. The operator only takes ownership of the fields it changed: it uncordons nodes that were
+// cordoned by someone else only if the policy is Enforce and never touches nodes the leader cordoned for a reboot.
+// A provider ID can't be changed once it is set. Fields that can't be changed are reported by Conflicts.
+// A specFieldsAdded annotation in the format of earlier versions is migrated and one that can't be read is repaired
+// (see OwnershipError).
+// It will return errors.ErrSkipUpdate if no updates to the node are required.
+// The provided Node object is updated and the caller must persist the updated
+// Node with the Kubernetes API server on their own.
+func (r *specReconciler) Reconcile(node *corev1.Node, section *configv1alpha1.K3OSConfigFileSectionOperator) error {
+	if node == nil {
+		return fmt.Errorf("node: %w", errors.ErrNilObjectPassed)
+	}
+	if section == nil {
+		section = &configv1alpha1.K3OSConfigFileSectionOperator{}
+	}
+	addedSpecFieldsMap, rewrite, err := addedSpecFields(node)
+	r.ownershipErr = err
+	updateUnschedulable := r.reconcileUnschedulable(node, section.Unschedulable, addedSpecFieldsMap)
+	updateProviderID := r.reconcileProviderID(node, section.ProviderID, addedSpecFieldsMap)
+
+	if updateUnschedulable || updateProviderID || rewrite {
+		updateAddedSpecFields(node, addedSpecFieldsMap)
+		return nil
+	}
+	return errors.ErrSkipUpdate
+}
+
+func (r *specReconciler) reconcileUnschedulable(node *corev1.Node, unschedulable *bool, addedSpecFieldsMap map[string]struct{}) bool {
+	_, owned := addedSpecFieldsMap[SpecFieldUnschedulable]
+	if _, ok := node.GetAnnotations()[consts.RebootCordonedNodeAnnotation()]; ok { // cordoned for a reboot, leave it alone
+		return false
+	}
+	switch {
+	case unschedulable == nil && !owned:
+		return false
+	case unschedulable == nil || (!*unschedulable && node.Spec.Unschedulable && owned): // uncordon the node we cordoned
+		delete(addedSpecFieldsMap, SpecFieldUnschedulable)
+		r.setUnschedulable(node, false)
+		return true
+	case *unschedulable == node.Spec.Unschedulable:
+		if !*unschedulable && owned { // someone else uncordoned the node we cordoned
+			delete(addedSpecFieldsMap, SpecFieldUnschedulable)
+			return true
+		}
+		return false
+	case *unschedulable:
+		addedSpecFieldsMap[SpecFieldUnschedulable] = struct{}{}
+		r.setUnschedulable(node, true)
+		return true
+	case r.policy == configv1alpha1.UnschedulablePolicyEnforce:
+		r.setUnschedulable(node, false)
+		return true
+	default:
+		r.conflicts = append(r.conflicts, "node was cordoned by someone else and the unschedulable policy is "+string(configv1alpha1.UnschedulablePolicyRespect))
+		return false
+	}
+}
+
+func (r *specReconciler) setUnschedulable(node *corev1.Node, unschedulable bool) {
+	if node.Spec.Unschedulable != unschedulable {
+		node.Spec.Unschedulable = unschedulable
+		r.updatedFields[SpecFieldUnschedulable] = strconv.FormatBool(unschedulable)
+	}
+}
+
+func (r *specReconciler) reconcileProviderID(node *corev1.Node, providerID string, addedSpecFieldsMap map[string]struct{}) bool {
+	_, owned := addedSpecFieldsMap[SpecFieldProviderID]
+	switch {
+	case providerID == "": // a provider ID can't be removed, only stop tracking it
+		delete(addedSpecFieldsMap, SpecFieldProviderID)
+		return owned
+	case node.Spec.ProviderID == "":
+		addedSpecFieldsMap[SpecFieldProviderID] = struct{}{}
+		node.Spec.ProviderID = providerID
+		r.updatedFields[SpecFieldProviderID] = providerID
+		return true
+	case node.Spec.ProviderID != providerID:
+		r.conflicts = append(r.conflicts, fmt.Sprintf("providerID is already set to %q and can't be changed", node.Spec.ProviderID))
+	}
+	return false
+}
+
+// UpdatedFields returns the updated spec fields and their new values after Reconcile was called.
+func (r *specReconciler) UpdatedFields() map[string]string {
+	return r.updatedFields
+}
+
+// Conflicts returns why spec fields couldn't be updated after Reconcile was called.
+func (r *specReconciler) Conflicts() []string {
+	return r.conflicts
+}
+
+// OwnershipError returns the *OwnershipError describing why the specFieldsAdded annotation couldn't be read completely
+// after Reconcile was called. Spec fields that couldn't be read are treated as set by someone else.
+func (r *specReconciler) OwnershipError() error {
+	return r.ownershipErr
+}
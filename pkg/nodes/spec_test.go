@@ -0,0 +1,141 @@
+package nodes
+
+import (
+	"reflect"
+	"testing"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func specNode(unschedulable bool, providerID string, annotations map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node", Annotations: annotations},
+		Spec:       corev1.NodeSpec{Unschedulable: unschedulable, ProviderID: providerID},
+	}
+}
+
+func Test_specReconciler_Reconcile(t *testing.T) {
+	yes, no := true, false
+	owned := func(fields string) map[string]string {
+		return map[string]string{consts.AddedSpecFieldsNodeAnnotation(): `{"version":1,"specFields":[` + fields + `]}`}
+	}
+
+	tests := []struct {
+		name              string
+		node              *corev1.Node
+		section           *configv1alpha1.K3OSConfigFileSectionOperator
+		policy            configv1alpha1.UnschedulablePolicy
+		wantErr           error
+		wantNode          *corev1.Node
+		wantUpdatedFields map[string]string
+		wantConflicts     []string
+	}{
+		{
+			name:    "passing a nil Node object",
+			wantErr: errors.ErrNilObjectPassed,
+		},
+		{
+			name:              "nothing configured",
+			node:              specNode(false, "", nil),
+			wantErr:           errors.ErrSkipUpdate,
+			wantNode:          specNode(false, "", nil),
+			wantUpdatedFields: map[string]string{},
+		},
+		{
+			name:              "cordon the node and set the provider ID",
+			node:              specNode(false, "", nil),
+			section:           &configv1alpha1.K3OSConfigFileSectionOperator{Unschedulable: &yes, ProviderID: "k3os://node"},
+			wantNode:          specNode(true, "k3os://node", owned(`"providerID","unschedulable"`)),
+			wantUpdatedFields: map[string]string{SpecFieldUnschedulable: "true", SpecFieldProviderID: "k3os://node"},
+		},
+		{
+			name:              "node that was cordoned by someone else isn't owned",
+			node:              specNode(true, "", nil),
+			section:           &configv1alpha1.K3OSConfigFileSectionOperator{Unschedulable: &yes},
+			wantErr:           errors.ErrSkipUpdate,
+			wantNode:          specNode(true, "", nil),
+			wantUpdatedFields: map[string]string{},
+		},
+		{
+			name:              "uncordon the node we cordoned",
+			node:              specNode(true, "", owned(`"unschedulable"`)),
+			section:           &configv1alpha1.K3OSConfigFileSectionOperator{Unschedulable: &no},
+			wantNode:          specNode(false, "", map[string]string{}),
+			wantUpdatedFields: map[string]string{SpecFieldUnschedulable: "false"},
+		},
+		{
+			name:              "uncordon the node we cordoned when unschedulable is unset",
+			node:              specNode(true, "", owned(`"unschedulable"`)),
+			wantNode:          specNode(false, "", map[string]string{}),
+			wantUpdatedFields: map[string]string{SpecFieldUnschedulable: "false"},
+		},
+		{
+			name:              "respect nodes cordoned by someone else",
+			node:              specNode(true, "", nil),
+			section:           &configv1alpha1.K3OSConfigFileSectionOperator{Unschedulable: &no},
+			policy:            configv1alpha1.UnschedulablePolicyRespect,
+			wantErr:           errors.ErrSkipUpdate,
+			wantNode:          specNode(true, "", nil),
+			wantUpdatedFields: map[string]string{},
+			wantConflicts:     []string{"node was cordoned by someone else and the unschedulable policy is Respect"},
+		},
+		{
+			name:              "enforce uncordoning nodes cordoned by someone else",
+			node:              specNode(true, "", nil),
+			section:           &configv1alpha1.K3OSConfigFileSectionOperator{Unschedulable: &no},
+			policy:            configv1alpha1.UnschedulablePolicyEnforce,
+			wantNode:          specNode(false, "", nil),
+			wantUpdatedFields: map[string]string{SpecFieldUnschedulable: "false"},
+		},
+		{
+			name:              "nodes cordoned for a reboot are left alone",
+			node:              specNode(true, "", map[string]string{consts.RebootCordonedNodeAnnotation(): "true"}),
+			section:           &configv1alpha1.K3OSConfigFileSectionOperator{Unschedulable: &no},
+			policy:            configv1alpha1.UnschedulablePolicyEnforce,
+			wantErr:           errors.ErrSkipUpdate,
+			wantNode:          specNode(true, "", map[string]string{consts.RebootCordonedNodeAnnotation(): "true"}),
+			wantUpdatedFields: map[string]string{},
+		},
+		{
+			name:              "an existing provider ID can't be changed",
+			node:              specNode(false, "aws:///eu-central-1a/i-0123", nil),
+			section:           &configv1alpha1.K3OSConfigFileSectionOperator{ProviderID: "k3os://node"},
+			wantErr:           errors.ErrSkipUpdate,
+			wantNode:          specNode(false, "aws:///eu-central-1a/i-0123", nil),
+			wantUpdatedFields: map[string]string{},
+			wantConflicts:     []string{`providerID is already set to "aws:///eu-central-1a/i-0123" and can't be changed`},
+		},
+		{
+			name:              "unsetting the provider ID only drops its ownership",
+			node:              specNode(false, "k3os://node", owned(`"providerID"`)),
+			wantNode:          specNode(false, "k3os://node", map[string]string{}),
+			wantUpdatedFields: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewNodeSpecReconciler(tt.policy)
+			err := r.Reconcile(tt.node, tt.section)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("specReconciler.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.node == nil {
+				return
+			}
+			if !reflect.DeepEqual(tt.node, tt.wantNode) {
+				t.Errorf("specReconciler.Reconcile() node = %v, want %v", tt.node, tt.wantNode)
+			}
+			if !reflect.DeepEqual(r.UpdatedFields(), tt.wantUpdatedFields) {
+				t.Errorf("specReconciler.UpdatedFields() = %v, want %v", r.UpdatedFields(), tt.wantUpdatedFields)
+			}
+			if !reflect.DeepEqual(r.Conflicts(), tt.wantConflicts) {
+				t.Errorf("specReconciler.Conflicts() = %v, want %v", r.Conflicts(), tt.wantConflicts)
+			}
+		})
+	}
+}
@@ -2,11 +2,10 @@ package nodes
 
 import (
 	"fmt"
-	"strings"
+	"time"
 
-	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
 	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
-	internalConsts "github.com/annismckenzie/k3os-config-operator/pkg/internal/consts"
 	"github.com/annismckenzie/k3os-config-operator/pkg/util/taints"
 	corev1 "k8s.io/api/core/v1"
 )
@@ -15,21 +14,39 @@ import (
 type Tainter interface {
 	Reconcile(*corev1.Node, []string) error
 	UpdatedTaints() map[string]string // stringified taint => added/removed/changed
+	SkippedTaints() []string
+	DelayedTaints() []string
+	RequeueAfter() time.Duration
+	OwnershipError() error
 }
 
 // tainter implements the Tainter interface.
 var _ Tainter = (*tainter)(nil)
 
 type tainter struct {
+	policy        *configv1alpha1.TaintPolicy
+	now           func() time.Time
 	updatedTaints map[string]string
+	skippedTaints []string
+	delayedTaints []string
+	requeueAfter  time.Duration
+	ownershipErr  error
 }
 
-// NewTainter returns an initialized taint reconciler.
-func NewTainter() Tainter {
-	return &tainter{}
+// NewTainter returns an initialized taint reconciler that applies the safeguards of the policy to taints with the
+// NoExecute effect (they are added right away if it is nil).
+func NewTainter(policy *configv1alpha1.TaintPolicy) Tainter {
+	return &tainter{
+		policy: policy,
+		now:    time.Now,
+	}
 }
 
 // Reconcile updates a node's taints according to the provided node taints.
+// Taints with the NoExecute effect that the policy forbids are skipped as if they weren't provided and the ones
+// that wait for their grace period or the approval of the leader are added with the NoSchedule effect instead.
+// A taintsAdded annotation in the format of earlier versions is migrated and one that can't be read is repaired
+// (see OwnershipError).
 // It will return errors.ErrSkipUpdate if no updates to the node are required.
 // The provided Node object is updated and the caller must persist the updated
 // Node with the Kubernetes API server on their own.
@@ -42,8 +59,10 @@ func (t *tainter) Reconcile(node *corev1.Node, configNodeTaints []string) error
 	if err != nil {
 		return err
 	}
+	taintsToAdd, safeguardsUpdated := t.applyPolicy(node, taintsToAdd)
 
-	addedTaintsMap := getAddedTaints(node)
+	addedTaintsMap, rewriteAddedTaints, err := getAddedTaints(node)
+	t.ownershipErr = err
 	for existingTaint := range addedTaintsMap {
 		var skipCheckingTaintsToAdd bool
 		for _, taintToRemove := range taintsToRemove {
@@ -111,6 +130,12 @@ outer:
 	taintsToAdd = tmp
 
 	if len(taintsToAdd) == 0 && len(taintsToRemove) == 0 {
+		if rewriteAddedTaints {
+			updateAddedTaints(node, addedTaintsMap)
+		}
+		if rewriteAddedTaints || safeguardsUpdated {
+			return nil
+		}
 		return errors.ErrSkipUpdate
 	}
 
@@ -132,35 +157,25 @@ func (t *tainter) UpdatedTaints() map[string]string {
 	return t.updatedTaints
 }
 
-func getAddedTaints(node *corev1.Node) map[corev1.Taint]struct{} {
-	if node == nil {
-		return nil
-	}
+// SkippedTaints returns the sorted taints with the NoExecute effect that the policy forbids after Reconcile was called.
+func (t *tainter) SkippedTaints() []string {
+	return t.skippedTaints
+}
 
-	addedTaintsMap := map[corev1.Taint]struct{}{}
-	if addedTaintsAnnotation := node.GetAnnotations()[consts.AddedTaintsNodeAnnotation()]; addedTaintsAnnotation != "" {
-		for _, addedTaint := range strings.Split(addedTaintsAnnotation, internalConsts.NodeAnnotationValueSeparator) {
-			parsed, _, err := taints.ParseTaints([]string{addedTaint})
-			if len(parsed) != 1 || err != nil {
-				panic(fmt.Sprintf("could not parse stored taint %q: %v", addedTaint, err))
-			}
-			addedTaintsMap[parsed[0]] = struct{}{}
-		}
-	}
-	return addedTaintsMap
+// DelayedTaints returns the sorted taints with the NoExecute effect that were added with the NoSchedule effect
+// instead after Reconcile was called.
+func (t *tainter) DelayedTaints() []string {
+	return t.delayedTaints
 }
 
-func updateAddedTaints(node *corev1.Node, addedTaintsMap map[corev1.Taint]struct{}) {
-	addedTaints := make([]string, len(addedTaintsMap))
-	var i int
-	for addedTaint := range addedTaintsMap {
-		addedTaints[i] = addedTaint.ToString()
-		i++
-	}
-	annotations := node.GetAnnotations()
-	if annotations == nil {
-		annotations = map[string]string{}
-	}
-	annotations[consts.AddedTaintsNodeAnnotation()] = strings.Join(addedTaints, internalConsts.NodeAnnotationValueSeparator)
-	node.Annotations = annotations
+// OwnershipError returns the *OwnershipError describing why the taintsAdded annotation couldn't be read completely
+// after Reconcile was called. Taints that couldn't be read aren't removed from the node anymore.
+func (t *tainter) OwnershipError() error {
+	return t.ownershipErr
+}
+
+// RequeueAfter returns the duration after which the grace period of the next delayed taint elapses after Reconcile
+// was called. It is zero if no taint waits for its grace period.
+func (t *tainter) RequeueAfter() time.Duration {
+	return t.requeueAfter
 }
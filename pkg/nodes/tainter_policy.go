@@ -0,0 +1,177 @@
+package nodes
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// applyPolicy skips the taints with the NoExecute effect that the policy forbids and replaces the ones that wait for
+// their grace period or the approval of the leader with a taint with the same key and value and the NoSchedule effect.
+// Taints with the NoExecute effect that the node already carries are kept. It returns the taints to add and whether
+// the annotations that keep track of the delayed taints were updated.
+func (t *tainter) applyPolicy(node *corev1.Node, taintsToAdd []corev1.Taint) ([]corev1.Taint, bool) {
+	now, gracePeriod := t.now(), t.policy.GracePeriod()
+	graceStarted := noExecuteGrace(node)
+	updatedGraceStarted := map[string]time.Time{}
+	approved := NoExecuteApproved(node) && NoExecuteRequested(node)
+	var requested bool
+
+	filtered := make([]corev1.Taint, 0, len(taintsToAdd))
+	var standIns []corev1.Taint
+	for _, taint := range taintsToAdd {
+		taint := taint
+		switch {
+		case taint.Effect != corev1.TaintEffectNoExecute:
+			filtered = append(filtered, taint)
+			continue
+		case !t.policy.AllowsNoExecute(taint.Key):
+			t.skippedTaints = append(t.skippedTaints, taint.ToString())
+			continue
+		case hasTaint(node.Spec.Taints, &taint): // escalated before, changing its value doesn't evict more pods
+			filtered = append(filtered, taint)
+			continue
+		}
+
+		var delayed bool
+		if gracePeriod > 0 {
+			started, ok := graceStarted[taint.ToString()]
+			if !ok || started.After(now) {
+				started = now
+			}
+			updatedGraceStarted[taint.ToString()] = started
+			if remaining := started.Add(gracePeriod).Sub(now); remaining > 0 {
+				delayed = true
+				if t.requeueAfter == 0 || remaining < t.requeueAfter {
+					t.requeueAfter = remaining
+				}
+			}
+		}
+		if !delayed && t.policy.LimitsNoExecuteNodes() && !approved {
+			delayed, requested = true, true
+		}
+		if !delayed {
+			delete(updatedGraceStarted, taint.ToString())
+			filtered = append(filtered, taint)
+			continue
+		}
+		t.delayedTaints = append(t.delayedTaints, taint.ToString())
+		standIn := taint
+		standIn.Effect = corev1.TaintEffectNoSchedule
+		standIns = append(standIns, standIn)
+	}
+	for _, standIn := range standIns {
+		standIn := standIn
+		if !hasTaint(filtered, &standIn) {
+			filtered = append(filtered, standIn)
+		}
+	}
+	sort.Strings(t.skippedTaints)
+	sort.Strings(t.delayedTaints)
+
+	return filtered, updateNoExecuteAnnotations(node, updatedGraceStarted, requested)
+}
+
+// hasTaint returns whether the taints contain a taint with the same key and effect.
+func hasTaint(taints []corev1.Taint, taintToFind *corev1.Taint) bool {
+	for _, taint := range taints {
+		if taint.MatchTaint(taintToFind) {
+			return true
+		}
+	}
+	return false
+}
+
+// noExecuteGrace returns the times at which the grace periods of the delayed taints of the node started. An annotation
+// that can't be parsed is ignored: the grace periods start over which delays the taints further instead of evicting pods early.
+func noExecuteGrace(node *corev1.Node) map[string]time.Time {
+	graceStarted := map[string]time.Time{}
+	value, ok := node.GetAnnotations()[consts.NoExecuteGraceNodeAnnotation()]
+	if !ok {
+		return graceStarted
+	}
+	stored := map[string]string{}
+	if err := json.Unmarshal([]byte(value), &stored); err != nil {
+		return graceStarted
+	}
+	for taint, started := range stored {
+		if startedTime, err := time.Parse(time.RFC3339, started); err == nil {
+			graceStarted[taint] = startedTime
+		}
+	}
+	return graceStarted
+}
+
+// updateNoExecuteAnnotations stores the times at which the grace periods of the delayed taints started and whether
+// the node waits for the approval of the leader in the annotations of the node. It returns whether they changed.
+func updateNoExecuteAnnotations(node *corev1.Node, graceStarted map[string]time.Time, requested bool) bool {
+	desired := map[string]string{}
+	if len(graceStarted) > 0 {
+		stored := make(map[string]string, len(graceStarted))
+		for taint, started := range graceStarted {
+			stored[taint] = started.UTC().Format(time.RFC3339)
+		}
+		value, _ := json.Marshal(stored) // maps are marshaled with sorted keys
+		desired[consts.NoExecuteGraceNodeAnnotation()] = string(value)
+	}
+	if requested {
+		desired[consts.NoExecuteRequestedNodeAnnotation()] = "true"
+	}
+
+	var updated bool
+	for _, key := range []string{consts.NoExecuteGraceNodeAnnotation(), consts.NoExecuteRequestedNodeAnnotation()} {
+		current, exists := node.GetAnnotations()[key]
+		value, wanted := desired[key]
+		switch {
+		case wanted && (!exists || current != value):
+			if node.Annotations == nil {
+				node.Annotations = map[string]string{}
+			}
+			node.Annotations[key] = value
+			updated = true
+		case !wanted && exists:
+			delete(node.Annotations, key)
+			updated = true
+		}
+	}
+	return updated
+}
+
+// NoExecuteRequested returns whether the node waits for the leader to approve adding taints with the NoExecute effect.
+func NoExecuteRequested(node *corev1.Node) bool {
+	return node.GetAnnotations()[consts.NoExecuteRequestedNodeAnnotation()] == "true"
+}
+
+// NoExecuteApproved returns whether the leader approved adding taints with the NoExecute effect to the node.
+func NoExecuteApproved(node *corev1.Node) bool {
+	return node.GetAnnotations()[consts.NoExecuteApprovedNodeAnnotation()] == "true"
+}
+
+// ManagesNoExecuteTaints returns whether the operator added taints with the NoExecute effect to the node.
+func ManagesNoExecuteTaints(node *corev1.Node) bool {
+	for _, taint := range ManagedTaints(node) {
+		if strings.HasSuffix(taint, ":"+string(corev1.TaintEffectNoExecute)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApproveNoExecutePatch returns a JSON merge patch that approves adding taints with the NoExecute effect to the node.
+func ApproveNoExecutePatch(*corev1.Node) ([]byte, error) {
+	return annotationsPatch(map[string]interface{}{
+		consts.NoExecuteApprovedNodeAnnotation(): "true",
+	}, nil)
+}
+
+// ReleaseNoExecutePatch returns a JSON merge patch that removes the approval to add taints with the NoExecute effect
+// from the node.
+func ReleaseNoExecutePatch(*corev1.Node) ([]byte, error) {
+	return annotationsPatch(map[string]interface{}{
+		consts.NoExecuteApprovedNodeAnnotation(): nil,
+	}, nil)
+}
@@ -0,0 +1,204 @@
+package nodes
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_tainter_Reconcile_policy(t *testing.T) {
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	configNodeTaints := []string{"dedicated=db:NoExecute", "arm=true:NoSchedule"}
+	gracePolicy := &configv1alpha1.TaintPolicy{NoExecuteGracePeriod: &metav1.Duration{Duration: 5 * time.Minute}}
+	limit := int32(50)
+	limitPolicy := &configv1alpha1.TaintPolicy{MaxNoExecuteNodesPercent: &limit}
+
+	// reconcile returns the node after reconciling it with the policy at the given time
+	reconcile := func(node *corev1.Node, policy *configv1alpha1.TaintPolicy, configNodeTaints []string, now time.Time) *corev1.Node {
+		node = node.DeepCopy()
+		tainter := NewTainter(policy).(*tainter)
+		tainter.now = func() time.Time { return now }
+		if err := tainter.Reconcile(node, configNodeTaints); err != nil && !errors.Is(err, errors.ErrSkipUpdate) {
+			panic(err)
+		}
+		return node
+	}
+	annotate := func(node *corev1.Node, annotations ...string) *corev1.Node {
+		node = node.DeepCopy()
+		for _, annotation := range annotations {
+			node.Annotations[annotation] = "true"
+		}
+		return node
+	}
+	graceStarted := reconcile(&corev1.Node{}, gracePolicy, configNodeTaints, start)
+	requested := reconcile(&corev1.Node{}, limitPolicy, configNodeTaints, start)
+
+	tests := []struct {
+		name              string
+		node              *corev1.Node
+		policy            *configv1alpha1.TaintPolicy
+		configNodeTaints  []string
+		now               time.Time
+		wantErr           error
+		wantTaints        []string
+		wantAddedTaints   string
+		wantSkipped       []string
+		wantDelayed       []string
+		wantRequeueAfter  time.Duration
+		wantAnnotations   map[string]string
+		absentAnnotations []string
+	}{
+		{
+			name:             "NoExecute taints are forbidden",
+			node:             &corev1.Node{},
+			policy:           &configv1alpha1.TaintPolicy{NoExecute: configv1alpha1.NoExecutePolicyForbid},
+			configNodeTaints: configNodeTaints,
+			wantTaints:       []string{"arm=true:NoSchedule"},
+			wantAddedTaints:  "arm=true:NoSchedule",
+			wantSkipped:      []string{"dedicated=db:NoExecute"},
+		},
+		{
+			name:             "NoExecute taint that was opted in",
+			node:             &corev1.Node{},
+			policy:           &configv1alpha1.TaintPolicy{NoExecute: configv1alpha1.NoExecutePolicyOptIn, NoExecuteKeys: []string{"dedicated"}},
+			configNodeTaints: configNodeTaints,
+			wantTaints:       []string{"arm=true:NoSchedule", "dedicated=db:NoExecute"},
+			wantAddedTaints:  "arm=true:NoSchedule,dedicated=db:NoExecute",
+		},
+		{
+			name:             "NoExecute taint added before is removed once it is forbidden",
+			node:             reconcile(&corev1.Node{}, nil, configNodeTaints, start),
+			policy:           &configv1alpha1.TaintPolicy{NoExecute: configv1alpha1.NoExecutePolicyOptIn},
+			configNodeTaints: configNodeTaints,
+			wantTaints:       []string{"arm=true:NoSchedule"},
+			wantAddedTaints:  "arm=true:NoSchedule",
+			wantSkipped:      []string{"dedicated=db:NoExecute"},
+		},
+		{
+			name:             "NoExecute taint starts its grace period as a NoSchedule taint",
+			node:             &corev1.Node{},
+			policy:           gracePolicy,
+			configNodeTaints: configNodeTaints,
+			now:              start,
+			wantTaints:       []string{"arm=true:NoSchedule", "dedicated=db:NoSchedule"},
+			wantAddedTaints:  "arm=true:NoSchedule,dedicated=db:NoSchedule",
+			wantDelayed:      []string{"dedicated=db:NoExecute"},
+			wantRequeueAfter: 5 * time.Minute,
+			wantAnnotations:  map[string]string{consts.NoExecuteGraceNodeAnnotation(): `{"dedicated=db:NoExecute":"2021-06-01T12:00:00Z"}`},
+		},
+		{
+			name:             "NoExecute taint waits for its grace period",
+			node:             graceStarted,
+			policy:           gracePolicy,
+			configNodeTaints: configNodeTaints,
+			now:              start.Add(time.Minute),
+			wantErr:          errors.ErrSkipUpdate,
+			wantTaints:       []string{"arm=true:NoSchedule", "dedicated=db:NoSchedule"},
+			wantAddedTaints:  "arm=true:NoSchedule,dedicated=db:NoSchedule",
+			wantDelayed:      []string{"dedicated=db:NoExecute"},
+			wantRequeueAfter: 4 * time.Minute,
+		},
+		{
+			name:              "NoExecute taint is escalated after its grace period",
+			node:              graceStarted,
+			policy:            gracePolicy,
+			configNodeTaints:  configNodeTaints,
+			now:               start.Add(5 * time.Minute),
+			wantTaints:        []string{"arm=true:NoSchedule", "dedicated=db:NoExecute"},
+			wantAddedTaints:   "arm=true:NoSchedule,dedicated=db:NoExecute",
+			absentAnnotations: []string{consts.NoExecuteGraceNodeAnnotation()},
+		},
+		{
+			name:              "grace period is dropped when the taint is removed from the node config",
+			node:              graceStarted,
+			policy:            gracePolicy,
+			configNodeTaints:  []string{"arm=true:NoSchedule"},
+			now:               start.Add(time.Minute),
+			wantTaints:        []string{"arm=true:NoSchedule"},
+			wantAddedTaints:   "arm=true:NoSchedule",
+			absentAnnotations: []string{consts.NoExecuteGraceNodeAnnotation()},
+		},
+		{
+			name:             "NoExecute taint waits for the approval of the leader",
+			node:             &corev1.Node{},
+			policy:           limitPolicy,
+			configNodeTaints: configNodeTaints,
+			wantTaints:       []string{"arm=true:NoSchedule", "dedicated=db:NoSchedule"},
+			wantAddedTaints:  "arm=true:NoSchedule,dedicated=db:NoSchedule",
+			wantDelayed:      []string{"dedicated=db:NoExecute"},
+			wantAnnotations:  map[string]string{consts.NoExecuteRequestedNodeAnnotation(): "true"},
+		},
+		{
+			name:              "NoExecute taint is added once the leader approved it",
+			node:              annotate(requested, consts.NoExecuteApprovedNodeAnnotation()),
+			policy:            limitPolicy,
+			configNodeTaints:  configNodeTaints,
+			wantTaints:        []string{"arm=true:NoSchedule", "dedicated=db:NoExecute"},
+			wantAddedTaints:   "arm=true:NoSchedule,dedicated=db:NoExecute",
+			wantAnnotations:   map[string]string{consts.NoExecuteApprovedNodeAnnotation(): "true"},
+			absentAnnotations: []string{consts.NoExecuteRequestedNodeAnnotation()},
+		},
+		{
+			name:             "NoExecute taint the node carries doesn't need an approval",
+			node:             reconcile(&corev1.Node{}, nil, configNodeTaints, start),
+			policy:           limitPolicy,
+			configNodeTaints: configNodeTaints,
+			wantErr:          errors.ErrSkipUpdate,
+			wantTaints:       []string{"arm=true:NoSchedule", "dedicated=db:NoExecute"},
+			wantAddedTaints:  "arm=true:NoSchedule,dedicated=db:NoExecute",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			node := tt.node.DeepCopy()
+			tainter := NewTainter(tt.policy).(*tainter)
+			tainter.now = func() time.Time { return tt.now }
+			err := tainter.Reconcile(node, tt.configNodeTaints)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("tainter.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var sortedTaints []string
+			for _, taint := range node.Spec.Taints {
+				sortedTaints = append(sortedTaints, taint.ToString())
+			}
+			sort.Strings(sortedTaints)
+			if !reflect.DeepEqual(sortedTaints, tt.wantTaints) {
+				t.Errorf("tainter.Reconcile() taints = %v, want %v", sortedTaints, tt.wantTaints)
+			}
+			addedTaints := ManagedTaints(node)
+			sort.Strings(addedTaints)
+			if got := strings.Join(addedTaints, ","); got != tt.wantAddedTaints {
+				t.Errorf("tainter.Reconcile() added taints annotation = %q, want %q", got, tt.wantAddedTaints)
+			}
+			if !reflect.DeepEqual(tainter.SkippedTaints(), tt.wantSkipped) {
+				t.Errorf("tainter.SkippedTaints() = %v, want %v", tainter.SkippedTaints(), tt.wantSkipped)
+			}
+			if !reflect.DeepEqual(tainter.DelayedTaints(), tt.wantDelayed) {
+				t.Errorf("tainter.DelayedTaints() = %v, want %v", tainter.DelayedTaints(), tt.wantDelayed)
+			}
+			if tainter.RequeueAfter() != tt.wantRequeueAfter {
+				t.Errorf("tainter.RequeueAfter() = %v, want %v", tainter.RequeueAfter(), tt.wantRequeueAfter)
+			}
+			for key, value := range tt.wantAnnotations {
+				if got := node.GetAnnotations()[key]; got != value {
+					t.Errorf("tainter.Reconcile() annotation %s = %q, want %q", key, got, value)
+				}
+			}
+			for _, key := range tt.absentAnnotations {
+				if _, ok := node.GetAnnotations()[key]; ok {
+					t.Errorf("tainter.Reconcile() annotation %s exists, want it removed", key)
+				}
+			}
+		})
+	}
+}
@@ -23,7 +23,7 @@ func defaultTaintedNode() *corev1.Node {
 
 func taintedNode(nodeTaints []string) *corev1.Node {
 	node := defaultTaintedNode()
-	l := NewTainter()
+	l := NewTainter(nil)
 	if err := l.Reconcile(node, nodeTaints); err != nil {
 		panic(err)
 	}
@@ -174,7 +174,7 @@ func Test_tainter_Reconcile(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			l := NewTainter()
+			l := NewTainter(nil)
 			err := l.Reconcile(tt.args.node, tt.args.configNodeTaints)
 			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("tainter.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
@@ -193,7 +193,7 @@ func Test_tainter_Reconcile(t *testing.T) {
 				}
 			}
 
-			addedTaintsMap := getAddedTaints(tt.args.node)
+			addedTaintsMap, _, _ := getAddedTaints(tt.args.node)
 			var addedTaints []string
 			for addedTaint := range addedTaintsMap {
 				addedTaints = append(addedTaints, addedTaint.ToString())
@@ -0,0 +1,102 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/annismckenzie/k3os-config-operator/config"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// ConfigFileWatcher watches the node config file and the config.d directory and emits an event whenever they
+// change so changes made on the node (e.g. by hand over SSH) are noticed right away. It is a manager.Runnable.
+type ConfigFileWatcher struct {
+	configuration *config.Configuration
+	logger        logr.Logger
+	events        chan event.GenericEvent
+}
+
+// NewConfigFileWatcher returns an initialized ConfigFileWatcher.
+func NewConfigFileWatcher(configuration *config.Configuration, logger logr.Logger) *ConfigFileWatcher {
+	return &ConfigFileWatcher{
+		configuration: configuration,
+		logger:        logger,
+		events:        make(chan event.GenericEvent, 1),
+	}
+}
+
+// Events returns the channel the change events are sent to, to be used with source.Channel.
+func (w *ConfigFileWatcher) Events() <-chan event.GenericEvent {
+	return w.events
+}
+
+// NeedLeaderElection satisfies the manager.LeaderElectionRunnable interface: every node watches its own files.
+func (w *ConfigFileWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start watches the files until the context is done. It watches the directories containing the files because
+// atomic writes replace the files which would end a watch on the files themselves.
+func (w *ConfigFileWatcher) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create node config file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	configFile := filepath.Clean(w.configuration.NodeConfigFileLocation)
+	if err = watcher.Add(filepath.Dir(configFile)); err != nil {
+		return fmt.Errorf("failed to watch node config file: %w", err)
+	}
+	var dropInDir string
+	if w.configuration.EnableNodeConfigDropInManagement() {
+		dropInDir = filepath.Clean(w.configuration.NodeConfigDropInDir)
+		if err = watcher.Add(dropInDir); err != nil && !os.IsNotExist(err) { // a missing directory is created by the first update
+			return fmt.Errorf("failed to watch config.d directory: %w", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			name := filepath.Clean(e.Name)
+			switch {
+			case name == configFile, dropInDir != "" && filepath.Dir(name) == dropInDir:
+			case name == dropInDir && e.Op&fsnotify.Create != 0: // the config.d directory was created after starting
+				if err = watcher.Add(dropInDir); err != nil {
+					w.logger.Error(err, "failed to watch config.d directory")
+				}
+				continue
+			default:
+				continue
+			}
+			w.logger.V(1).Info("node config file changed on disk", "file", name, "op", e.Op.String())
+			w.notify()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			w.logger.Error(err, "failed to watch node config file")
+		}
+	}
+}
+
+// notify sends an event without blocking: a pending event already triggers a sync that sees all changes.
+func (w *ConfigFileWatcher) notify() {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: w.configuration.NodeName}}
+	select {
+	case w.events <- event.GenericEvent{Object: node}:
+	default:
+	}
+}
@@ -0,0 +1,44 @@
+package nodes
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/annismckenzie/k3os-config-operator/config"
+	"github.com/go-logr/logr"
+)
+
+func TestConfigFileWatcher(t *testing.T) {
+	dir := t.TempDir()
+	configuration := &config.Configuration{NodeName: "n1", NodeConfigFileLocation: filepath.Join(dir, "config.yaml")}
+	watcher := NewConfigFileWatcher(configuration, logr.Discard())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- watcher.Start(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+	}()
+	time.Sleep(100 * time.Millisecond) // give the watcher time to start
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "unrelated.yaml"), []byte("a: b\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(configuration.NodeConfigFileLocation, []byte("hostname: a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-watcher.Events():
+		if e.Object.GetName() != "n1" {
+			t.Errorf("event object = %q, want node n1", e.Object.GetName())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event after changing the node config file")
+	}
+}
@@ -0,0 +1,63 @@
+package atomicfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to a temporary file in the directory of filename, syncs it to disk and renames it to filename.
+// The directory is synced afterwards so the rename survives a power loss. The permissions of an existing file are kept,
+// perm is used for new files.
+func WriteFile(filename string, data []byte, perm os.FileMode) (err error) {
+	if info, statErr := os.Stat(filename); statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	tempFile, err := ioutil.TempFile(dir, "."+base+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		if err != nil { // leaves the original error in place but cleans up the temporary file
+			_ = tempFile.Close()
+			_ = os.Remove(tempFile.Name())
+		}
+	}()
+
+	if _, err = tempFile.Write(data); err != nil {
+		return err
+	}
+	if err = tempFile.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tempFile.Sync(); err != nil {
+		return err
+	}
+	if err = tempFile.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tempFile.Name(), filename); err != nil {
+		return err
+	}
+	return SyncDir(dir)
+}
+
+// SyncDir syncs the directory to disk which persists renames and removals of the files in it.
+func SyncDir(dir string) (err error) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := d.Close(); err == nil {
+			err = errClose
+		}
+	}()
+	return d.Sync()
+}
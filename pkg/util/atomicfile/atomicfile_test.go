@@ -0,0 +1,56 @@
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.yaml")
+
+	if err := WriteFile(filename, []byte("hostname: a\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	assertFile(t, filename, "hostname: a\n", 0600)
+
+	if err := os.Chmod(filename, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(filename, []byte("hostname: b\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	assertFile(t, filename, "hostname: b\n", 0640) // the permissions of existing files are kept
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected temporary files to be gone, found %d files", len(entries))
+	}
+
+	if err = WriteFile(filepath.Join(dir, "missing", "config.yaml"), []byte("hostname: c\n"), 0600); err == nil {
+		t.Error("WriteFile() into a missing directory should fail")
+	}
+}
+
+func assertFile(t *testing.T, filename, wantData string, wantPerm os.FileMode) {
+	t.Helper()
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != wantData {
+		t.Errorf("file contents = %q, want %q", data, wantData)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != wantPerm {
+		t.Errorf("file permissions = %v, want %v", info.Mode().Perm(), wantPerm)
+	}
+}
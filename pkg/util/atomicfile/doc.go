@@ -0,0 +1,3 @@
+// Package atomicfile writes files so that readers (and the k3OS boot process) see either the old or the new contents
+// but never a partially written file, even if the node loses power in the middle of the write.
+package atomicfile
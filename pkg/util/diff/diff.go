@@ -0,0 +1,136 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around every change.
+const context = 3
+
+type op struct {
+	kind       byte // ' ', '-' or '+'
+	text       string
+	aPos, bPos int // the number of lines of a and b before this op
+}
+
+// Unified returns the unified diff between a and b with three lines of context. The names are used in the
+// header of the diff. It returns an empty string if both are equal.
+func Unified(fromName, toName string, a, b []byte) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var buf strings.Builder
+	for start := 0; start < len(ops); {
+		first := nextChange(ops, start)
+		if first == len(ops) {
+			break
+		}
+		// extend the hunk as long as the next change is within the context of the current one
+		last := first
+		for next := nextChange(ops, last+1); next < len(ops) && next-last <= 2*context; next = nextChange(ops, last+1) {
+			last = next
+		}
+		from, to := max(first-context, 0), min(last+context+1, len(ops))
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		writeHunk(&buf, ops[from:to])
+		start = to
+	}
+	return buf.String()
+}
+
+func writeHunk(buf *strings.Builder, ops []op) {
+	var aLen, bLen int
+	for _, o := range ops {
+		if o.kind != '+' {
+			aLen++
+		}
+		if o.kind != '-' {
+			bLen++
+		}
+	}
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(ops[0].aPos, aLen), hunkRange(ops[0].bPos, bLen))
+	for _, o := range ops {
+		buf.WriteByte(o.kind)
+		buf.WriteString(o.text)
+		buf.WriteByte('\n')
+	}
+}
+
+// hunkRange formats the range of a hunk like GNU diff: empty ranges start at the line before them.
+func hunkRange(pos, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", pos+1)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, length)
+}
+
+func nextChange(ops []op, start int) int {
+	for i := start; i < len(ops); i++ {
+		if ops[i].kind != ' ' {
+			return i
+		}
+	}
+	return len(ops)
+}
+
+// diffLines returns the ops that turn a into b based on their longest common subsequence.
+func diffLines(a, b []string) []op {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{kind: ' ', text: a[i], aPos: i, bPos: j})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{kind: '-', text: a[i], aPos: i, bPos: j})
+			i++
+		default:
+			ops = append(ops, op{kind: '+', text: b[j], aPos: i, bPos: j})
+			j++
+		}
+	}
+	return ops
+}
+
+func splitLines(data []byte) []string {
+	s := strings.TrimSuffix(string(data), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
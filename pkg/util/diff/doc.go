@@ -0,0 +1,2 @@
+// Package diff computes line-based unified diffs, e.g. to show the changes a sync would make to the node config file.
+package diff
@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	"github.com/annismckenzie/k3os-config-operator/pkg/consts"
+	"github.com/annismckenzie/k3os-config-operator/pkg/nodeconfig"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// NodeConfigSecretPath is the path the node config Secret validating webhook is served on.
+const NodeConfigSecretPath = "/validate-v1-secret-nodeconfig"
+
+// +kubebuilder:webhook:path=/validate-v1-secret-nodeconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups="",resources=secrets,verbs=create;update,versions=v1,name=vnodeconfigsecret.kb.io,admissionReviewVersions=v1
+
+// NodeConfigSecretValidator validates the Secret containing the k3OS config.yaml files of all nodes.
+type NodeConfigSecretValidator struct {
+	client   client.Client
+	decoder  *admission.Decoder
+	selector labels.Selector
+}
+
+// NodeConfigSecretValidator implements the admission.Handler, admission.DecoderInjector and inject.Client interfaces.
+var (
+	_ admission.Handler         = (*NodeConfigSecretValidator)(nil)
+	_ admission.DecoderInjector = (*NodeConfigSecretValidator)(nil)
+	_ inject.Client             = (*NodeConfigSecretValidator)(nil)
+)
+
+// NewNodeConfigSecretWebhook returns the validating webhook for the node config Secret.
+func NewNodeConfigSecretWebhook() (*webhook.Admission, error) {
+	labelSelector := consts.LabelSelectorForNodeConfigFileSecret()
+	selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook.Admission{Handler: &NodeConfigSecretValidator{selector: selector}}, nil
+}
+
+// InjectClient injects the client.
+func (v *NodeConfigSecretValidator) InjectClient(c client.Client) error {
+	v.client = c
+	return nil
+}
+
+// InjectDecoder injects the decoder.
+func (v *NodeConfigSecretValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle rejects node config Secrets with entries that cannot be parsed or fail validation.
+// Secrets that don't carry the node config Secret labels are always allowed.
+func (v *NodeConfigSecretValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	secret := &corev1.Secret{}
+	if err := v.decoder.Decode(req, secret); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !v.selector.Matches(labels.Set(secret.GetLabels())) {
+		return admission.Allowed("not a node config secret")
+	}
+
+	templating, err := v.templatingEnabled(ctx, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	allErrs, warnings := ValidateNodeConfigSecret(secret, templating)
+	if len(allErrs) > 0 {
+		err := apierrors.NewInvalid(corev1.SchemeGroupVersion.WithKind("Secret").GroupKind(), secret.GetName(), allErrs)
+		status := err.Status()
+		return admission.Response{
+			AdmissionResponse: admissionv1.AdmissionResponse{Allowed: false, Result: &status, Warnings: warnings},
+		}
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// templatingEnabled returns whether a K3OSConfig in the namespace renders the entries of the node config Secret as templates.
+func (v *NodeConfigSecretValidator) templatingEnabled(ctx context.Context, namespace string) (bool, error) {
+	k3OSConfigs := &configv1alpha1.K3OSConfigList{}
+	if err := v.client.List(ctx, k3OSConfigs, client.InNamespace(namespace)); err != nil {
+		return false, err
+	}
+	for _, k3OSConfig := range k3OSConfigs.Items {
+		if templating := k3OSConfig.Spec.Templating; templating != nil && templating.Enabled {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ValidateNodeConfigSecret parses and validates every entry of the node config Secret.
+// It returns the errors and the warnings of all entries. If templating is enabled, the template actions of an entry
+// are stubbed out (see nodeconfig.StubTemplate) and everything but the values they produce is validated.
+func ValidateNodeConfigSecret(secret *corev1.Secret, templating bool) (field.ErrorList, []string) {
+	keys := make([]string, 0, len(secret.Data)+len(secret.StringData))
+	data := make(map[string][]byte, len(secret.Data)+len(secret.StringData))
+	for key, value := range secret.Data {
+		data[key] = value
+	}
+	for key, value := range secret.StringData { // StringData takes precedence over Data
+		data[key] = []byte(value)
+	}
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var (
+		allErrs  field.ErrorList
+		warnings []string
+		dataPath = field.NewPath("data")
+	)
+	for _, key := range keys {
+		var (
+			entryPath = dataPath.Key(key)
+			entry     = data[key]
+			templated = templating && bytes.Contains(entry, []byte("{{"))
+			err       error
+		)
+		if templated {
+			if entry, err = nodeconfig.StubTemplate(key, entry); err != nil {
+				allErrs = append(allErrs, field.Invalid(entryPath, "(template)", err.Error()))
+				continue
+			}
+		}
+		spec, err := configv1alpha1.ParseConfigYAML(entry)
+		if spec == nil {
+			if templated { // the YAML structure depends on the template actions, e.g. a key or a whole list is templated
+				warnings = append(warnings, fmt.Sprintf("%s: contains template actions and is only validated when it's rendered for a node", entryPath))
+				continue
+			}
+			allErrs = append(allErrs, field.Invalid(entryPath, "(YAML data)", err.Error()))
+			continue
+		}
+		for _, fieldErr := range spec.ValidateFields() {
+			if templated && strings.Contains(fmt.Sprint(fieldErr.BadValue), nodeconfig.TemplatePlaceholder) {
+				continue // the value is only known once the template is rendered for a node
+			}
+			fieldErr.Field = entryPath.String() + "." + fieldErr.Field
+			allErrs = append(allErrs, fieldErr)
+		}
+		for _, warning := range spec.Warnings() {
+			if templated && strings.Contains(warning, nodeconfig.TemplatePlaceholder) {
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("%s: %s", entryPath, warning))
+		}
+	}
+	return allErrs, warnings
+}
@@ -0,0 +1,197 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	configv1alpha1 "github.com/annismckenzie/k3os-config-operator/apis/config/v1alpha1"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const validNodeConfig = `hostname: n1-node
+k3os:
+  labels:
+    region: shelf-1
+  taints:
+  - key1=value1:NoSchedule
+`
+
+func nodeConfigSecret(managed bool, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: "k3os-nodes", Namespace: "k3os-config-operator-system"},
+		Data:       map[string][]byte{},
+	}
+	if managed {
+		secret.Labels = map[string]string{"app.kubernetes.io/managed-by": "k3os-config-operator"}
+	}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+	return secret
+}
+
+const nodeConfigTemplate = `{{- $zones := fromYaml .Values.zones }}
+hostname: {{ .Node.Name }}
+k3os:
+  labels:
+    topology.kubernetes.io/zone: {{ index $zones .Node.Name | default "unknown" }}
+  k3s_args:
+  {{- if eq .Node.Architecture "arm64" }}
+  - --kubelet-arg=image-gc-high-threshold=70
+  {{- end }}
+`
+
+func k3OSConfig(templating bool) *configv1alpha1.K3OSConfig {
+	return &configv1alpha1.K3OSConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "k3osconfig", Namespace: "k3os-config-operator-system"},
+		Spec:       configv1alpha1.K3OSConfigSpec{Templating: &configv1alpha1.K3OSConfigTemplating{Enabled: templating}},
+	}
+}
+
+func TestNodeConfigSecretValidator_Handle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := configv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		k3OSConfig    *configv1alpha1.K3OSConfig
+		secret        *corev1.Secret
+		allowed       bool
+		errorContains []string
+		warnings      int
+	}{
+		{
+			name:    "Secret that isn't a node config Secret",
+			secret:  nodeConfigSecret(false, map[string]string{"n1": "not: [valid"}),
+			allowed: true,
+		},
+		{
+			name:    "valid node config Secret",
+			secret:  nodeConfigSecret(true, map[string]string{"n1": validNodeConfig, "n2": validNodeConfig}),
+			allowed: true,
+		},
+		{
+			name:     "valid node config Secret with unknown keys",
+			secret:   nodeConfigSecret(true, map[string]string{"n1": validNodeConfig + "unknown: key\n"}),
+			allowed:  true,
+			warnings: 1,
+		},
+		{
+			name:          "node config Secret with template without templating",
+			k3OSConfig:    k3OSConfig(false),
+			secret:        nodeConfigSecret(true, map[string]string{"n1": "hostname: {{ .Node.Name }}\n"}),
+			errorContains: []string{"data[n1]"},
+		},
+		{
+			name:       "node config Secret with template",
+			k3OSConfig: k3OSConfig(true),
+			secret:     nodeConfigSecret(true, map[string]string{"n1": nodeConfigTemplate}),
+			allowed:    true,
+		},
+		{
+			name:       "node config Secret with template with invalid fields",
+			k3OSConfig: k3OSConfig(true),
+			secret: nodeConfigSecret(true, map[string]string{
+				"n1": "hostname: {{ .Node.Name }}\nk3os:\n  taints:\n  - key-\n",
+				"n2": "hostname: n2\nk3os:\n  labels:\n    arch: {{ .Node.Architecture | upper }}_Invalid\n",
+			}),
+			errorContains: []string{"data[n1].k3os.taints[0]"},
+		},
+		{
+			name:          "node config Secret with broken template",
+			k3OSConfig:    k3OSConfig(true),
+			secret:        nodeConfigSecret(true, map[string]string{"n1": "hostname: {{ .Node.Name\n"}),
+			errorContains: []string{"data[n1]", "failed to parse template"},
+		},
+		{
+			name:       "node config Secret with template generating YAML",
+			k3OSConfig: k3OSConfig(true),
+			secret: nodeConfigSecret(true, map[string]string{
+				"n1": "k3os:\n  labels:\n{{ .Values.labels | indent 4 }}\n  taints:\n  - key1=value1:NoSchedule\n",
+			}),
+			allowed: true,
+		},
+		{
+			name:       "node config Secret with template whose YAML structure depends on its actions",
+			k3OSConfig: k3OSConfig(true),
+			secret: nodeConfigSecret(true, map[string]string{
+				"n1": "k3os:\n  labels: {{ toYaml .Values.labels }}\n    extra: label\n",
+			}),
+			allowed:  true,
+			warnings: 1,
+		},
+		{
+			name:          "node config Secret with broken YAML",
+			secret:        nodeConfigSecret(true, map[string]string{"n1": validNodeConfig, "n2": "k3os: [broken"}),
+			errorContains: []string{"data[n2]"},
+		},
+		{
+			name: "node config Secret with invalid entries",
+			secret: nodeConfigSecret(true, map[string]string{
+				"n1": validNodeConfig,
+				"n2": "hostname: Invalid_Hostname\n",
+				"n3": "k3os:\n  taints:\n  - key-\n",
+			}),
+			errorContains: []string{"data[n2].hostname", "data[n3].k3os.taints[0]"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			wh, err := NewNodeConfigSecretWebhook()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = wh.Handler.(admission.DecoderInjector).InjectDecoder(decoder); err != nil {
+				t.Fatal(err)
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme)
+			if tt.k3OSConfig != nil {
+				c = c.WithObjects(tt.k3OSConfig)
+			}
+			if err = wh.Handler.(inject.Client).InjectClient(c.Build()); err != nil {
+				t.Fatal(err)
+			}
+			raw, err := json.Marshal(tt.secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Update,
+				Namespace: tt.secret.GetNamespace(),
+				Object:    runtime.RawExtension{Raw: raw},
+			}}
+			resp := wh.Handle(context.Background(), req)
+			if resp.Allowed != tt.allowed {
+				t.Fatalf("Handle() allowed = %v, want %v (result: %v)", resp.Allowed, tt.allowed, resp.Result)
+			}
+			for _, s := range tt.errorContains {
+				if !strings.Contains(resp.Result.Message, s) {
+					t.Errorf("Handle() message %q does not contain %q", resp.Result.Message, s)
+				}
+			}
+			if len(resp.Warnings) != tt.warnings {
+				t.Errorf("Handle() warnings = %v, want %d warnings", resp.Warnings, tt.warnings)
+			}
+		})
+	}
+}